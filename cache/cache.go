@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/kenretto/crane/codec"
+	credis "github.com/kenretto/crane/redis"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound the key isn't cached, or the loader reports that the value doesn't exist
+var ErrNotFound = errors.New("cache: not found")
+
+// errPanicked the error of the loads waiting for a loader that panicked
+var errPanicked = errors.New("cache: the loader panicked")
+
+// the first byte of the stored values
+const (
+	flagValue    = 'v'
+	flagNotFound = 'n'
+)
+
+// Options the options of a cache
+//  Connection: the connection of the redis node, default default
+//  Prefix: prepended to the keys and the tags, such as "users:", so that the caches sharing a connection never collide
+//  Codec: default codec.GobCodec
+//  TTL: how long the values are kept in redis, default 10m
+//  Jitter: the ttls are randomly extended by up to this fraction, so that the keys set together don't expire together, such as 0.1
+//  LocalSize: the max number of the entries kept in the local LRU in front of redis, 0 disables it
+//  LocalTTL: how long the local entries are kept, default 1m, the local entries of the other processes aren't invalidated, so keep it short
+//  NegativeTTL: how long ErrNotFound of the loader is cached, 0 disables the negative caching
+type Options struct {
+	Connection  string
+	Prefix      string
+	Codec       codec.Codec
+	TTL         time.Duration
+	Jitter      float64
+	LocalSize   int
+	LocalTTL    time.Duration
+	NegativeTTL time.Duration
+}
+
+// Cache a typed cache of the values of T, the values are kept in an optional local LRU and in redis
+//  var users = cache.New[User](crane.Redis(), cache.Options{Prefix: "users:", LocalSize: 1000})
+//  user, err := users.Load(ctx, id, func(ctx context.Context) (User, error) {
+//      return findUser(ctx, id)
+//  }, "team:"+teamID)
+type Cache[T any] struct {
+	redis   *credis.Redis
+	options Options
+	local   *lru
+	group   group[T]
+}
+
+// New r may be nil, then only the local tier is used and LocalSize defaults to 1024
+func New[T any](r *credis.Redis, options Options) *Cache[T] {
+	if options.Connection == "" {
+		options.Connection = credis.DefaultConnection
+	}
+	if options.Codec == nil {
+		options.Codec = codec.GobCodec{}
+	}
+	if options.TTL <= 0 {
+		options.TTL = 10 * time.Minute
+	}
+	if options.LocalTTL <= 0 {
+		options.LocalTTL = time.Minute
+	}
+	if r == nil && options.LocalSize <= 0 {
+		options.LocalSize = 1024
+	}
+	var c = &Cache[T]{redis: r, options: options}
+	if options.LocalSize > 0 {
+		c.local = newLRU(options.LocalSize)
+	}
+	return c
+}
+
+func (c *Cache[T]) client() redis.Cmdable {
+	if c.redis == nil {
+		return nil
+	}
+	return c.redis.Connection(c.options.Connection)
+}
+
+func (c *Cache[T]) key(key string) string {
+	return c.options.Prefix + key
+}
+
+func (c *Cache[T]) tag(tag string) string {
+	return c.options.Prefix + "tag:" + tag
+}
+
+// ttl the jittered ttl, the default ttl is used if it isn't positive
+func (c *Cache[T]) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = c.options.TTL
+	}
+	if c.options.Jitter > 0 {
+		ttl += time.Duration(rand.Float64() * c.options.Jitter * float64(ttl))
+	}
+	return ttl
+}
+
+// get the stored value, false if the key isn't cached
+func (c *Cache[T]) get(ctx context.Context, key string) ([]byte, bool, error) {
+	if c.local != nil {
+		if data, ok := c.local.get(key); ok {
+			return data, true, nil
+		}
+	}
+	var client = c.client()
+	if client == nil {
+		return nil, false, nil
+	}
+	data, err := client.Get(ctx, c.key(key)).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil || len(data) == 0 {
+		return nil, false, err
+	}
+	if c.local != nil {
+		c.local.set(key, data, nil, c.options.LocalTTL)
+	}
+	return data, true, nil
+}
+
+func (c *Cache[T]) decode(data []byte) (T, error) {
+	var val T
+	if data[0] == flagNotFound {
+		return val, ErrNotFound
+	}
+	err := c.options.Codec.Unmarshal(data[1:], &val)
+	return val, err
+}
+
+// set store the value of the flag, the tag sets are kept at least as long as the default ttl
+func (c *Cache[T]) set(ctx context.Context, key string, data []byte, ttl time.Duration, tags []string) error {
+	if c.local != nil {
+		var local = c.options.LocalTTL
+		if c.redis == nil || ttl < local {
+			local = ttl
+		}
+		c.local.set(key, data, tags, local)
+	}
+	var client = c.client()
+	if client == nil {
+		return nil
+	}
+	var tagTTL = ttl
+	if maximum := time.Duration((1 + c.options.Jitter) * float64(c.options.TTL)); tagTTL < maximum {
+		tagTTL = maximum
+	}
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.key(key), data, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, c.tag(tag), c.key(key))
+			pipe.Expire(ctx, c.tag(tag), tagTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+// Get the cached value, ErrNotFound if the key isn't cached or the missing value is cached
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, error) {
+	data, ok, err := c.get(ctx, key)
+	if err != nil || !ok {
+		var val T
+		if err == nil {
+			err = ErrNotFound
+		}
+		return val, err
+	}
+	return c.decode(data)
+}
+
+// Set cache the value with the tags, the default ttl is used if ttl isn't positive
+func (c *Cache[T]) Set(ctx context.Context, key string, val T, ttl time.Duration, tags ...string) error {
+	data, err := c.options.Codec.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.set(ctx, key, append([]byte{flagValue}, data...), c.ttl(ttl), tags)
+}
+
+// Load get the cached value, or call the loader and cache its value with the tags,
+//  the concurrent loads of a key in the process share one call of the loader,
+//  ErrNotFound returned by the loader is cached for NegativeTTL, the other errors aren't cached,
+//  the loader is the source of truth, so the errors of redis don't fail the load
+func (c *Cache[T]) Load(ctx context.Context, key string, loader func(ctx context.Context) (T, error), tags ...string) (T, error) {
+	if data, ok, err := c.get(ctx, key); err == nil && ok {
+		return c.decode(data)
+	}
+	return c.group.do(key, func() (T, error) {
+		val, err := loader(ctx)
+		if errors.Is(err, ErrNotFound) && c.options.NegativeTTL > 0 {
+			_ = c.set(ctx, key, []byte{flagNotFound}, c.options.NegativeTTL, tags)
+		}
+		if err != nil {
+			return val, err
+		}
+		data, err := c.options.Codec.Marshal(val)
+		if err != nil {
+			return val, err
+		}
+		_ = c.set(ctx, key, append([]byte{flagValue}, data...), c.ttl(0), tags)
+		return val, nil
+	})
+}
+
+// Delete delete the keys
+func (c *Cache[T]) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if c.local != nil {
+		c.local.delete(keys...)
+	}
+	var client = c.client()
+	if client == nil {
+		return nil
+	}
+	var prefixed = make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = c.key(key)
+	}
+	return client.Del(ctx, prefixed...).Err()
+}
+
+// Invalidate delete the keys set with any of the tags
+func (c *Cache[T]) Invalidate(ctx context.Context, tags ...string) error {
+	if c.local != nil {
+		c.local.deleteTags(tags...)
+	}
+	var client = c.client()
+	if client == nil {
+		return nil
+	}
+	for _, tag := range tags {
+		keys, err := client.SMembers(ctx, c.tag(tag)).Result()
+		if err != nil {
+			return err
+		}
+		// the local entries read from redis don't know their tags
+		if c.local != nil {
+			for _, key := range keys {
+				c.local.delete(strings.TrimPrefix(key, c.options.Prefix))
+			}
+		}
+		if err = client.Del(ctx, append(keys, c.tag(tag))...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// call a load in flight
+type call[T any] struct {
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// group the loads of the same key share one call
+type group[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*call[T]
+}
+
+func (g *group[T]) do(key string, fn func() (T, error)) (T, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*call[T])
+	}
+	var c = &call[T]{done: make(chan struct{}), err: errPanicked}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val, c.err = fn()
+	return c.val, c.err
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/kenretto/crane/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type user struct {
+	Name  string
+	Age   int
+	Roles []string
+}
+
+func TestCache_Protobuf(t *testing.T) {
+	var c = New[*wrapperspb.StringValue](nil, Options{Codec: codec.ProtobufCodec{}})
+	if err := c.Set(context.Background(), "message", wrapperspb.String("hello"), 0); err != nil {
+		t.Fatal(err)
+	}
+	message, err := c.Get(context.Background(), "message")
+	if err != nil || !proto.Equal(message, wrapperspb.String("hello")) {
+		t.Errorf("unexpected message %v %v", message, err)
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	var ctx = context.Background()
+	var c = New[user](nil, Options{NegativeTTL: 50 * time.Millisecond})
+	var calls int32
+	var loader = func(ctx context.Context) (user, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return user{Name: "medivh"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if u, err := c.Load(ctx, "medivh", loader); err != nil || u.Name != "medivh" {
+				t.Errorf("unexpected load %+v %v", u, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, _ = c.Load(ctx, "medivh", loader); calls != 1 {
+		t.Errorf("the loader should be called once, got %d", calls)
+	}
+
+	var missing = func(ctx context.Context) (user, error) {
+		atomic.AddInt32(&calls, 1)
+		return user{}, ErrNotFound
+	}
+	calls = 0
+	for i := 0; i < 3; i++ {
+		if _, err := c.Load(ctx, "nobody", missing); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the missing value should be cached, got %d calls", calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	_, _ = c.Load(ctx, "nobody", missing)
+	if calls != 2 {
+		t.Errorf("the negative entry should expire, got %d calls", calls)
+	}
+
+	var failed = errors.New("failed")
+	for i := 0; i < 2; i++ {
+		_, _ = c.Load(ctx, "broken", func(ctx context.Context) (user, error) {
+			atomic.AddInt32(&calls, 1)
+			return user{}, failed
+		})
+	}
+	if calls != 4 {
+		t.Errorf("the errors shouldn't be cached, got %d calls", calls)
+	}
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	var ctx = context.Background()
+	var c = New[string](nil, Options{LocalSize: 2, TTL: time.Hour})
+	_ = c.Set(ctx, "a", "a", 0, "team:1")
+	_ = c.Set(ctx, "b", "b", 0, "team:1", "team:2")
+	if err := c.Invalidate(ctx, "team:2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "b"); err != ErrNotFound {
+		t.Errorf("the tagged key should be invalidated, got %v", err)
+	}
+	if v, _ := c.Get(ctx, "a"); v != "a" {
+		t.Errorf("unexpected value %q", v)
+	}
+
+	// the least recently used key is evicted
+	_ = c.Set(ctx, "c", "c", 0)
+	_ = c.Set(ctx, "d", "d", 0)
+	if _, err := c.Get(ctx, "a"); err != ErrNotFound {
+		t.Errorf("the oldest key should be evicted, got %v", err)
+	}
+	if err := c.Delete(ctx, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "c"); err != ErrNotFound {
+		t.Errorf("the deleted key should be removed, got %v", err)
+	}
+
+	_ = c.Set(ctx, "short", "short", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := c.Get(ctx, "short"); err != ErrNotFound {
+		t.Errorf("the expired key should be removed, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry an encoded value of the local tier
+type entry struct {
+	key     string
+	data    []byte
+	tags    []string
+	expires time.Time
+}
+
+// lru the local tier, keeps the encoded values so that the callers never share the decoded ones
+type lru struct {
+	size  int
+	mu    sync.Mutex
+	list  *list.List
+	items map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{size: size, list: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	var e = element.Value.(*entry)
+	if time.Now().After(e.expires) {
+		l.remove(element)
+		return nil, false
+	}
+	l.list.MoveToFront(element)
+	return e.data, true
+}
+
+func (l *lru) set(key string, data []byte, tags []string, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var e = &entry{key: key, data: data, tags: tags, expires: time.Now().Add(ttl)}
+	if element, ok := l.items[key]; ok {
+		element.Value = e
+		l.list.MoveToFront(element)
+		return
+	}
+	l.items[key] = l.list.PushFront(e)
+	for l.list.Len() > l.size {
+		l.remove(l.list.Back())
+	}
+}
+
+func (l *lru) remove(element *list.Element) {
+	l.list.Remove(element)
+	delete(l.items, element.Value.(*entry).key)
+}
+
+func (l *lru) delete(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := l.items[key]; ok {
+			l.remove(element)
+		}
+	}
+}
+
+// deleteTags delete the entries having any of the tags
+func (l *lru) deleteTags(tags ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for element := l.list.Front(); element != nil; {
+		var next = element.Next()
+		for _, tag := range element.Value.(*entry).tags {
+			if contains(tags, tag) {
+				l.remove(element)
+				break
+			}
+		}
+		element = next
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package captcha
 
 import (
+	"github.com/go-redis/redis/v8"
+	credis "github.com/kenretto/crane/redis"
 	"github.com/mojocn/base64Captcha"
 	"github.com/spf13/viper"
 	"sync"
@@ -13,6 +15,7 @@ type (
 		Store  StoreConfig  `mapstructure:"store"`
 
 		captcha *Captcha
+		shared  *credis.Redis
 		rw      sync.RWMutex
 	}
 
@@ -39,8 +42,61 @@ func (loader *Loader) OnChange(viper *viper.Viper) {
 	loader.newInstance()
 }
 
+// newInstance the own redis client of the previous store is closed, its logger is kept
 func (loader *Loader) newInstance() {
-	loader.captcha = &Captcha{base64Captcha.NewCaptcha(loader.Driver.NewDriver(), loader.Store.NewStore())}
+	var store *RedisStore
+	if name := loader.Store.Connection; name != "" {
+		if loader.shared == nil {
+			panic("captcha: call UseRedis before using the redis connection " + name)
+		}
+		var client = loader.shared.Connection(name)
+		if client == nil {
+			panic("captcha: the redis connection " + name + " isn't configured")
+		}
+		store = &RedisStore{logger: NewDefaultLogger(), r: client, shared: true}
+	} else {
+		store = loader.Store.NewStore()
+	}
+
+	var old = loader.captcha
+	loader.captcha = &Captcha{base64Captcha.NewCaptcha(loader.Driver.NewDriver(), store)}
+	if old != nil {
+		if previous, ok := old.Store.(*RedisStore); ok {
+			store.SetLogger(previous.logger)
+			_ = previous.Close()
+		}
+	}
+}
+
+// UseRedis share the connections of r, the store uses the connection named by the connection field of the store config instead of its own client,
+//  and the captcha is recreated when the connection is reloaded, call it before the loader is added to the configurator
+//  captcha:
+//    store:
+//      connection: default
+func (loader *Loader) UseRedis(r *credis.Redis) {
+	loader.rw.Lock()
+	loader.shared = r
+	loader.rw.Unlock()
+	r.OnReload(func(name string, client redis.Cmdable) {
+		loader.rw.Lock()
+		defer loader.rw.Unlock()
+		if client != nil && loader.captcha != nil && loader.Store.Connection == name {
+			loader.newInstance()
+		}
+	})
+}
+
+// Close close the redis connection of the captcha store
+func (loader *Loader) Close() error {
+	loader.rw.Lock()
+	defer loader.rw.Unlock()
+	if loader.captcha == nil {
+		return nil
+	}
+	if store, ok := loader.captcha.Store.(*RedisStore); ok {
+		return store.Close()
+	}
+	return nil
 }
 
 // Instance Get the verification code generation example, do not save this return value as a global variable
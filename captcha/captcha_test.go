@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"github.com/go-redis/redis/v8"
 	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/crane/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"io/ioutil"
@@ -19,7 +20,7 @@ func TestLoader(t *testing.T) {
 		t.Error(err)
 	}
 	c.Add(captcha)
-	id, b64s, err := captcha.Instance().WithLogger(logrus.New()).Generate()
+	id, b64s, err := captcha.Instance().WithLogger(logging.NewLogrus(logrus.NewEntry(logrus.New()))).Generate()
 	if err != nil {
 		t.Error(err)
 	}
@@ -28,7 +29,7 @@ func TestLoader(t *testing.T) {
 		t.Error(err)
 	}
 	_ = ioutil.WriteFile("testdata/captcha.png", data, 0666)
-	v := captcha.Instance().Verify(id, captcha.Instance().WithLogger(logrus.New()).Store.Get(id, false), true)
+	v := captcha.Instance().Verify(id, captcha.Instance().WithLogger(logging.NewLogrus(logrus.NewEntry(logrus.New()))).Store.Get(id, false), true)
 	if !v {
 		t.Error("captcha valid error")
 	}
@@ -55,7 +56,7 @@ func TestImplLoader(t *testing.T) {
 		t.Error(err)
 	}
 	c.Add(captcha)
-	id, b64s, err := captcha.Instance().WithLogger(logrus.New()).Generate()
+	id, b64s, err := captcha.Instance().WithLogger(logging.NewLogrus(logrus.NewEntry(logrus.New()))).Generate()
 	if err != nil {
 		t.Error(err)
 	}
@@ -65,7 +66,7 @@ func TestImplLoader(t *testing.T) {
 		t.Error(err)
 	}
 	_ = ioutil.WriteFile("testdata/captcha.png", data, 0666)
-	v := captcha.Instance().Verify(id, captcha.Instance().WithLogger(logrus.New()).Store.Get(id, false), true)
+	v := captcha.Instance().Verify(id, captcha.Instance().WithLogger(logging.NewLogrus(logrus.NewEntry(logrus.New()))).Store.Get(id, false), true)
 	if !v {
 		t.Error("captcha valid error")
 	}
@@ -1,21 +1,11 @@
 package captcha
 
-import "log"
+import "github.com/kenretto/crane/logging"
 
-// ILogger Log interface required by this package
-type ILogger interface {
-	Error(args ...interface{})
-}
-
-// DefaultLogger The default logger implementation
-type DefaultLogger struct {
-}
-
-func (d DefaultLogger) Error(args ...interface{}) {
-	log.Println(args...)
-}
+// ILogger Log interface required by this package, see package logging for the adapters
+type ILogger = logging.Logger
 
 // NewDefaultLogger default logger
-func NewDefaultLogger() *DefaultLogger {
-	return &DefaultLogger{}
+func NewDefaultLogger() ILogger {
+	return logging.Default()
 }
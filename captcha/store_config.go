@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	"io"
 	"net"
 	"time"
 )
@@ -13,6 +14,7 @@ import (
 type RedisStore struct {
 	logger ILogger
 	r      redis.Cmdable
+	shared bool
 }
 
 // Verify Verify that the verification code is correct
@@ -45,14 +47,24 @@ func (s *RedisStore) Get(id string, clear bool) string {
 	return val
 }
 
+// Close close the redis client, the shared connection is closed by its owner
+func (s *RedisStore) Close() error {
+	if closer, ok := s.r.(io.Closer); ok && !s.shared {
+		return closer.Close()
+	}
+	return nil
+}
+
 // SetLogger set logger
 func (s *RedisStore) SetLogger(logger ILogger) {
 	s.logger = logger
 }
 
 // StoreConfig redis config
+//  connection: the name of a connection of the redis node shared by Loader.UseRedis, the other fields are ignored if it's set
 type StoreConfig struct {
-	RedisType string `mapstructure:"redis_type"`
+	Connection string `mapstructure:"connection"`
+	RedisType  string `mapstructure:"redis_type"`
 
 	Network  string `mapstructure:"network"`
 	Addr     string `mapstructure:"addr"`
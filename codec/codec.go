@@ -0,0 +1,91 @@
+// Package codec the codecs shared by cache, sessions and events to encode the values into bytes
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	jsoniter "github.com/json-iterator/go"
+	msgpack "github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+)
+
+// ErrNotMessage the value given to ProtobufCodec isn't a proto.Message
+var ErrNotMessage = errors.New("codec: the value isn't a proto.Message")
+
+// Codec encodes the values, such as the cached values, the session values and the event payloads
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// GobCodec keeps the go types of the values, the types stored in interface values must be registered with gob.Register
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec readable by the other languages, the values of interface fields are decoded as the json types
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniter.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniter.Unmarshal(data, v)
+}
+
+var msgpackHandle msgpack.MsgpackHandle
+
+// MsgpackCodec more compact than json, the fields are matched by their names
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var data []byte
+	err := msgpack.NewEncoderBytes(&data, &msgpackHandle).Encode(v)
+	return data, err
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.NewDecoderBytes(data, &msgpackHandle).Decode(v)
+}
+
+// ProtobufCodec the values must be pointers to the generated messages, such as cache.Cache[*pb.User]
+type ProtobufCodec struct{}
+
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+	return nil, ErrNotMessage
+}
+
+// Unmarshal v is either a message or a pointer to a message pointer, the message is allocated in the latter case
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	var rv = reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Ptr {
+		return ErrNotMessage
+	}
+	var elem = reflect.New(rv.Elem().Type().Elem())
+	m, ok := elem.Interface().(proto.Message)
+	if !ok {
+		return ErrNotMessage
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return err
+	}
+	rv.Elem().Set(elem)
+	return nil
+}
@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+type user struct {
+	Name  string
+	Age   int
+	Roles []string
+}
+
+func TestCodecs(t *testing.T) {
+	var u = user{Name: "medivh", Age: 25, Roles: []string{"admin"}}
+	for name, codec := range map[string]Codec{"gob": GobCodec{}, "json": JSONCodec{}, "msgpack": MsgpackCodec{}} {
+		data, err := codec.Marshal(u)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		var out user
+		if err = codec.Unmarshal(data, &out); err != nil {
+			t.Fatal(name, err)
+		}
+		if out.Name != u.Name || out.Age != u.Age || len(out.Roles) != 1 {
+			t.Errorf("%s: unexpected value %+v", name, out)
+		}
+	}
+
+	data, err := (ProtobufCodec{}).Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message *wrapperspb.StringValue
+	if err = (ProtobufCodec{}).Unmarshal(data, &message); err != nil || !proto.Equal(message, wrapperspb.String("hello")) {
+		t.Errorf("unexpected message %v %v", message, err)
+	}
+	if _, err = (ProtobufCodec{}).Marshal(u); err != ErrNotMessage {
+		t.Errorf("expected ErrNotMessage, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package crane
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Describe the server node fields read by Crane, the other fields of the node belong to server.HTTPServer
+func (crane *Crane) Describe() interface{} {
+	return &struct {
+		PID  string `mapstructure:"pid"`
+		Name string `mapstructure:"name"`
+	}{}
+}
+
+// SetCommand implemented daemon.Command, add the config subcommand used to check the configuration
+//  config dump     print the effective configuration, secrets are masked
+//  config schema   print the json schema of the configuration
+//  config sample   print an annotated sample yaml configuration
+func (crane *Crane) SetCommand(cmd *cobra.Command) {
+	var config = &cobra.Command{Use: "config", Short: "configuration tools"}
+
+	var dump = &cobra.Command{
+		Use:   "dump",
+		Short: "print the effective configuration, secrets are masked",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			var (
+				data []byte
+				err  error
+			)
+			switch format {
+			case "json":
+				data, err = jsoniter.MarshalIndent(crane.Configurator.Dump(), "", "  ")
+			default:
+				data, err = yaml.Marshal(crane.Configurator.Dump())
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+	dump.Flags().StringP("format", "f", "yaml", "output format, yaml or json")
+
+	var schema = &cobra.Command{
+		Use:   "schema",
+		Short: "print the json schema of the configuration",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := jsoniter.MarshalIndent(crane.Configurator.Schema(), "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	var sample = &cobra.Command{
+		Use:   "sample",
+		Short: "print an annotated sample yaml configuration",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print(crane.Configurator.Sample())
+		},
+	}
+
+	config.AddCommand(dump, schema, sample)
+	cmd.AddCommand(config)
+}
@@ -22,8 +22,9 @@ type Configurator struct {
 	path  string
 	viper *viper.Viper
 
-	mu    sync.Mutex
-	nodes []IConfig
+	mu       sync.Mutex
+	nodes    []IConfig
+	declared []IConfig
 
 	configChangeInterval time.Time
 }
@@ -52,6 +53,13 @@ func (config *Configurator) Add(impl IConfig) {
 	impl.OnChange(config.viper.Sub(impl.Node()))
 }
 
+// Declare declare a node that will be added later, it will not be loaded now, but Schema and Sample will include it
+func (config *Configurator) Declare(impl IConfig) {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	config.declared = append(config.declared, impl)
+}
+
 // NewConfigurator new a configurator
 func NewConfigurator(filename string) (*Configurator, error) {
 	var ext = filepath.Ext(filename)
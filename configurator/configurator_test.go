@@ -0,0 +1,83 @@
+package configurator
+
+import (
+	"encoding/json"
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+)
+
+type redisConfig struct {
+	Config struct {
+		Addr     string   `mapstructure:"addr"`
+		Password string   `mapstructure:"password"`
+		DB       int      `mapstructure:"db"`
+		Hosts    []string `mapstructure:"hosts"`
+		OnClose  func()
+	}
+}
+
+func (r *redisConfig) Node() string {
+	return "redis"
+}
+
+func (r *redisConfig) OnChange(viper *viper.Viper) {
+	_ = viper.Unmarshal(&r.Config)
+}
+
+func (r *redisConfig) Describe() interface{} {
+	return &r.Config
+}
+
+func TestConfigurator_Dump(t *testing.T) {
+	var c, err = NewConfigurator("testdata/configurator.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump = c.Dump()
+	if dump["redis"].(map[string]interface{})["password"] != MaskText {
+		t.Error("password not masked")
+	}
+	var dsn = dump["database"].(map[string]interface{})["master"].(map[string]interface{})["dsn"].(string)
+	if strings.Contains(dsn, "secret") || !strings.HasPrefix(dsn, "root:"+MaskText+"@") {
+		t.Errorf("dsn not masked: %s", dsn)
+	}
+
+	var dumped, _ = json.Marshal(dump)
+	for _, leaked := range []string{"slave-password", ":slave@", "jwt-secret", "session-secret", "token-value"} {
+		if strings.Contains(string(dumped), leaked) {
+			t.Errorf("%s not masked: %s", leaked, dumped)
+		}
+	}
+	// the names only containing the sensitive words are kept
+	for _, kept := range []string{`"allow_credentials":true`, `"header_key":"JWT"`, `"kid":"2020-12"`, `"algorithm":"HS256"`, `"max_idle":10`} {
+		if !strings.Contains(string(dumped), kept) {
+			t.Errorf("%s should not be masked: %s", kept, dumped)
+		}
+	}
+}
+
+func TestConfigurator_Schema(t *testing.T) {
+	var c, err = NewConfigurator("testdata/configurator.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(new(redisConfig))
+
+	var properties = c.Schema()["properties"].(map[string]interface{})["redis"].(map[string]interface{})["properties"].(map[string]interface{})
+	if properties["db"].(map[string]interface{})["type"] != "integer" {
+		t.Error("db should be integer")
+	}
+	if properties["hosts"].(map[string]interface{})["items"].(map[string]interface{})["type"] != "string" {
+		t.Error("hosts should be array of string")
+	}
+	if _, ok := properties["OnClose"]; ok {
+		t.Error("untagged field should be ignored")
+	}
+
+	var sample = c.Sample()
+	if !strings.Contains(sample, "redis:\n  addr: \"\" # string\n") {
+		t.Error(sample)
+	}
+}
@@ -0,0 +1,78 @@
+package configurator
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// MaskText the text used to replace sensitive values in Dump
+const MaskText = "******"
+
+var (
+	// SensitiveKeys the patterns of the sensitive key names, Dump masks the value when the whole key name matches any of them,
+	//  * matches any characters, so that *_token matches access_token but header_key or allow_credentials aren't masked
+	SensitiveKeys = []string{
+		"password", "*_password", "passwd", "secret", "*_secret", "token", "*_token",
+		"key", "private_key", "*_private_key", "api_key", "*_api_key", "access_key", "secret_key", "credentials",
+	}
+
+	// matches the user:password@ part of a connection string, such as dsn or redis url
+	dsnPassword = regexp.MustCompile(`([^:/@\s]+):([^@/\s]+)@`)
+)
+
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range SensitiveKeys {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func mask(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var masked = make(map[string]interface{}, len(v))
+		for k, item := range v {
+			masked[k] = mask(k, item)
+		}
+		return masked
+	case map[interface{}]interface{}:
+		// the maps in the lists are decoded by yaml with interface{} keys
+		var masked = make(map[string]interface{}, len(v))
+		for k, item := range v {
+			var name = fmt.Sprint(k)
+			masked[name] = mask(name, item)
+		}
+		return masked
+	case []interface{}:
+		var masked = make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = mask(key, item)
+		}
+		return masked
+	case nil:
+		return nil
+	case string:
+		if sensitive(key) && v != "" {
+			return MaskText
+		}
+		return dsnPassword.ReplaceAllString(v, "$1:"+MaskText+"@")
+	default:
+		if sensitive(key) {
+			return MaskText
+		}
+		return v
+	}
+}
+
+// Dump returns the merged configuration tree the process is currently running with,
+//  the values of the keys listed in SensitiveKeys and the passwords in connection strings are masked
+func (config *Configurator) Dump() map[string]interface{} {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+	return mask("", config.viper.AllSettings()).(map[string]interface{})
+}
@@ -0,0 +1,245 @@
+package configurator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// IDescribe components whose node is not unmarshalled into the component itself should implement this interface,
+//  Describe returns the structure (or map of structures) the node is unmarshalled into, Schema and Sample read its mapstructure tags,
+//  components that don't implement it are described by themselves
+type IDescribe interface {
+	Describe() interface{}
+}
+
+func describe(impl IConfig) reflect.Value {
+	if d, ok := impl.(IDescribe); ok {
+		return reflect.ValueOf(d.Describe())
+	}
+	return reflect.ValueOf(impl)
+}
+
+// all declared and added nodes
+func (config *Configurator) all() []IConfig {
+	var all = make([]IConfig, 0, len(config.declared)+len(config.nodes))
+	all = append(all, config.declared...)
+	return append(all, config.nodes...)
+}
+
+// field a configurable field of a structure
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// fields get the configurable fields of the structure according to the mapstructure tag,
+//  fields without tag are ignored, unless none of the fields of the structure are tagged (mapstructure will then match by field name)
+func fields(v reflect.Value) []field {
+	var (
+		typ    = v.Type()
+		tagged = false
+		result = make([]field, 0)
+	)
+	for i := 0; i < typ.NumField(); i++ {
+		if _, ok := typ.Field(i).Tag.Lookup("mapstructure"); ok {
+			tagged = true
+			break
+		}
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		var f = typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag, ok := f.Tag.Lookup("mapstructure")
+		if !ok && tagged {
+			continue
+		}
+		var name = strings.Split(tag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(tag, ",squash") {
+			result = append(result, fields(indirect(v.Field(i)))...)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		switch f.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		result = append(result, field{name: name, value: v.Field(i)})
+	}
+	return result
+}
+
+// indirect dereference pointers, nil pointers will get a zero value of the element type
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if v.Kind() == reflect.Interface {
+				return v
+			}
+			v = reflect.Zero(v.Type().Elem())
+			continue
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func typeName(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return ""
+}
+
+func schemaOf(v reflect.Value) map[string]interface{} {
+	v = indirect(v)
+	var schema = make(map[string]interface{})
+	if name := typeName(v); name != "" {
+		schema["type"] = name
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var properties = make(map[string]interface{})
+		for _, f := range fields(v) {
+			properties[f.name] = schemaOf(f.value)
+		}
+		schema["properties"] = properties
+	case reflect.Map:
+		schema["additionalProperties"] = schemaOf(reflect.Zero(v.Type().Elem()))
+	case reflect.Slice, reflect.Array:
+		schema["items"] = schemaOf(reflect.Zero(v.Type().Elem()))
+	}
+	return schema
+}
+
+// Schema generate a JSON Schema (draft-07) of all added nodes from the mapstructure tags of the components,
+//  components sharing the same node will be merged
+func (config *Configurator) Schema() map[string]interface{} {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+
+	var properties = make(map[string]interface{})
+	for _, impl := range config.all() {
+		var schema = schemaOf(describe(impl))
+		exists, ok := properties[impl.Node()].(map[string]interface{})
+		if !ok {
+			properties[impl.Node()] = schema
+			continue
+		}
+		if merged, ok := exists["properties"].(map[string]interface{}); ok {
+			if items, ok := schema["properties"].(map[string]interface{}); ok {
+				for key, item := range items {
+					merged[key] = item
+				}
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// sample write the yaml sample of v to b, scalars are written as zero values and annotated with their type
+func sample(b *strings.Builder, key string, v reflect.Value, depth int) {
+	v = indirect(v)
+	var indent = strings.Repeat("  ", depth)
+	switch v.Kind() {
+	case reflect.Struct:
+		b.WriteString(fmt.Sprintf("%s%s:\n", indent, key))
+		for _, f := range fields(v) {
+			sample(b, f.name, f.value, depth+1)
+		}
+	case reflect.Map:
+		b.WriteString(fmt.Sprintf("%s%s:\n", indent, key))
+		var keys = make([]string, 0)
+		for _, k := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+		}
+		sort.Strings(keys)
+		if len(keys) == 0 {
+			sample(b, "<name>", reflect.Zero(v.Type().Elem()), depth+1)
+		}
+		for _, k := range keys {
+			sample(b, k, reflect.Zero(v.Type().Elem()), depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		var item = indirect(reflect.Zero(v.Type().Elem()))
+		if item.Kind() == reflect.Struct {
+			b.WriteString(fmt.Sprintf("%s%s:\n", indent, key))
+			var sub = new(strings.Builder)
+			sample(sub, "-", item, 0)
+			for i, line := range strings.Split(strings.TrimSuffix(sub.String(), "\n"), "\n")[1:] {
+				var prefix = "  "
+				if i == 0 {
+					prefix = "- "
+				}
+				b.WriteString(fmt.Sprintf("%s  %s%s\n", indent, prefix, strings.TrimPrefix(line, "  ")))
+			}
+			return
+		}
+		b.WriteString(fmt.Sprintf("%s%s: [] # array of %s\n", indent, key, typeName(item)))
+	case reflect.String:
+		b.WriteString(fmt.Sprintf("%s%s: \"\" # string\n", indent, key))
+	case reflect.Interface:
+		b.WriteString(fmt.Sprintf("%s%s: # any\n", indent, key))
+	default:
+		b.WriteString(fmt.Sprintf("%s%s: %v # %s\n", indent, key, reflect.Zero(v.Type()).Interface(), typeName(v)))
+	}
+}
+
+// Sample generate an annotated sample yaml of all added nodes from the mapstructure tags of the components
+func (config *Configurator) Sample() string {
+	config.mu.Lock()
+	defer config.mu.Unlock()
+
+	var (
+		b     = new(strings.Builder)
+		nodes = make(map[string][]reflect.Value)
+		names = make([]string, 0)
+	)
+	for _, impl := range config.all() {
+		if _, ok := nodes[impl.Node()]; !ok {
+			names = append(names, impl.Node())
+		}
+		nodes[impl.Node()] = append(nodes[impl.Node()], describe(impl))
+	}
+
+	for _, name := range names {
+		var body = new(strings.Builder)
+		for _, v := range nodes[name] {
+			var node = new(strings.Builder)
+			sample(node, name, v, 0)
+			var lines = strings.SplitN(node.String(), "\n", 2)
+			if len(lines) == 2 {
+				body.WriteString(lines[1])
+			}
+		}
+		b.WriteString(name + ":\n" + body.String() + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -2,11 +2,14 @@
 package crane
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/kenretto/crane/captcha"
 	"github.com/kenretto/crane/configurator"
 	"github.com/kenretto/crane/database/orm"
+	"github.com/kenretto/crane/events"
 	"github.com/kenretto/crane/logger"
+	"github.com/kenretto/crane/logging"
 	"github.com/kenretto/crane/password"
 	"github.com/kenretto/crane/redis"
 	"github.com/kenretto/crane/server"
@@ -16,7 +19,9 @@ import (
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
 	"log"
+	"os"
 	"sync"
+	"syscall"
 )
 
 type ICrane interface {
@@ -51,6 +56,7 @@ type Crane struct {
 	logger       *logger.Logger
 	password     *password.Password
 	redis        *redis.Redis
+	events       *events.Events
 	server       *server.HTTPServer
 	sessions     *sessions.Sessions
 
@@ -58,6 +64,8 @@ type Crane struct {
 	ServiceName string
 
 	container map[string]configurator.IConfig
+	lifecycle lifecycle
+	done      chan struct{} // closed when Start returns, guarded by mu
 	mu        sync.RWMutex
 }
 
@@ -78,13 +86,43 @@ func (crane *Crane) Name() string {
 	return crane.ServiceName
 }
 
+// Start the entry of the daemon, see Serve, the process exits with status 1 if it fails
 func (crane *Crane) Start() {
+	if err := crane.Serve(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// Serve start the managed components in dependency order, and then start the http server, it returns after the http server
+//  is shut down and the components are stopped, if any component fails to start, the started ones are stopped and the error is returned
+func (crane *Crane) Serve() error {
+	var done = make(chan struct{})
+	crane.mu.Lock()
+	crane.done = done
+	crane.mu.Unlock()
+	defer close(done)
+	if err := crane.lifecycle.start(context.Background()); err != nil {
+		return err
+	}
+
 	crane.Configurator.Add(crane.server)
 	crane.server.Listen()
+
+	ctx, cancel := context.WithTimeout(context.Background(), crane.server.ShutdownDuration())
+	defer cancel()
+	return crane.lifecycle.stop(ctx)
 }
 
+// Stop shut down the http server, and wait for the managed components to be stopped in reverse order
 func (crane *Crane) Stop() error {
 	crane.server.Stop()
+	crane.mu.RLock()
+	var done = crane.done
+	crane.mu.RUnlock()
+	if done != nil {
+		<-done
+	}
 	return nil
 }
 
@@ -104,6 +142,17 @@ func (crane *Crane) Integration(bind configurator.IConfig) {
 	}
 	crane.container[bind.Node()] = bind
 	crane.Configurator.Add(crane.container[bind.Node()])
+	if component, ok := bind.(Lifecycle); ok {
+		crane.lifecycle.register(bind.Node(), component)
+	}
+}
+
+// Manage let the component participate in the lifecycle of Crane, it isn't a method of ICrane so that the implementations
+//  written before it still work, assert interface{ Manage(string, crane.Lifecycle) } to use it through ICrane,
+//  the name is used by other components to declare dependencies, see Dependent, the node name is used for the built-in integrations:
+//  logger, redis, events, database, captcha, sessions
+func (crane *Crane) Manage(name string, component Lifecycle) {
+	crane.lifecycle.register(name, component)
 }
 
 func (crane *Crane) Get(node string) configurator.IConfig {
@@ -116,18 +165,37 @@ func (crane *Crane) Get(node string) configurator.IConfig {
 func (crane *Crane) IntegrationLogger() {
 	crane.logger = new(logger.Logger)
 	crane.Configurator.Add(crane.logger)
+	crane.Manage(crane.logger.Node(), &component{stop: func(ctx context.Context) error {
+		return crane.logger.Close()
+	}})
+}
+
+// module the logger of the module adapted to logging.Logger, its level can be adjusted with logger.Logger.SetModuleLevel
+func (crane *Crane) module(name string) logging.Logger {
+	return logging.NewLogrus(logrus.NewEntry(crane.logger.Module(name)))
 }
 
 // IntegrationCaptcha integration captcha
 func (crane *Crane) IntegrationCaptcha() {
 	crane.captcha = captcha.NewCaptcha()
+	var dependencies = []string{"logger"}
+	if crane.redis != nil {
+		crane.captcha.UseRedis(crane.redis)
+		dependencies = append(dependencies, crane.redis.Node())
+	}
 	crane.Configurator.Add(crane.captcha)
+	crane.Manage(crane.captcha.Node(), &component{dependencies: dependencies, stop: func(ctx context.Context) error {
+		return crane.captcha.Close()
+	}})
 }
 
 // IntegrationORM integration gorm
 func (crane *Crane) IntegrationORM() {
-	crane.orm = orm.NewORM(logrus.NewEntry(crane.logger.Instance()))
+	crane.orm = orm.NewORM(crane.module("orm"))
 	crane.Configurator.Add(crane.orm)
+	crane.Manage(crane.orm.Node(), &component{dependencies: []string{"logger"}, stop: func(ctx context.Context) error {
+		return crane.orm.Close()
+	}})
 }
 
 // IntegrationPassword integration password
@@ -139,18 +207,46 @@ func (crane *Crane) IntegrationPassword() {
 // IntegrationRedis integration redis
 func (crane *Crane) IntegrationRedis() {
 	crane.redis = new(redis.Redis)
+	crane.redis.SetLogger(crane.module("redis"))
 	crane.Configurator.Add(crane.redis)
+	crane.Manage(crane.redis.Node(), &component{dependencies: []string{"logger"}, stop: func(ctx context.Context) error {
+		return crane.redis.Close()
+	}})
+}
+
+// IntegrationEvents integration the event bus, the redis driver shares the connections of the redis node
+func (crane *Crane) IntegrationEvents() {
+	crane.events = events.NewEvents(crane.module("events"))
+	var dependencies = []string{"logger"}
+	if crane.redis != nil {
+		crane.events.UseRedis(crane.redis)
+		dependencies = append(dependencies, crane.redis.Node())
+	}
+	crane.Configurator.Add(crane.events)
+	crane.Manage(crane.events.Node(), &component{dependencies: dependencies, stop: func(ctx context.Context) error {
+		return crane.events.Close()
+	}})
 }
 
 // IntegrationSession integration session
 func (crane *Crane) IntegrationSession() {
 	crane.sessions = new(sessions.Sessions)
+	crane.sessions.UseDatabase(crane.ORM)
+	var dependencies = []string{"logger"}
+	if crane.redis != nil {
+		crane.sessions.UseRedis(crane.redis)
+		dependencies = append(dependencies, crane.redis.Node())
+	}
 	crane.Configurator.Add(crane.sessions)
+	crane.Manage(crane.sessions.Node(), &component{dependencies: dependencies, stop: func(ctx context.Context) error {
+		return crane.sessions.Close()
+	}})
 }
 
 // IntegrationHTTPServer integration http server
 func (crane *Crane) IntegrationHTTPServer() {
-	crane.server = server.NewHTTPServer(&Logger{logrus.NewEntry(crane.logger.Instance())})
+	crane.server = server.NewHTTPServer(crane.module("server"))
+	crane.Configurator.Declare(crane.server)
 }
 
 func (crane *Crane) WithConfigurator(config string) error {
@@ -160,6 +256,19 @@ func (crane *Crane) WithConfigurator(config string) error {
 	return err
 }
 
+// process the daemon process of Crane, SIGTERM is handled the same as interrupt, so that the components can be stopped gracefully
+func (crane *Crane) process() *daemon.Process {
+	var process = daemon.NewProcess(crane)
+	process.On(syscall.SIGTERM, func() {
+		if err := crane.Stop(); err != nil {
+			log.Println(err)
+		}
+		process.Pid.Remove()
+		os.Exit(0)
+	})
+	return process
+}
+
 // NewCrane 子目录的每个库其实都是可以单独使用的, 如果想要整个依赖, 建议使用这个方法来初始化
 func NewCrane(config string) (crane ICrane, err error) {
 	crane = new(Crane)
@@ -169,13 +278,14 @@ func NewCrane(config string) (crane ICrane, err error) {
 	}
 
 	crane.IntegrationLogger()
-	crane.IntegrationCaptcha()
 	crane.IntegrationRedis()
+	crane.(*Crane).IntegrationEvents()
+	crane.IntegrationCaptcha()
 	crane.IntegrationORM()
 	crane.IntegrationPassword()
 	crane.IntegrationSession()
 	crane.IntegrationHTTPServer()
-	daemon.Register(daemon.NewProcess(crane.(*Crane)))
+	daemon.Register(crane.(*Crane).process())
 	return
 }
 
@@ -186,21 +296,25 @@ func NewCraneWithCustom(config string, crane ICrane) error {
 	}
 
 	crane.IntegrationLogger()
-	crane.IntegrationCaptcha()
 	crane.IntegrationRedis()
+	// IntegrationEvents isn't a method of ICrane, so that the implementations written before it still work
+	if c, ok := crane.(interface{ IntegrationEvents() }); ok {
+		c.IntegrationEvents()
+	}
+	crane.IntegrationCaptcha()
 	crane.IntegrationORM()
 	crane.IntegrationPassword()
 	crane.IntegrationSession()
 	crane.IntegrationHTTPServer()
 	if c, ok := crane.(*Crane); ok {
-		daemon.Register(daemon.NewProcess(c))
+		daemon.Register(c.process())
 	}
 	return nil
 }
 
 // Captcha 获取验证码操作
 func (crane *Crane) Captcha() *captcha.Captcha {
-	return crane.captcha.Instance().WithLogger(crane.logger.Instance())
+	return crane.captcha.Instance().WithLogger(crane.module("captcha"))
 }
 
 // ORM 获取 gorm 的操作
@@ -213,6 +327,11 @@ func (crane *Crane) Logger() *logrus.Logger {
 	return crane.logger.Instance()
 }
 
+// LoggerLoader 获取 logger 组件, 用于调整各模块的日志级别或注册 logger.AdminRoutes
+func (crane *Crane) LoggerLoader() *logger.Logger {
+	return crane.logger
+}
+
 // Handler set handler
 func (crane *Crane) Handler(handler func(router *gin.Engine)) {
 	crane.server.Handler(handler)
@@ -240,6 +359,11 @@ func (crane *Crane) Redis() *redis.Redis {
 	return crane.redis
 }
 
+// Events get the event bus
+func (crane *Crane) Events() *events.Events {
+	return crane.events
+}
+
 // Password get password
 func (crane *Crane) Password() *password.Password {
 	return crane.password
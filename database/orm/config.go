@@ -1,7 +1,7 @@
 package orm
 
 import (
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -27,7 +27,7 @@ type Node struct {
 type Loader struct {
 	nodes map[string]Node
 
-	logger *logrus.Entry
+	logger logging.Logger
 	choice string
 	rw     sync.RWMutex
 	conns  map[string]*gorm.DB
@@ -37,8 +37,13 @@ func (loader *Loader) Node() string {
 	return "database"
 }
 
+// Describe each child node of database is a connection, the default connection is master
+func (loader *Loader) Describe() interface{} {
+	return map[string]Node{"master": {}}
+}
+
 // NewORM new orm
-func NewORM(logger *logrus.Entry) *Loader {
+func NewORM(logger logging.Logger) *Loader {
 	loader := new(Loader)
 	loader.logger = logger
 	return loader
@@ -99,6 +104,24 @@ func (loader *Loader) newInstance() {
 	loader.conns = conns
 }
 
+// Close close all database connections
+func (loader *Loader) Close() error {
+	loader.rw.Lock()
+	defer loader.rw.Unlock()
+	var first error
+	for _, conn := range loader.conns {
+		db, err := conn.DB()
+		if err == nil {
+			err = db.Close()
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	loader.conns = nil
+	return first
+}
+
 // DB get *gorm.DB  If the DB parameter is passed in, the connection of the specified configuration node in the configuration file will be obtained. Otherwise, the connection of the default master node will be taken
 func (loader *Loader) DB(db ...string) *gorm.DB {
 	loader.rw.RLock()
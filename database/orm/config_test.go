@@ -2,7 +2,7 @@ package orm
 
 import (
 	"github.com/kenretto/crane/configurator"
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 	"testing"
 )
 
@@ -16,7 +16,7 @@ func (area) TableName() string {
 }
 
 func TestLoader_DB(t *testing.T) {
-	var loader = NewORM(logrus.NewEntry(logrus.New()))
+	var loader = NewORM(logging.Default())
 	var c, err = configurator.NewConfigurator("testdata/database.yaml")
 	if err != nil {
 		t.Error(err)
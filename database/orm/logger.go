@@ -2,7 +2,7 @@ package orm
 
 import (
 	"context"
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/utils"
 	"time"
@@ -16,7 +16,7 @@ var logLevel = map[string]logger.LogLevel{
 }
 
 type iLogger struct {
-	logger        *logrus.Entry
+	logger        logging.Logger
 	level         logger.LogLevel
 	SlowThreshold time.Duration
 }
@@ -29,31 +29,31 @@ func (log *iLogger) LogMode(level logger.LogLevel) logger.Interface {
 	return &newLogger
 }
 
-func (log *iLogger) Info(_ context.Context, msg string, data ...interface{}) {
+func (log *iLogger) Info(ctx context.Context, msg string, data ...interface{}) {
 	if log.level >= logger.Info {
-		log.logger.Info(msg, data)
+		log.logger.WithContext(ctx).Info(msg, data)
 	}
 }
 
-func (log *iLogger) Warn(_ context.Context, msg string, data ...interface{}) {
+func (log *iLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
 	if log.level >= logger.Warn {
-		log.logger.Warn(msg, data)
+		log.logger.WithContext(ctx).Warn(msg, data)
 	}
 }
 
-func (log *iLogger) Error(_ context.Context, msg string, data ...interface{}) {
+func (log *iLogger) Error(ctx context.Context, msg string, data ...interface{}) {
 	if log.level >= logger.Error {
-		log.logger.Error(msg, data)
+		log.logger.WithContext(ctx).Error(msg, data)
 	}
 }
 
-func (log *iLogger) Trace(_ context.Context, begin time.Time, fc func() (string, int64), err error) {
+func (log *iLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
 	if log.level > 0 {
 		elapsed := time.Since(begin)
 		switch {
 		case err != nil && log.level >= logger.Error:
 			sql, rows := fc()
-			log.logger.WithFields(logrus.Fields{
+			log.logger.WithContext(ctx).WithFields(logging.Fields{
 				"exec_file":     utils.FileWithLineNum(),
 				"rows_affected": rows,
 				"error":         err,
@@ -62,7 +62,7 @@ func (log *iLogger) Trace(_ context.Context, begin time.Time, fc func() (string,
 			}).Error()
 		case elapsed > log.SlowThreshold && log.SlowThreshold != 0 && log.level >= logger.Warn:
 			sql, rows := fc()
-			log.logger.WithFields(logrus.Fields{
+			log.logger.WithContext(ctx).WithFields(logging.Fields{
 				"exec_file":     utils.FileWithLineNum(),
 				"rows_affected": rows,
 				"error":         err,
@@ -71,7 +71,7 @@ func (log *iLogger) Trace(_ context.Context, begin time.Time, fc func() (string,
 			}).Warn()
 		case log.level >= logger.Info:
 			sql, rows := fc()
-			log.logger.WithFields(logrus.Fields{
+			log.logger.WithContext(ctx).WithFields(logging.Fields{
 				"exec_file":     utils.FileWithLineNum(),
 				"rows_affected": rows,
 				"error":         err,
@@ -0,0 +1,97 @@
+// Package events the event bus broadcasting the events between the instances, such as the cache invalidations and the config changes,
+//  RedisBus publishes through redis pub/sub, MemoryBus dispatches in the process for the tests and the single node deployments,
+//  Topic adds the typed payloads on top of either of them
+package events
+
+import (
+	"context"
+	"fmt"
+	"github.com/kenretto/crane/logging"
+	"sync"
+)
+
+// Handler handles the payload of an event
+type Handler func(ctx context.Context, payload []byte)
+
+// Bus publishes the events to the handlers subscribed to the topic
+type Bus interface {
+	// Publish publish the payload to the topic
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe add the handler of the topic, the returned function removes it
+	Subscribe(topic string, handler Handler) (unsubscribe func())
+	// Close release the connections of the bus
+	Close() error
+}
+
+// registry the handlers of the topics, shared by the buses of Events so that the subscriptions survive the reloads
+type registry struct {
+	logger   logging.Logger
+	mu       sync.RWMutex
+	next     int
+	handlers map[string]map[int]Handler
+}
+
+func newRegistry(logger logging.Logger) *registry {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &registry{logger: logger, handlers: make(map[string]map[int]Handler)}
+}
+
+// add add the handler, first is true if it's the first handler of the topic
+func (r *registry) add(topic string, handler Handler) (id int, first bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	if r.handlers[topic] == nil {
+		r.handlers[topic] = make(map[int]Handler)
+		first = true
+	}
+	r.handlers[topic][r.next] = handler
+	return r.next, first
+}
+
+// remove remove the handler, last is true if the topic has no handler anymore
+func (r *registry) remove(topic string, id int) (last bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.handlers[topic][id]; !ok {
+		return false
+	}
+	delete(r.handlers[topic], id)
+	if len(r.handlers[topic]) == 0 {
+		delete(r.handlers, topic)
+		return true
+	}
+	return false
+}
+
+func (r *registry) topics() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var topics = make([]string, 0, len(r.handlers))
+	for topic := range r.handlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// dispatch call the handlers of the topic, a panicking handler is logged and doesn't affect the others
+func (r *registry) dispatch(ctx context.Context, topic string, payload []byte) {
+	r.mu.RLock()
+	var handlers = make([]Handler, 0, len(r.handlers[topic]))
+	for _, handler := range r.handlers[topic] {
+		handlers = append(handlers, handler)
+	}
+	r.mu.RUnlock()
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					r.logger.WithFields(logging.Fields{"topic": topic}).Error(fmt.Sprintf("events: handler panicked: %v", err))
+				}
+			}()
+			handler(ctx, payload)
+		}()
+	}
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/kenretto/crane/logging"
+	credis "github.com/kenretto/crane/redis"
+	"github.com/spf13/viper"
+	"sync"
+)
+
+// Events the event bus loaded from the events node, the subscriptions are kept when the node or the redis connection is reloaded
+//  events:
+//    driver: redis # memory or redis
+//    connection: default # the connection of the redis node
+//    prefix: "events:" # the prefix of the redis channels
+type Events struct {
+	Driver     string `mapstructure:"driver"`
+	Connection string `mapstructure:"connection"`
+	Prefix     string `mapstructure:"prefix"`
+
+	registry *registry
+	shared   *credis.Redis
+	bus      Bus
+	mu       sync.RWMutex
+}
+
+// NewEvents logger logs the panics of the handlers, nil uses logging.Default
+func NewEvents(logger logging.Logger) *Events {
+	return &Events{registry: newRegistry(logger)}
+}
+
+func (e *Events) Node() string {
+	return "events"
+}
+
+// UseRedis share the connections of r with the redis driver, call it before the events are added to the configurator
+func (e *Events) UseRedis(r *credis.Redis) {
+	e.mu.Lock()
+	e.shared = r
+	e.mu.Unlock()
+	r.OnReload(func(name string, client redis.Cmdable) {
+		if bus, ok := e.current().(*RedisBus); ok {
+			bus.reload(name, client)
+		}
+	})
+}
+
+// OnChange the bus is recreated, the handlers are subscribed on the new bus, the missing node uses the memory driver,
+//  so does the redis driver without UseRedis, the error is logged
+func (e *Events) OnChange(viper *viper.Viper) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Driver, e.Connection, e.Prefix = "", "", ""
+	if viper != nil {
+		_ = viper.Unmarshal(e)
+	}
+	var old = e.bus
+	switch e.Driver {
+	case "redis":
+		if e.shared != nil {
+			e.bus = newRedisBus(e.shared, e.Connection, e.Prefix, e.registry)
+			break
+		}
+		e.registry.logger.Error("events: the redis driver requires UseRedis, the memory driver is used instead")
+		fallthrough
+	default:
+		e.bus = &MemoryBus{registry: e.registry}
+	}
+	if old != nil {
+		_ = old.Close()
+	}
+}
+
+func (e *Events) current() Bus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.bus
+}
+
+func (e *Events) Publish(ctx context.Context, topic string, payload []byte) error {
+	return e.current().Publish(ctx, topic, payload)
+}
+
+// Subscribe the handler is kept across the reloads
+func (e *Events) Subscribe(topic string, handler Handler) func() {
+	id, first := e.registry.add(topic, handler)
+	if bus, ok := e.current().(*RedisBus); ok && first {
+		bus.subscribe(topic)
+	}
+	return func() {
+		if bus, ok := e.current().(*RedisBus); e.registry.remove(topic, id) && ok {
+			bus.unsubscribe(topic)
+		}
+	}
+}
+
+func (e *Events) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.bus == nil {
+		return nil
+	}
+	return e.bus.Close()
+}
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"github.com/kenretto/crane/codec"
+	"github.com/kenretto/crane/redis"
+	"github.com/spf13/viper"
+	"sync"
+	"testing"
+	"time"
+)
+
+type invalidation struct {
+	Keys []string
+}
+
+func TestTopic(t *testing.T) {
+	var ctx = context.Background()
+	for name, cdc := range map[string]codec.Codec{"json": nil, "gob": codec.GobCodec{}} {
+		var bus = NewMemoryBus(nil)
+		var topic = NewTopic[invalidation](bus, "cache.invalidate", cdc)
+		var received []invalidation
+		var unsubscribe = topic.Subscribe(func(ctx context.Context, event invalidation) {
+			received = append(received, event)
+		})
+		// a panicking handler doesn't affect the others
+		bus.Subscribe(topic.Name(), func(ctx context.Context, payload []byte) {
+			panic("handler")
+		})
+		// the payloads that can't be decoded are dropped
+		_ = bus.Publish(ctx, topic.Name(), []byte("invalid"))
+		if err := topic.Publish(ctx, invalidation{Keys: []string{"user:1"}}); err != nil {
+			t.Fatal(name, err)
+		}
+		unsubscribe()
+		_ = topic.Publish(ctx, invalidation{Keys: []string{"user:2"}})
+		if len(received) != 1 || received[0].Keys[0] != "user:1" {
+			t.Errorf("%s: unexpected events %v", name, received)
+		}
+	}
+}
+
+func TestEvents_OnChange(t *testing.T) {
+	var ctx = context.Background()
+	var e = NewEvents(nil)
+	e.OnChange(nil)
+	var received int
+	e.Subscribe("config.changed", func(ctx context.Context, payload []byte) {
+		received++
+	})
+	_ = e.Publish(ctx, "config.changed", nil)
+
+	// the subscriptions are kept after the reload
+	var config = viper.New()
+	config.Set("driver", "memory")
+	e.OnChange(config)
+	_ = e.Publish(ctx, "config.changed", nil)
+	if received != 2 {
+		t.Errorf("expected 2 events, got %d", received)
+	}
+
+	// the redis driver without UseRedis falls back to the memory driver
+	config.Set("driver", "redis")
+	e.OnChange(config)
+	if _, ok := e.current().(*MemoryBus); !ok {
+		t.Errorf("expected the memory bus, got %T", e.current())
+	}
+	_ = e.Publish(ctx, "config.changed", nil)
+	if received != 3 {
+		t.Errorf("expected 3 events, got %d", received)
+	}
+}
+
+func TestRedisBus(t *testing.T) {
+	var ctx = context.Background()
+	var r = new(redis.Redis)
+	var config = viper.New()
+	config.Set("redis_type", "default")
+	config.Set("addr", "127.0.0.1:6379")
+	r.OnChange(config)
+	if err := r.Instance().Ping(ctx).Err(); err != nil {
+		t.Skip("redis isn't available:", err)
+	}
+
+	var bus = NewRedisBus(r, "", "test_events:", nil)
+	defer bus.Close()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	bus.Subscribe("ping", func(ctx context.Context, payload []byte) {
+		wg.Done()
+	})
+	time.Sleep(100 * time.Millisecond)
+	_ = bus.Publish(ctx, "ping", []byte("1"))
+
+	// the topics are subscribed again on the reloaded connection
+	config.Set("db", 1)
+	r.OnChange(config)
+	time.Sleep(100 * time.Millisecond)
+	_ = bus.Publish(ctx, "ping", []byte("2"))
+
+	var done = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the events weren't received")
+	}
+}
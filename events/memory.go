@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+	"github.com/kenretto/crane/logging"
+)
+
+// MemoryBus dispatches the events in the process, Publish returns after the handlers return
+type MemoryBus struct {
+	registry *registry
+}
+
+// NewMemoryBus logger logs the panics of the handlers, nil uses logging.Default
+func NewMemoryBus(logger logging.Logger) *MemoryBus {
+	return &MemoryBus{registry: newRegistry(logger)}
+}
+
+func (bus *MemoryBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	bus.registry.dispatch(ctx, topic, append([]byte(nil), payload...))
+	return nil
+}
+
+func (bus *MemoryBus) Subscribe(topic string, handler Handler) func() {
+	id, _ := bus.registry.add(topic, handler)
+	return func() {
+		bus.registry.remove(topic, id)
+	}
+}
+
+func (bus *MemoryBus) Close() error {
+	return nil
+}
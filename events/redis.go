@@ -0,0 +1,156 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/kenretto/crane/logging"
+	credis "github.com/kenretto/crane/redis"
+	"strings"
+	"sync"
+)
+
+// subscriber the clients supporting pub/sub, redis.Cmdable doesn't include Subscribe
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// RedisBus publishes the events through redis pub/sub, every instance subscribed to the topic receives the event, including the publisher,
+//  go-redis reconnects and resubscribes after the connection is lost, and the topics are subscribed again on the new client
+//  after the connection is reloaded, the events published in between are lost, so don't use it as a queue
+type RedisBus struct {
+	redis      *credis.Redis
+	connection string
+	prefix     string
+	registry   *registry
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	closed bool
+}
+
+// NewRedisBus connection is the connection of the redis node, default default, prefix is the prefix of the channels, default events:
+func NewRedisBus(r *credis.Redis, connection, prefix string, logger logging.Logger) *RedisBus {
+	var bus = newRedisBus(r, connection, prefix, newRegistry(logger))
+	r.OnReload(bus.reload)
+	return bus
+}
+
+func newRedisBus(r *credis.Redis, connection, prefix string, registry *registry) *RedisBus {
+	if connection == "" {
+		connection = credis.DefaultConnection
+	}
+	if prefix == "" {
+		prefix = "events:"
+	}
+	var bus = &RedisBus{redis: r, connection: connection, prefix: prefix, registry: registry}
+	bus.mu.Lock()
+	bus.start(r.Connection(connection))
+	bus.mu.Unlock()
+	return bus
+}
+
+func (bus *RedisBus) channels(topics []string) []string {
+	var channels = make([]string, len(topics))
+	for i, topic := range topics {
+		channels[i] = bus.prefix + topic
+	}
+	return channels
+}
+
+// start subscribe the topics of the registry on the client, the pub/sub connection is created with the first topic
+func (bus *RedisBus) start(client redis.Cmdable) {
+	var topics = bus.registry.topics()
+	if client == nil || len(topics) == 0 {
+		return
+	}
+	s, ok := client.(subscriber)
+	if !ok {
+		bus.registry.logger.Error("events: the redis connection " + bus.connection + " doesn't support pub/sub")
+		return
+	}
+	bus.pubsub = s.Subscribe(context.Background(), bus.channels(topics)...)
+	go bus.receive(bus.pubsub)
+}
+
+// receive dispatch the messages until the pub/sub is closed
+func (bus *RedisBus) receive(pubsub *redis.PubSub) {
+	for message := range pubsub.Channel() {
+		bus.registry.dispatch(context.Background(), strings.TrimPrefix(message.Channel, bus.prefix), []byte(message.Payload))
+	}
+}
+
+// reload subscribe the topics on the new client of the connection
+func (bus *RedisBus) reload(name string, client redis.Cmdable) {
+	if name != bus.connection {
+		return
+	}
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return
+	}
+	if bus.pubsub != nil {
+		_ = bus.pubsub.Close()
+		bus.pubsub = nil
+	}
+	bus.start(client)
+}
+
+// subscribe subscribe the channel of the topic, called after the first handler of the topic is added
+func (bus *RedisBus) subscribe(topic string) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return
+	}
+	if bus.pubsub == nil {
+		bus.start(bus.redis.Connection(bus.connection))
+		return
+	}
+	if err := bus.pubsub.Subscribe(context.Background(), bus.prefix+topic); err != nil {
+		bus.registry.logger.WithFields(logging.Fields{"topic": topic}).Error(err)
+	}
+}
+
+// unsubscribe unsubscribe the channel of the topic, called after the last handler of the topic is removed
+func (bus *RedisBus) unsubscribe(topic string) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.pubsub != nil {
+		_ = bus.pubsub.Unsubscribe(context.Background(), bus.prefix+topic)
+	}
+}
+
+func (bus *RedisBus) Publish(ctx context.Context, topic string, payload []byte) error {
+	var client = bus.redis.Connection(bus.connection)
+	if client == nil {
+		return errors.New("events: the redis connection " + bus.connection + " isn't configured")
+	}
+	return client.Publish(ctx, bus.prefix+topic, payload).Err()
+}
+
+func (bus *RedisBus) Subscribe(topic string, handler Handler) func() {
+	id, first := bus.registry.add(topic, handler)
+	if first {
+		bus.subscribe(topic)
+	}
+	return func() {
+		if bus.registry.remove(topic, id) {
+			bus.unsubscribe(topic)
+		}
+	}
+}
+
+// Close close the pub/sub connection, the handlers aren't called anymore
+func (bus *RedisBus) Close() error {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.closed = true
+	if bus.pubsub == nil {
+		return nil
+	}
+	var err = bus.pubsub.Close()
+	bus.pubsub = nil
+	return err
+}
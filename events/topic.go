@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"github.com/kenretto/crane/codec"
+	"github.com/kenretto/crane/logging"
+)
+
+// Topic a topic with the payloads of T
+//  type Invalidation struct{ Keys []string }
+//  var invalidations = events.NewTopic[Invalidation](crane.Events(), "cache.invalidate", nil)
+//  invalidations.Subscribe(func(ctx context.Context, event Invalidation) { ... })
+//  _ = invalidations.Publish(ctx, Invalidation{Keys: []string{"user:1"}})
+type Topic[T any] struct {
+	bus   Bus
+	name  string
+	codec codec.Codec
+}
+
+// NewTopic cdc nil uses codec.JSONCodec, readable by the subscribers in the other languages,
+//  the publishers and the subscribers of a topic must use the same codec
+func NewTopic[T any](bus Bus, name string, cdc codec.Codec) *Topic[T] {
+	if cdc == nil {
+		cdc = codec.JSONCodec{}
+	}
+	return &Topic[T]{bus: bus, name: name, codec: cdc}
+}
+
+// Name the name of the topic
+func (topic *Topic[T]) Name() string {
+	return topic.name
+}
+
+// Publish publish the event
+func (topic *Topic[T]) Publish(ctx context.Context, event T) error {
+	payload, err := topic.codec.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return topic.bus.Publish(ctx, topic.name, payload)
+}
+
+// Subscribe add the handler, the payloads that can't be decoded are logged and dropped, the returned function removes it
+func (topic *Topic[T]) Subscribe(handler func(ctx context.Context, event T)) (unsubscribe func()) {
+	return topic.bus.Subscribe(topic.name, func(ctx context.Context, payload []byte) {
+		var event T
+		if err := topic.codec.Unmarshal(payload, &event); err != nil {
+			logging.Default().WithFields(logging.Fields{"topic": topic.name}).Warn(err)
+			return
+		}
+		handler(ctx, event)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/go-playground/locales/zh"
 	"github.com/kenretto/crane"
 	"github.com/kenretto/crane/i18n"
+	"github.com/kenretto/crane/middleware"
 	"github.com/kenretto/crane/response"
 	"github.com/kenretto/crane/validator"
 	"golang.org/x/text/language"
@@ -15,6 +16,7 @@ import (
 var (
 	validate = validator.New(validator.Translator{Translator: en.New()}, validator.Translator{Translator: zh.New()})
 	pilot    crane.ICrane
+	limiter  *middleware.RateLimit
 	err      error
 )
 
@@ -23,6 +25,9 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	// the rate_limit node is integrated once, the router only uses it when the server is rebuilt
+	limiter = middleware.NewRateLimit(pilot.Redis())
+	pilot.Integration(limiter)
 	response.SetTranslator(i18n.NewBundle(language.Chinese).LoadFiles("locales/", "yaml", yaml.Unmarshal))
 	binding.Validator = validate
 }
@@ -34,3 +39,7 @@ func Pilot() crane.ICrane {
 func Validator() *validator.Validator {
 	return validate
 }
+
+func Limiter() *middleware.RateLimit {
+	return limiter
+}
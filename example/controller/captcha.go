@@ -16,7 +16,8 @@ func CaptchaVerify(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "false")
 		return
 	}
-	if bootstrap.Pilot().Captcha().Verify(sessions.Get(ctx, "captcha_id"), ctx.Query("answer"), b) {
+	var id, _ = sessions.Get[string](ctx, "captcha_id")
+	if bootstrap.Pilot().Captcha().Verify(id, ctx.Query("answer"), b) {
 		ctx.String(http.StatusOK, "ok")
 		return
 	}
@@ -28,7 +29,9 @@ func CaptchaImg(ctx *gin.Context) {
 	if err != nil {
 		bootstrap.Pilot().Logger().Error(err)
 	}
-	sessions.Set(ctx, "captcha_id", id)
+	if err = sessions.Set(ctx, "captcha_id", id); err != nil {
+		bootstrap.Pilot().Logger().Error(err)
+	}
 	ctx.Header("Content-Type", "image/png")
 	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(b64s, "data:image/png;base64,", ""))
 	if err != nil {
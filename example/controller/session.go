@@ -7,15 +7,22 @@ import (
 )
 
 func SessionSet(ctx *gin.Context) {
-	sessions.Set(ctx, "hello", ctx.Query("content"))
+	if err := sessions.Set(ctx, "hello", ctx.Query("content")); err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
 	ctx.String(http.StatusOK, "set success")
 }
 
 func SessionGet(ctx *gin.Context) {
-	ctx.String(http.StatusOK, sessions.Get(ctx, "hello"))
+	var hello, _ = sessions.Get[string](ctx, "hello")
+	ctx.String(http.StatusOK, hello)
 }
 
 func SessionDel(ctx *gin.Context) {
-	sessions.Del(ctx, "hello")
+	if err := sessions.Del(ctx, "hello"); err != nil {
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
 	ctx.String(http.StatusOK, "del success")
 }
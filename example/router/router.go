@@ -17,7 +17,7 @@ func Router(router *gin.Engine) {
 	crud.Register(bootstrap.Pilot().ORM(), bootstrap.Validator(), model.Member{}, crudman.SetRoute("/member"))
 	router.Any("/crud/*any", crud.Controller)
 
-	router.POST("member/register", member.Register)
+	router.POST("member/register", bootstrap.Limiter().Handler("login"), member.Register)
 	//router.GET("/tmp", func(ctx *gin.Context) {
 	//	ctx.HTML(http.StatusOK, "index", gin.H{
 	//		"links": gin.H{
@@ -0,0 +1,161 @@
+package crane
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Lifecycle components managed by Crane, they are initialized and started in dependency order before the http server listens,
+//  and stopped in reverse order after the http server is shut down
+type Lifecycle interface {
+	Init(ctx context.Context) error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Dependent components implementing Lifecycle can declare the components they depend on, returns their names (the node name for integrations)
+type Dependent interface {
+	DependsOn() []string
+}
+
+// component adapts the built-in integrations to Lifecycle, nil functions are skipped
+type component struct {
+	init, start, stop func(ctx context.Context) error
+	dependencies      []string
+}
+
+func (c *component) Init(ctx context.Context) error {
+	if c.init == nil {
+		return nil
+	}
+	return c.init(ctx)
+}
+
+func (c *component) Start(ctx context.Context) error {
+	if c.start == nil {
+		return nil
+	}
+	return c.start(ctx)
+}
+
+func (c *component) Stop(ctx context.Context) error {
+	if c.stop == nil {
+		return nil
+	}
+	return c.stop(ctx)
+}
+
+func (c *component) DependsOn() []string {
+	return c.dependencies
+}
+
+// lifecycle component registry
+type lifecycle struct {
+	mu         sync.Mutex
+	names      []string
+	components map[string]Lifecycle
+	started    []string
+}
+
+// register register or replace a component, the registration order is kept for components without dependencies between them
+func (l *lifecycle) register(name string, c Lifecycle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.components == nil {
+		l.components = make(map[string]Lifecycle)
+	}
+	if _, ok := l.components[name]; !ok {
+		l.names = append(l.names, name)
+	}
+	l.components[name] = c
+}
+
+func dependencies(c Lifecycle) []string {
+	if d, ok := c.(Dependent); ok {
+		return d.DependsOn()
+	}
+	return nil
+}
+
+// order topological sort of the components
+func (l *lifecycle) order() ([]string, error) {
+	var (
+		sorted   = make([]string, 0, len(l.names))
+		visited  = make(map[string]bool)
+		visiting = make(map[string]bool)
+		visit    func(name string, parent string) error
+	)
+	visit = func(name string, parent string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("crane: circular dependency between %s and %s", parent, name)
+		}
+		c, ok := l.components[name]
+		if !ok {
+			return fmt.Errorf("crane: %s depends on %s, but it is not registered", parent, name)
+		}
+		visiting[name] = true
+		for _, dependency := range dependencies(c) {
+			if err := visit(dependency, name); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+		sorted = append(sorted, name)
+		return nil
+	}
+
+	for _, name := range l.names {
+		if err := visit(name, ""); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// start init all components and then start them in dependency order, if any of them fails, the started ones will be stopped
+func (l *lifecycle) start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sorted, err := l.order()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sorted {
+		if err := l.components[name].Init(ctx); err != nil {
+			return fmt.Errorf("crane: init %s: %w", name, err)
+		}
+	}
+
+	for _, name := range sorted {
+		if err := l.components[name].Start(ctx); err != nil {
+			_ = l.stopStarted(ctx)
+			return fmt.Errorf("crane: start %s: %w", name, err)
+		}
+		l.started = append(l.started, name)
+	}
+	return nil
+}
+
+// stop stop the started components in reverse order
+func (l *lifecycle) stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stopStarted(ctx)
+}
+
+func (l *lifecycle) stopStarted(ctx context.Context) error {
+	var first error
+	for i := len(l.started) - 1; i >= 0; i-- {
+		if err := l.components[l.started[i]].Stop(ctx); err != nil && first == nil {
+			first = fmt.Errorf("crane: stop %s: %w", l.started[i], err)
+		}
+	}
+	l.started = nil
+	return first
+}
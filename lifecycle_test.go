@@ -0,0 +1,110 @@
+package crane
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorder records the calls of the components
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) component(name string, failStart bool, dependencies ...string) *component {
+	return &component{
+		init: func(ctx context.Context) error {
+			r.calls = append(r.calls, "init "+name)
+			return nil
+		},
+		start: func(ctx context.Context) error {
+			r.calls = append(r.calls, "start "+name)
+			if failStart {
+				return errors.New("failed")
+			}
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			r.calls = append(r.calls, "stop "+name)
+			return nil
+		},
+		dependencies: dependencies,
+	}
+}
+
+func TestLifecycle_Order(t *testing.T) {
+	var r = new(recorder)
+	var l lifecycle
+	l.register("sessions", r.component("sessions", false, "redis", "database"))
+	l.register("redis", r.component("redis", false, "logger"))
+	l.register("database", r.component("database", false, "logger"))
+	l.register("logger", r.component("logger", false))
+
+	var ctx = context.Background()
+	if err := l.start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var expected = []string{
+		"init logger", "init redis", "init database", "init sessions",
+		"start logger", "start redis", "start database", "start sessions",
+		"stop sessions", "stop database", "stop redis", "stop logger",
+	}
+	if !reflect.DeepEqual(r.calls, expected) {
+		t.Errorf("unexpected calls %v", r.calls)
+	}
+}
+
+func TestLifecycle_Cycle(t *testing.T) {
+	var r = new(recorder)
+	var l lifecycle
+	l.register("a", r.component("a", false, "b"))
+	l.register("b", r.component("b", false, "c"))
+	l.register("c", r.component("c", false, "a"))
+
+	var err = l.start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "circular dependency") {
+		t.Fatalf("expect the circular dependency, got %v", err)
+	}
+	if len(r.calls) != 0 {
+		t.Errorf("nothing should be called, got %v", r.calls)
+	}
+
+	l = lifecycle{}
+	l.register("a", r.component("a", false, "missing"))
+	if err = l.start(context.Background()); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("expect the missing dependency, got %v", err)
+	}
+}
+
+func TestLifecycle_Rollback(t *testing.T) {
+	var r = new(recorder)
+	var l lifecycle
+	l.register("logger", r.component("logger", false))
+	l.register("redis", r.component("redis", false, "logger"))
+	l.register("database", r.component("database", true, "redis"))
+	l.register("sessions", r.component("sessions", false, "database"))
+
+	var ctx = context.Background()
+	if err := l.start(ctx); err == nil || !strings.Contains(err.Error(), "start database") {
+		t.Fatalf("expect the start of database to fail, got %v", err)
+	}
+	var expected = []string{
+		"init logger", "init redis", "init database", "init sessions",
+		"start logger", "start redis", "start database",
+		"stop redis", "stop logger",
+	}
+	if !reflect.DeepEqual(r.calls, expected) {
+		t.Errorf("unexpected calls %v", r.calls)
+	}
+
+	// the rolled back components aren't stopped again
+	r.calls = nil
+	if err := l.stop(ctx); err != nil || len(r.calls) != 0 {
+		t.Errorf("unexpected stop %v %v", err, r.calls)
+	}
+}
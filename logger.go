@@ -1,16 +1,11 @@
 package crane
 
 import (
-	"github.com/kenretto/crane/server"
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 )
 
-// Logger encapsulating logrus
-type Logger struct {
-	*logrus.Entry
-}
-
-// WithFields encapsulating logrus
-func (logger *Logger) WithFields(fields server.Fields) server.ILogger {
-	return &Logger{logger.Logger.WithFields(logrus.Fields(fields))}
-}
+// Logger the logger used by the packages of crane, it was a struct embedding *logrus.Entry and is an interface now,
+//  so that &crane.Logger{Entry: entry} doesn't compile anymore, use logging.NewLogrus(entry) instead
+//
+// Deprecated: use logging.Logger
+type Logger = logging.Logger
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/response"
+	"net/http"
+	"time"
+)
+
+// levelRequest the request body of changing the level of a module
+//  revert_after: such as 10m, the level will be reverted to the configured one after it, empty means never
+type levelRequest struct {
+	Level       string `json:"level" binding:"required"`
+	RevertAfter string `json:"revert_after"`
+}
+
+// AdminRoutes register the routes adjusting the module levels at runtime, the routes should be protected by the middlewares
+//  GET    /logger/modules        the current level of each module
+//  PUT    /logger/modules/:name  change the level of the registered module, body: {"level": "debug", "revert_after": "10m"}
+//  DELETE /logger/modules/:name  revert the level of the module to the configured one
+func AdminRoutes(router gin.IRouter, l *Logger, middlewares ...gin.HandlerFunc) {
+	var group = router.Group("/logger/modules", middlewares...)
+
+	group.GET("", func(ctx *gin.Context) {
+		response.NewResponse(response.Success.Code, l.ModuleLevels()).End(ctx)
+	})
+
+	group.PUT("/:name", func(ctx *gin.Context) {
+		var req levelRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusBadRequest)
+			return
+		}
+
+		var revertAfter time.Duration
+		if req.RevertAfter != "" {
+			var err error
+			if revertAfter, err = time.ParseDuration(req.RevertAfter); err != nil {
+				response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := l.SetModuleLevel(ctx.Param("name"), req.Level, revertAfter); errors.Is(err, ErrUnknownModule) {
+			response.NewResponse(response.NotFound.Code, nil, err.Error()).End(ctx, http.StatusNotFound)
+			return
+		} else if err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusBadRequest)
+			return
+		}
+		response.NewResponse(response.Success.Code, l.ModuleLevels()).End(ctx)
+	})
+
+	group.DELETE("/:name", func(ctx *gin.Context) {
+		l.ResetModuleLevel(ctx.Param("name"))
+		response.NewResponse(response.Success.Code, l.ModuleLevels()).End(ctx)
+	})
+}
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull the message was dropped because the queue of the async writer is full
+	ErrQueueFull = errors.New("logger: async queue is full, message dropped")
+
+	droppedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "crane",
+			Name:      "logger_dropped_messages_total",
+			Help:      "log messages dropped because the async queue is full",
+		},
+		[]string{"target"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(droppedCounter)
+}
+
+// Async asynchronous writing config, messages are queued and written by a background goroutine,
+//  so that disk stalls don't block the caller
+//  policy: block (default) waits for the queue to have space, drop discards the message and counts it in crane_logger_dropped_messages_total
+type Async struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	QueueSize    int    `mapstructure:"queue_size"`    // default 8192
+	Policy       string `mapstructure:"policy"`        // block or drop
+	FlushTimeout string `mapstructure:"flush_timeout"` // the maximum duration to wait for the queue to be flushed on closing, default 5s
+}
+
+type record struct {
+	level   logrus.Level
+	leveled bool
+	data    []byte
+}
+
+// asyncWriter write to writer in a background goroutine, closing it flushes the queue but doesn't close writer
+type asyncWriter struct {
+	writer  io.Writer
+	target  string
+	drop    bool
+	timeout time.Duration
+
+	// the queue isn't closed, so that the blocked writers never send on a closed channel, they return when closing is closed
+	queue   chan record
+	closing chan struct{}
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newAsyncWriter(writer io.Writer, target string, config Async, timeout time.Duration) *asyncWriter {
+	var size = config.QueueSize
+	if size <= 0 {
+		size = 8192
+	}
+	var w = &asyncWriter{
+		writer:  writer,
+		target:  target,
+		drop:    config.Policy == "drop",
+		timeout: timeout,
+		queue:   make(chan record, size),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// run write the queued messages, after closing the messages left in the queue are written and it returns
+func (w *asyncWriter) run() {
+	defer close(w.done)
+	for {
+		select {
+		case r := <-w.queue:
+			w.write(r)
+		case <-w.closing:
+			for {
+				select {
+				case r := <-w.queue:
+					w.write(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *asyncWriter) write(r record) {
+	if writer, ok := w.writer.(levelWriter); ok && r.leveled {
+		_, _ = writer.WriteLevel(r.level, r.data)
+		return
+	}
+	_, _ = w.writer.Write(r.data)
+}
+
+func (w *asyncWriter) enqueue(r record) error {
+	select {
+	case <-w.closing:
+		return os.ErrClosed
+	default:
+	}
+
+	if !w.drop {
+		select {
+		case w.queue <- r:
+			return nil
+		case <-w.closing:
+			return os.ErrClosed
+		}
+	}
+	select {
+	case w.queue <- r:
+		return nil
+	default:
+		droppedCounter.With(prometheus.Labels{"target": w.target}).Inc()
+		return ErrQueueFull
+	}
+}
+
+// Write queue a copy of p, the formatter may reuse the buffer after Write returns
+func (w *asyncWriter) Write(p []byte) (n int, err error) {
+	var data = make([]byte, len(p))
+	copy(data, p)
+	return len(p), w.enqueue(record{data: data})
+}
+
+// WriteLevel the same as Write, the level is passed to the writer if it's a levelWriter
+func (w *asyncWriter) WriteLevel(level logrus.Level, p []byte) (n int, err error) {
+	var data = make([]byte, len(p))
+	copy(data, p)
+	return len(p), w.enqueue(record{level: level, leveled: true, data: data})
+}
+
+// Close stop accepting messages, and wait for the queued messages to be written until the flush timeout,
+//  the writers blocked on the full queue return os.ErrClosed
+func (w *asyncWriter) Close() error {
+	var closed bool
+	w.once.Do(func() {
+		close(w.closing)
+		closed = true
+	})
+	if !closed {
+		return nil
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(w.timeout):
+		return errors.New("logger: flush timeout, some messages may be lost")
+	}
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buffer  bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buffer.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	var writer = &blockingWriter{release: make(chan struct{})}
+	var async = newAsyncWriter(writer, "test", Async{QueueSize: 1, Policy: "drop"}, time.Second)
+
+	var buffer = []byte("message\n")
+	for i := 0; i < 5; i++ {
+		_, _ = async.Write(buffer)
+	}
+	// the formatter may reuse its buffer
+	copy(buffer, "changed")
+
+	if dropped := testutil.ToFloat64(droppedCounter.WithLabelValues("test")); dropped < 3 {
+		t.Errorf("expect at least 3 dropped messages, got %v", dropped)
+	}
+
+	close(writer.release)
+	if err := async.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := async.Write(buffer); err == nil {
+		t.Error("write after close should fail")
+	}
+	if !bytes.HasPrefix(writer.buffer.Bytes(), []byte("message\n")) {
+		t.Errorf("unexpected content %q", writer.buffer.String())
+	}
+}
+
+func TestAsyncWriter_CloseStalled(t *testing.T) {
+	// the writer never returns, the blocked writers and Close return within the flush timeout
+	var writer = &blockingWriter{release: make(chan struct{})}
+	defer close(writer.release)
+	var async = newAsyncWriter(writer, "stalled", Async{QueueSize: 1}, 100*time.Millisecond)
+
+	var blocked = make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := async.Write([]byte("message\n"))
+			blocked <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	var closed = make(chan error, 1)
+	go func() {
+		closed <- async.Close()
+	}()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Error("expect the flush timeout error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close should return after the flush timeout")
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case <-blocked:
+		case <-time.After(time.Second):
+			t.Fatal("the blocked writers should return after closing")
+		}
+	}
+}
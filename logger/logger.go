@@ -2,10 +2,10 @@ package logger
 
 import (
 	"fmt"
-	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
-	"github.com/medivh-jay/lfshook"
+	"github.com/kenretto/crane/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"io"
 	"os"
 	"sync"
 	"time"
@@ -14,14 +14,26 @@ import (
 // Logger logger
 type Logger struct {
 	Config struct {
-		MaxAge       string `mapstructure:"max_age"`
-		RotationTime string `mapstructure:"rotation_time"`
-		Level        string `mapstructure:"level"`
-		Path         string `mapstructure:"path"`
-		ReportCaller bool   `mapstructure:"report_caller"`
+		MaxAge       string   `mapstructure:"max_age"`
+		RotationTime string   `mapstructure:"rotation_time"`
+		Level        string   `mapstructure:"level"`
+		Path         string   `mapstructure:"path"`
+		ReportCaller bool     `mapstructure:"report_caller"`
+		Outputs      []Output `mapstructure:"outputs"`
+		MaxSize      int      `mapstructure:"max_size"`    // the maximum size in megabytes of a log file before it gets rotated, 0 means no limit
+		MaxBackups   int      `mapstructure:"max_backups"` // the maximum number of rotated files to retain, 0 means retaining all files within max_age
+		Compress     bool     `mapstructure:"compress"`    // compress the rotated files with gzip
+		Combined     bool     `mapstructure:"combined"`    // write all levels to a single file instead of a file per level
+		Async        Async    `mapstructure:"async"`
+		// Modules the level of each module logger, such as orm: debug, the global level is used for the modules not listed
+		Modules map[string]string `mapstructure:"modules"`
 	}
 
 	logger     *logrus.Logger
+	writers    []io.Closer
+	queues     []io.Closer
+	files      map[string]io.Writer
+	modules    map[string]*module
 	FilenameFn func(path, level string) string
 	rw         sync.RWMutex
 }
@@ -30,17 +42,14 @@ func (l *Logger) Node() string {
 	return "logger"
 }
 
+// Describe the logger node is unmarshalled into Config
+func (l *Logger) Describe() interface{} {
+	return &l.Config
+}
+
 // LogLevel logger level
 func (l *Logger) LogLevel() logrus.Level {
-	return map[string]logrus.Level{
-		"panic": logrus.PanicLevel,
-		"fatal": logrus.FatalLevel,
-		"error": logrus.ErrorLevel,
-		"warn":  logrus.WarnLevel,
-		"info":  logrus.InfoLevel,
-		"debug": logrus.DebugLevel,
-		"trace": logrus.TraceLevel,
-	}[l.Config.Level]
+	return levels[l.Config.Level]
 }
 
 func (l *Logger) parseDuration(s string, defaultValue time.Duration) time.Duration {
@@ -59,53 +68,145 @@ func (l *Logger) defaultFilenameFn(path, level string) string {
 	return fmt.Sprintf("%s/%s.%%Y%%m%%d.%s.log", path, level, hostname)
 }
 
-func (l *Logger) newWriter(level string) *rotatelogs.RotateLogs {
-	var (
-		writer *rotatelogs.RotateLogs
-		err    error
-	)
+// writer get the rotating file writer of the level, writers are shared by the outputs of the same instance
+//
+//	in combined mode all levels are written to the same file named with "all"
+func (l *Logger) writer(level string) (io.Writer, error) {
+	if l.Config.Combined {
+		level = "all"
+	}
+	if writer, ok := l.files[level]; ok {
+		return writer, nil
+	}
+	file, err := l.newWriter(level)
+	if err != nil {
+		return nil, err
+	}
+	var writer = l.async(file, "file")
+	l.files[level] = writer
+	return writer, nil
+}
+
+// async wrap the writer with an async writer if async writing is enabled
+func (l *Logger) async(writer io.Writer, target string) io.Writer {
+	if !l.Config.Async.Enabled {
+		return writer
+	}
+	var w = newAsyncWriter(writer, target, l.Config.Async, l.parseDuration(l.Config.Async.FlushTimeout, 5*time.Second))
+	l.queues = append(l.queues, w)
+	return w
+}
+
+func (l *Logger) newWriter(level string) (*rotateWriter, error) {
 	if l.FilenameFn == nil {
 		l.FilenameFn = l.defaultFilenameFn
 	}
 
-	writer, err = rotatelogs.New(
+	writer, err := newRotateWriter(
 		l.FilenameFn(l.Config.Path, level),
-		rotatelogs.WithMaxAge(l.parseDuration(l.Config.MaxAge, time.Duration(30*86400)*time.Second)),
-		rotatelogs.WithRotationTime(l.parseDuration(l.Config.RotationTime, time.Hour)),
+		l.parseDuration(l.Config.RotationTime, time.Hour),
+		int64(l.Config.MaxSize)*1024*1024,
+		l.parseDuration(l.Config.MaxAge, time.Duration(30*86400)*time.Second),
+		l.Config.MaxBackups,
+		l.Config.Compress,
 	)
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return writer
+	l.writers = append(l.writers, writer)
+	return writer, nil
 }
 
-func (l *Logger) newInstance() {
-	l.logger = logrus.New()
-	l.logger.SetFormatter(&logrus.TextFormatter{ForceColors: true})
-	l.logger.SetNoLock()
-	l.logger.Level = l.LogLevel()
+// hooks create the hooks of the outputs, the writers they open are kept in writers, queues and files of l
+func (l *Logger) hooks() (logrus.LevelHooks, error) {
+	l.files = make(map[string]io.Writer)
+	var outputs = l.Config.Outputs
+	if len(outputs) == 0 {
+		outputs = defaultOutputs
+	}
+	var hooks = make(logrus.LevelHooks)
+	// the caller is fixed before the outputs format it
+	hooks.Add(logging.CallerHook{})
+	for _, output := range outputs {
+		hook, err := l.newHook(output)
+		if err != nil {
+			return nil, err
+		}
+		hooks.Add(hook)
+	}
+	return hooks, nil
+}
 
-	l.logger.AddHook(lfshook.NewHook(
-		lfshook.WriterMap{
-			logrus.InfoLevel:  l.newWriter("info"),
-			logrus.ErrorLevel: l.newWriter("error"),
-			logrus.DebugLevel: l.newWriter("debug"),
-			logrus.WarnLevel:  l.newWriter("warn"),
-			logrus.TraceLevel: l.newWriter("trace"),
-		}, &logrus.JSONFormatter{}))
+// newInstance apply the config and the hooks to the logger, the logger is created once and kept when the configuration changes,
+//  so that the callers of Instance keep writing to the new outputs
+func (l *Logger) newInstance(hooks logrus.LevelHooks) {
+	if l.logger == nil {
+		l.logger = logrus.New()
+		l.logger.SetFormatter(&logrus.TextFormatter{ForceColors: true})
+		// all outputs are written by hooks
+		l.logger.SetOutput(nilWriter{})
+	}
 
-	l.logger.SetOutput(nilWriter{})
+	// the hooks are fired under the lock of the logger, so the old writers aren't used once they are replaced
+	l.logger.ReplaceHooks(hooks)
+	l.logger.SetLevel(l.LogLevel())
 	l.logger.SetReportCaller(l.Config.ReportCaller)
+
+	// the module loggers are kept by the callers, so the new outputs are applied to them instead of replacing them
+	for name, m := range l.modules {
+		l.apply(name, m)
+	}
 }
 
-// OnChange When the configuration file changes, the logger will be reinitialized
+// OnChange When the configuration file changes, the outputs of the logger are replaced and the old writers are closed,
+//  if any output can't be created, it panics on the first load, and then the error is logged and the current outputs are kept
 func (l *Logger) OnChange(viper *viper.Viper) {
 	l.rw.Lock()
 	defer l.rw.Unlock()
+	var previous, writers, queues, files = l.Config, l.writers, l.queues, l.files
+	// the slices and maps are decoded into new ones, so that the previous config isn't changed
+	l.Config.Outputs, l.Config.Modules = nil, nil
 	_ = viper.Unmarshal(&l.Config)
-	l.newInstance()
+	l.writers, l.queues = nil, nil
+
+	hooks, err := l.hooks()
+	if err != nil {
+		_ = closeAll(l.queues)
+		_ = closeAll(l.writers)
+		if l.logger == nil {
+			panic(err)
+		}
+		l.Config, l.writers, l.queues, l.files = previous, writers, queues, files
+		l.logger.Error(fmt.Errorf("logger: the outputs aren't reloaded, %w", err))
+		return
+	}
+	l.newInstance(hooks)
+	_ = closeAll(queues)
+	_ = closeAll(writers)
+}
+
+// Close flush the async queues and close all log writers, the logger should not be used after closing
+func (l *Logger) Close() error {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	var err = closeAll(l.queues)
+	if closeErr := closeAll(l.writers); err == nil {
+		err = closeErr
+	}
+	l.writers, l.queues = nil, nil
+	return err
+}
+
+func closeAll(writers []io.Closer) error {
+	var first error
+	for _, writer := range writers {
+		if err := writer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
 }
 
 // Instance get logger instance
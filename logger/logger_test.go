@@ -2,6 +2,11 @@ package logger
 
 import (
 	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/crane/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -17,3 +22,112 @@ func TestLogger_Instance(t *testing.T) {
 	logger.Instance().Error("this is error message")
 	logger.Instance().Trace("this is trace message")
 }
+
+func TestLogger_Reload(t *testing.T) {
+	var dir = t.TempDir()
+	var logger = &Logger{FilenameFn: func(path, level string) string {
+		return path + "/" + level + ".log"
+	}}
+	var config = viper.New()
+	config.Set("level", "info")
+	config.Set("path", dir+"/first")
+	config.Set("combined", true)
+	logger.OnChange(config)
+	defer logger.Close()
+
+	// the instance saved before the reload writes to the new outputs
+	var instance = logger.Instance()
+	config.Set("path", dir+"/second")
+	logger.OnChange(config)
+	if instance != logger.Instance() {
+		t.Error("the instance should be kept across the reloads")
+	}
+	instance.Info("after reload")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dir + "/second/all.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "after reload") {
+		t.Errorf("the message should be written to the new file, got %q", data)
+	}
+}
+
+func TestLogger_InvalidReload(t *testing.T) {
+	var dir = t.TempDir()
+	var logger = &Logger{FilenameFn: func(path, level string) string {
+		return path + "/" + level + ".log"
+	}}
+	var config = viper.New()
+	config.Set("level", "info")
+	config.Set("path", dir)
+	config.Set("combined", true)
+	logger.OnChange(config)
+	defer logger.Close()
+
+	// one valid output is created before the invalid one, it's closed and the current outputs are kept
+	config.Set("path", dir+"/invalid")
+	config.Set("outputs", []map[string]interface{}{{"target": "file"}, {"target": "stdout", "format": "yaml"}})
+	logger.OnChange(config)
+	config.Set("outputs", []map[string]interface{}{{"target": "unknown"}})
+	logger.OnChange(config)
+	if logger.Config.Path != dir || len(logger.Config.Outputs) != 0 {
+		t.Errorf("the config should be kept, got %+v", logger.Config)
+	}
+	logger.Instance().Info("after reload")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dir + "/all.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "after reload") || !strings.Contains(string(data), "the outputs aren't reloaded") {
+		t.Errorf("the message should be written to the current file, got %q", data)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("the invalid outputs should panic on the first load")
+		}
+	}()
+	(&Logger{}).OnChange(config)
+}
+
+func TestLogger_Caller(t *testing.T) {
+	var dir = t.TempDir()
+	var logger = &Logger{FilenameFn: func(path, level string) string {
+		return path + "/" + level + ".log"
+	}}
+	var config = viper.New()
+	config.Set("level", "info")
+	config.Set("path", dir)
+	config.Set("combined", true)
+	config.Set("report_caller", true)
+	logger.OnChange(config)
+	defer logger.Close()
+
+	logging.NewLogrus(logrus.NewEntry(logger.Module("orm"))).WithFields(logging.Fields{"sql": "select 1"}).Info("adapted")
+	logger.Instance().Info("direct")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dir + "/all.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expect 2 lines, got %q", data)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "logger_test.go") || strings.Contains(line, "logging/logrus.go") {
+			t.Errorf("the caller should be the test, got %s", line)
+		}
+	}
+}
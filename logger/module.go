@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// ErrUnknownModule the module isn't registered by Logger.Module
+var ErrUnknownModule = errors.New("logger: unknown module")
+
+// module a named sub logger, it shares the outputs of Logger with an independently adjustable level
+type module struct {
+	logger   *logrus.Logger
+	override *logrus.Level
+	revert   *time.Timer
+}
+
+// ParseLevel parse the level name used in the configuration, the names reported by ModuleLevels such as warning are accepted too
+func ParseLevel(name string) (logrus.Level, error) {
+	if level, ok := levels[name]; ok {
+		return level, nil
+	}
+	level, err := logrus.ParseLevel(name)
+	if err != nil {
+		return 0, fmt.Errorf("logger: unknown level %s", name)
+	}
+	return level, nil
+}
+
+// moduleLevel the level of the module, the runtime override first, then the logger.modules config, and then logger.level
+func (l *Logger) moduleLevel(name string, m *module) logrus.Level {
+	if m.override != nil {
+		return *m.override
+	}
+	if level, ok := levels[l.Config.Modules[name]]; ok {
+		return level
+	}
+	return l.LogLevel()
+}
+
+// apply apply the outputs of the current instance to the module logger
+func (l *Logger) apply(name string, m *module) {
+	if l.logger == nil {
+		m.logger.SetLevel(l.moduleLevel(name, m))
+		return
+	}
+	var hooks = make(logrus.LevelHooks)
+	for level, items := range l.logger.Hooks {
+		hooks[level] = append(hooks[level], items...)
+	}
+	m.logger.ReplaceHooks(hooks)
+	m.logger.SetFormatter(l.logger.Formatter)
+	m.logger.SetOutput(nilWriter{})
+	m.logger.SetReportCaller(l.Config.ReportCaller)
+	m.logger.SetLevel(l.moduleLevel(name, m))
+}
+
+// Module get the named sub logger, such as orm, redis, server, timer, captcha,
+//  the returned logger is kept when the configuration changes, so it can be saved
+func (l *Logger) Module(name string) *logrus.Logger {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	if m, ok := l.modules[name]; ok {
+		return m.logger
+	}
+	if l.modules == nil {
+		l.modules = make(map[string]*module)
+	}
+	var m = &module{logger: logrus.New()}
+	l.apply(name, m)
+	l.modules[name] = m
+	return m.logger
+}
+
+// SetModuleLevel change the level of the module at runtime, if revertAfter is greater than 0, the level will be reverted after it,
+//  ErrUnknownModule is returned if the module isn't registered by Module
+func (l *Logger) SetModuleLevel(name, level string, revertAfter time.Duration) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	m, ok := l.modules[name]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownModule, name)
+	}
+	if m.revert != nil {
+		m.revert.Stop()
+		m.revert = nil
+	}
+	m.override = &parsed
+	m.logger.SetLevel(parsed)
+	if revertAfter > 0 {
+		var timer *time.Timer
+		timer = time.AfterFunc(revertAfter, func() {
+			l.rw.Lock()
+			defer l.rw.Unlock()
+			if m.revert == timer {
+				m.revert, m.override = nil, nil
+				m.logger.SetLevel(l.moduleLevel(name, m))
+			}
+		})
+		m.revert = timer
+	}
+	return nil
+}
+
+// ResetModuleLevel discard the runtime level of the module, the configured level will be used again
+func (l *Logger) ResetModuleLevel(name string) {
+	l.rw.Lock()
+	defer l.rw.Unlock()
+	m, ok := l.modules[name]
+	if !ok {
+		return
+	}
+	if m.revert != nil {
+		m.revert.Stop()
+	}
+	m.revert, m.override = nil, nil
+	m.logger.SetLevel(l.moduleLevel(name, m))
+}
+
+// ModuleLevels the current level of each module, such as panic, fatal, error, warning, info, debug and trace
+func (l *Logger) ModuleLevels() map[string]string {
+	l.rw.RLock()
+	defer l.rw.RUnlock()
+	var result = make(map[string]string, len(l.modules))
+	for name, m := range l.modules {
+		result[name] = m.logger.GetLevel().String()
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/configurator"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger_Module(t *testing.T) {
+	var logger = new(Logger)
+	var c, err = configurator.NewConfigurator("testdata/logger.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(logger)
+	defer logger.Close()
+
+	var orm = logger.Module("orm")
+	if orm != logger.Module("orm") {
+		t.Error("module logger should be reused")
+	}
+	if orm.GetLevel() != logrus.WarnLevel {
+		t.Errorf("expect the configured level warn, got %s", orm.GetLevel())
+	}
+	if level := logger.Module("redis").GetLevel(); level != logrus.TraceLevel {
+		t.Errorf("expect the global level trace, got %s", level)
+	}
+
+	if err := logger.SetModuleLevel("orm", "verbose", 0); err == nil {
+		t.Error("unknown level should be rejected")
+	}
+	if err := logger.SetModuleLevel("unregistered", "debug", 0); !errors.Is(err, ErrUnknownModule) {
+		t.Errorf("expect ErrUnknownModule, got %v", err)
+	}
+	if levels := logger.ModuleLevels(); levels["orm"] != "warning" {
+		t.Errorf("expect warning, got %s", levels["orm"])
+	}
+	if err := logger.SetModuleLevel("orm", "debug", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if levels := logger.ModuleLevels(); levels["orm"] != "debug" {
+		t.Errorf("expect debug, got %s", levels["orm"])
+	}
+	orm.Debug("this is debug message of orm")
+
+	time.Sleep(200 * time.Millisecond)
+	if orm.GetLevel() != logrus.WarnLevel {
+		t.Errorf("expect the level reverted to warn, got %s", orm.GetLevel())
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	var logger = new(Logger)
+	var c, err = configurator.NewConfigurator("testdata/logger.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(logger)
+	defer logger.Close()
+	logger.Module("orm")
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	AdminRoutes(router, logger)
+	var request = func(method, path, body string) *httptest.ResponseRecorder {
+		var recorder = httptest.NewRecorder()
+		var req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := request(http.MethodPut, "/logger/modules/unregistered", `{"level": "debug"}`); recorder.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", recorder.Code)
+	}
+	if _, ok := logger.ModuleLevels()["unregistered"]; ok {
+		t.Error("the unregistered module should not be created")
+	}
+	if recorder := request(http.MethodPut, "/logger/modules/orm", `{"level": "fatal"}`); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"orm":"fatal"`) {
+		t.Errorf("unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/medivh-jay/lfshook"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sync"
+)
+
+// Output a log output target, each output writes the levels it accepts in its own format
+//  target: stdout, stderr, file, syslog
+//  format: text, json, logfmt, the default format is text for stdout and stderr, json for the others
+//  levels: the levels written to this target, all levels if empty
+type Output struct {
+	Target string   `mapstructure:"target"`
+	Format string   `mapstructure:"format"`
+	Levels []string `mapstructure:"levels"`
+	Syslog struct {
+		Network string `mapstructure:"network"` // empty means the local syslog daemon
+		Addr    string `mapstructure:"addr"`
+		Tag     string `mapstructure:"tag"`
+	} `mapstructure:"syslog"`
+}
+
+// defaultOutputs without any output configured, logs are written to the rotating files in json, and nothing to the terminal
+var defaultOutputs = []Output{{Target: "file", Format: "json"}}
+
+var levels = map[string]logrus.Level{
+	"panic": logrus.PanicLevel,
+	"fatal": logrus.FatalLevel,
+	"error": logrus.ErrorLevel,
+	"warn":  logrus.WarnLevel,
+	"info":  logrus.InfoLevel,
+	"debug": logrus.DebugLevel,
+	"trace": logrus.TraceLevel,
+}
+
+// levelName the file name of the level, panic and fatal are written with error
+func levelName(level logrus.Level) string {
+	if level < logrus.ErrorLevel {
+		level = logrus.ErrorLevel
+	}
+	for name, l := range levels {
+		if l == level {
+			return name
+		}
+	}
+	return level.String()
+}
+
+func (output Output) levels() []logrus.Level {
+	if len(output.Levels) == 0 {
+		return logrus.AllLevels
+	}
+	var result = make([]logrus.Level, 0, len(output.Levels))
+	for _, name := range output.Levels {
+		if level, ok := levels[name]; ok {
+			result = append(result, level)
+		}
+	}
+	return result
+}
+
+func (output Output) formatter() (logrus.Formatter, error) {
+	var format = output.Format
+	if format == "" {
+		format = "json"
+		if output.Target == "stdout" || output.Target == "stderr" {
+			format = "text"
+		}
+	}
+
+	switch format {
+	case "text":
+		if output.Target == "stdout" || output.Target == "stderr" {
+			return &logrus.TextFormatter{ForceColors: true, FullTimestamp: true}, nil
+		}
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}, nil
+	case "logfmt":
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true, DisableSorting: true}, nil
+	case "json":
+		return &logrus.JSONFormatter{}, nil
+	}
+	return nil, fmt.Errorf("logger: unknown output format %s", format)
+}
+
+// newHook create the hook writing to the output target, the writers it opens are added to writers and queues of l
+func (l *Logger) newHook(output Output) (logrus.Hook, error) {
+	formatter, err := output.formatter()
+	if err != nil {
+		return nil, err
+	}
+	switch output.Target {
+	case "stdout":
+		return &writerHook{writer: l.async(os.Stdout, "stdout"), formatter: formatter, levels: output.levels()}, nil
+	case "stderr":
+		return &writerHook{writer: l.async(os.Stderr, "stderr"), formatter: formatter, levels: output.levels()}, nil
+	case "file":
+		var writers = make(lfshook.WriterMap)
+		for _, level := range output.levels() {
+			if writers[level], err = l.writer(levelName(level)); err != nil {
+				return nil, err
+			}
+		}
+		return lfshook.NewHook(writers, formatter), nil
+	case "syslog":
+		writer, err := newSyslogWriter(output.Syslog.Network, output.Syslog.Addr, output.Syslog.Tag)
+		if err != nil {
+			return nil, err
+		}
+		l.writers = append(l.writers, writer)
+		return &writerHook{writer: l.async(writer, "syslog"), formatter: formatter, levels: output.levels()}, nil
+	}
+	return nil, fmt.Errorf("logger: unknown output target %s", output.Target)
+}
+
+// levelWriter writers that write each level differently, such as syslog priorities
+type levelWriter interface {
+	WriteLevel(level logrus.Level, p []byte) (n int, err error)
+}
+
+// writerHook write the formatted entries of the accepted levels to writer
+type writerHook struct {
+	writer    io.Writer
+	formatter logrus.Formatter
+	levels    []logrus.Level
+	mu        sync.Mutex
+}
+
+func (hook *writerHook) Levels() []logrus.Level {
+	return hook.levels
+}
+
+func (hook *writerHook) Fire(entry *logrus.Entry) error {
+	data, err := hook.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if writer, ok := hook.writer.(levelWriter); ok {
+		_, err = writer.WriteLevel(entry.Level, data)
+		return err
+	}
+	_, err = hook.writer.Write(data)
+	return err
+}
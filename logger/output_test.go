@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"testing"
+)
+
+// recordWriter records the levels written by writerHook
+type recordWriter struct {
+	levels []logrus.Level
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w *recordWriter) WriteLevel(level logrus.Level, p []byte) (int, error) {
+	w.levels = append(w.levels, level)
+	return len(p), nil
+}
+
+func TestOutput_Levels(t *testing.T) {
+	var cases = []struct {
+		name   string
+		levels []string
+		expect []string
+	}{
+		{"all", nil, []string{"panic", "fatal", "error", "warn", "info", "debug", "trace"}},
+		{"errors", []string{"panic", "fatal", "error"}, []string{"panic", "fatal", "error"}},
+		{"info", []string{"info"}, []string{"info"}},
+		{"debug", []string{"debug", "trace", "unknown"}, []string{"debug", "trace"}},
+	}
+
+	var logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	var buffers = make([]*bytes.Buffer, len(cases))
+	var hooks = make(logrus.LevelHooks)
+	for i, item := range cases {
+		buffers[i] = new(bytes.Buffer)
+		var output = Output{Target: "stdout", Format: "logfmt", Levels: item.levels}
+		var formatter, _ = output.formatter()
+		hooks.Add(&writerHook{writer: buffers[i], formatter: formatter, levels: output.levels()})
+	}
+	logger.ReplaceHooks(hooks)
+
+	for _, level := range logrus.AllLevels {
+		// the panic level panics after the hooks are fired, Log doesn't exit on the fatal level
+		func() {
+			defer func() { _ = recover() }()
+			logger.Log(level, level.String()+" message")
+		}()
+	}
+
+	for i, item := range cases {
+		for _, level := range logrus.AllLevels {
+			var written = strings.Contains(buffers[i].String(), `msg="`+level.String()+" message")
+			var expected = false
+			for _, name := range item.expect {
+				expected = expected || levels[name] == level
+			}
+			if written != expected {
+				t.Errorf("%s: level %s written %v, expect %v", item.name, level, written, expected)
+			}
+		}
+	}
+}
+
+func TestWriterHook_LevelWriter(t *testing.T) {
+	var writer = new(recordWriter)
+	var logger = logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.AddHook(&writerHook{writer: writer, formatter: &logrus.JSONFormatter{}, levels: Output{Levels: []string{"error", "info"}}.levels()})
+
+	logger.Error("error message")
+	logger.Warn("warn message")
+	logger.Info("info message")
+	if len(writer.levels) != 2 || writer.levels[0] != logrus.ErrorLevel || writer.levels[1] != logrus.InfoLevel {
+		t.Errorf("unexpected levels %v", writer.levels)
+	}
+}
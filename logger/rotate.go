@@ -0,0 +1,239 @@
+package logger
+
+import (
+	"compress/gzip"
+	"github.com/lestrrat-go/strftime"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat the suffix of the files rotated because of size
+const backupTimeFormat = "20060102150405.000"
+
+var verbs = regexp.MustCompile(`%[A-Za-z]`)
+
+// rotateWriter a file writer rotated by time and size
+//  the file name is the strftime pattern formatted with the current time truncated to rotation,
+//  when the file exceeds maxSize, it is renamed with a time suffix and a new file is opened,
+//  rotated files are compressed with gzip if compress is enabled, and removed when they are older than maxAge or exceed maxBackups
+type rotateWriter struct {
+	pattern    *strftime.Strftime
+	glob       string
+	rotation   time.Duration
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	mu     sync.Mutex
+	file   *os.File
+	name   string
+	size   int64
+	closed bool
+	clean  chan struct{}
+	done   chan struct{}
+}
+
+func newRotateWriter(pattern string, rotation time.Duration, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*rotateWriter, error) {
+	p, err := strftime.New(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var w = &rotateWriter{
+		pattern:    p,
+		glob:       verbs.ReplaceAllString(pattern, "*") + "*",
+		rotation:   rotation,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+		clean:      make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	go w.cleaner()
+	// clean up the files left by the previous process
+	w.notify()
+	return w, nil
+}
+
+func (w *rotateWriter) filename(now time.Time) string {
+	if w.rotation > 0 {
+		now = now.Truncate(w.rotation)
+	}
+	return w.pattern.FormatString(now)
+}
+
+// Write write to the current file, rotate first if the time period changed or the size will exceed maxSize
+func (w *rotateWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	var name = w.filename(time.Now())
+	if w.file == nil || name != w.name {
+		if err = w.open(name); err != nil {
+			return 0, err
+		}
+	} else if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err = w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// open close the current file and open the file named name
+func (w *rotateWriter) open(name string) error {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+		w.notify()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file, w.name, w.size = file, name, info.Size()
+	return nil
+}
+
+// rotate rename the current file with a time suffix and reopen it
+func (w *rotateWriter) rotate() error {
+	_ = w.file.Close()
+	w.file = nil
+	if err := os.Rename(w.name, w.name+"."+time.Now().Format(backupTimeFormat)); err != nil {
+		return err
+	}
+	w.notify()
+	return w.open(w.name)
+}
+
+func (w *rotateWriter) notify() {
+	select {
+	case w.clean <- struct{}{}:
+	default:
+	}
+}
+
+func (w *rotateWriter) cleaner() {
+	defer close(w.done)
+	for range w.clean {
+		w.cleanup()
+	}
+}
+
+// cleanup compress and remove the rotated files
+func (w *rotateWriter) cleanup() {
+	w.mu.Lock()
+	var current = w.name
+	w.mu.Unlock()
+	// the file of the current period may be used by another writer of the same pattern during reloading
+	var period = w.filename(time.Now())
+
+	matches, err := filepath.Glob(w.glob)
+	if err != nil {
+		return
+	}
+
+	var backups = make([]os.FileInfo, 0, len(matches))
+	var paths = make(map[os.FileInfo]string)
+	for _, match := range matches {
+		if match == current || match == period {
+			continue
+		}
+		if w.compress && !strings.HasSuffix(match, ".gz") {
+			if compressed, err := compress(match); err == nil {
+				match = compressed
+			}
+		}
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		backups = append(backups, info)
+		paths[info] = match
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+	for i, info := range backups {
+		if (w.maxBackups > 0 && i >= w.maxBackups) || (w.maxAge > 0 && time.Since(info.ModTime()) > w.maxAge) {
+			_ = os.Remove(paths[info])
+		}
+	}
+}
+
+// compress gzip the file and remove it, returns the compressed file name
+func compress(name string) (string, error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	var gz = gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return "", err
+	}
+
+	_ = os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	_ = src.Close()
+	return name + ".gz", os.Remove(name)
+}
+
+// Close close the current file and wait for the cleanup to finish
+func (w *rotateWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	close(w.clean)
+	w.mu.Unlock()
+	<-w.done
+	return err
+}
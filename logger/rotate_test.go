@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crane-logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writer, err := newRotateWriter(filepath.Join(dir, "all.%Y%m%d.log"), time.Hour, 10, time.Hour, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := writer.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("closed")); err == nil {
+		t.Error("write after close should fail")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var current, compressed int
+	for _, file := range files {
+		switch {
+		case strings.HasSuffix(file.Name(), ".gz"):
+			compressed++
+		case strings.HasSuffix(file.Name(), ".log"):
+			current++
+			if file.Size() != 10 {
+				t.Errorf("file size %d exceeds max size", file.Size())
+			}
+		default:
+			t.Errorf("rotated file %s not compressed", file.Name())
+		}
+	}
+	if current != 1 || compressed != 2 {
+		t.Errorf("expect 1 current file and 2 backups, got %d and %d", current, compressed)
+	}
+}
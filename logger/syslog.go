@@ -0,0 +1,42 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"github.com/sirupsen/logrus"
+	"log/syslog"
+)
+
+// syslogWriter write entries to syslog with the priority of their level
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+func newSyslogWriter(network, addr, tag string) (*syslogWriter, error) {
+	writer, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{writer}, nil
+}
+
+// WriteLevel write with the syslog priority corresponding to the level
+func (w *syslogWriter) WriteLevel(level logrus.Level, p []byte) (n int, err error) {
+	var line = string(p)
+	switch level {
+	case logrus.PanicLevel:
+		err = w.Emerg(line)
+	case logrus.FatalLevel:
+		err = w.Crit(line)
+	case logrus.ErrorLevel:
+		err = w.Err(line)
+	case logrus.WarnLevel:
+		err = w.Warning(line)
+	case logrus.InfoLevel:
+		err = w.Info(line)
+	default:
+		err = w.Debug(line)
+	}
+	return len(p), err
+}
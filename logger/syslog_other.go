@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logger
+
+import (
+	"errors"
+	"io"
+)
+
+func newSyslogWriter(_, _, _ string) (io.WriteCloser, error) {
+	return nil, errors.New("logger: syslog is not supported on this platform")
+}
@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package logger
+
+import (
+	"github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogWriter_Priority(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("udp isn't available:", err)
+	}
+	defer conn.Close()
+	writer, err := newSyslogWriter("udp", conn.LocalAddr().String(), "crane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	// LOG_USER is 8, plus the severity of the level
+	var cases = []struct {
+		level    logrus.Level
+		priority string
+	}{
+		{logrus.PanicLevel, "<8>"},
+		{logrus.FatalLevel, "<10>"},
+		{logrus.ErrorLevel, "<11>"},
+		{logrus.WarnLevel, "<12>"},
+		{logrus.InfoLevel, "<14>"},
+		{logrus.DebugLevel, "<15>"},
+		{logrus.TraceLevel, "<15>"},
+	}
+	var buf = make([]byte, 1024)
+	for _, item := range cases {
+		if _, err = writer.WriteLevel(item.level, []byte(item.level.String())); err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message := string(buf[:n]); !strings.HasPrefix(message, item.priority) || !strings.HasSuffix(strings.TrimSpace(message), item.level.String()) {
+			t.Errorf("%s: unexpected message %s", item.level, message)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package logging the logging interface shared by all subpackages, the applications plug in their own backend once through the adapters,
+//  logrus and log/slog are supported out of the box, others such as zap can be adapted by implementing Logger
+package logging
+
+import "context"
+
+// Fields structured fields attached to the log entries
+type Fields map[string]interface{}
+
+// Logger the logger accepted by the subpackages
+//  WithFields and WithContext return a new logger, the receiver is not modified
+type Logger interface {
+	Trace(args ...interface{})
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+	WithFields(fields Fields) Logger
+	WithContext(ctx context.Context) Logger
+}
@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"github.com/sirupsen/logrus"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestAdapters(t *testing.T) {
+	var buffer bytes.Buffer
+
+	var l = logrus.New()
+	l.SetOutput(&buffer)
+	l.SetLevel(logrus.TraceLevel)
+	l.SetFormatter(&logrus.JSONFormatter{})
+	NewLogrus(logrus.NewEntry(l)).WithContext(context.Background()).WithFields(Fields{"module": "orm"}).Trace("logrus message")
+	if !strings.Contains(buffer.String(), `"module":"orm"`) || !strings.Contains(buffer.String(), "logrus message") {
+		t.Errorf("unexpected logrus output %s", buffer.String())
+	}
+
+	buffer.Reset()
+	var s = NewSlog(slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	s.Debug("ignored")
+	s.WithFields(Fields{"module": "redis"}).Warn("slog ", "message")
+	if strings.Contains(buffer.String(), "ignored") || !strings.Contains(buffer.String(), "module=redis") || !strings.Contains(buffer.String(), `msg="slog message"`) {
+		t.Errorf("unexpected slog output %s", buffer.String())
+	}
+
+	buffer.Reset()
+	NewStd(log.New(&buffer, "", 0)).WithFields(Fields{"b": 2}).WithFields(Fields{"a": 1}).Error("std message")
+	if buffer.String() != "[error] a:1    b:2    std message\n" {
+		t.Errorf("unexpected std output %q", buffer.String())
+	}
+}
@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// logrusLogger adapts *logrus.Entry to Logger
+type logrusLogger struct {
+	entry *logrus.Entry
+}
+
+// NewLogrus logrus adapter, use logrus.NewEntry to adapt a *logrus.Logger,
+//  with ReportCaller enabled, add CallerHook to the logger before the hooks using the caller, so that the caller isn't the adapter
+func NewLogrus(entry *logrus.Entry) Logger {
+	return &logrusLogger{entry: entry}
+}
+
+func (l *logrusLogger) Trace(args ...interface{}) {
+	l.entry.Trace(args...)
+}
+
+func (l *logrusLogger) Debug(args ...interface{}) {
+	l.entry.Debug(args...)
+}
+
+func (l *logrusLogger) Info(args ...interface{}) {
+	l.entry.Info(args...)
+}
+
+func (l *logrusLogger) Warn(args ...interface{}) {
+	l.entry.Warn(args...)
+}
+
+func (l *logrusLogger) Error(args ...interface{}) {
+	l.entry.Error(args...)
+}
+
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) WithContext(ctx context.Context) Logger {
+	return &logrusLogger{entry: l.entry.WithContext(ctx)}
+}
+
+// the packages skipped when looking for the caller
+var (
+	logrusPackage  = packageName(runtime.FuncForPC(reflect.ValueOf(logrus.New).Pointer()).Name())
+	loggingPackage = packageName(runtime.FuncForPC(reflect.ValueOf(NewLogrus).Pointer()).Name())
+)
+
+// packageName the package of the function name, such as github.com/sirupsen/logrus of github.com/sirupsen/logrus.(*Entry).Info
+func packageName(function string) string {
+	var slash = strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// CallerHook logrus reports the adapter as the caller of the entries logged through NewLogrus,
+//  the hook replaces it with the frame calling the adapter
+type CallerHook struct{}
+
+func (CallerHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (CallerHook) Fire(entry *logrus.Entry) error {
+	if entry.Caller == nil || packageName(entry.Caller.Function) != loggingPackage {
+		return nil
+	}
+	var pcs = make([]uintptr, 32)
+	var frames = runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if pkg := packageName(frame.Function); pkg != logrusPackage && pkg != loggingPackage {
+			entry.Caller = &frame
+			return nil
+		}
+		if !more {
+			return nil
+		}
+	}
+}
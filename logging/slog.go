@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// LevelTrace slog has no trace level, Trace is logged with this level
+const LevelTrace = slog.LevelDebug - 4
+
+// slogLogger adapts *slog.Logger to Logger, the arguments are formatted with fmt.Sprint as the message
+type slogLogger struct {
+	logger *slog.Logger
+	ctx    context.Context
+}
+
+// NewSlog log/slog adapter
+func NewSlog(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger, ctx: context.Background()}
+}
+
+func (l *slogLogger) log(level slog.Level, args []interface{}) {
+	if !l.logger.Enabled(l.ctx, level) {
+		return
+	}
+	l.logger.Log(l.ctx, level, fmt.Sprint(args...))
+}
+
+func (l *slogLogger) Trace(args ...interface{}) {
+	l.log(LevelTrace, args)
+}
+
+func (l *slogLogger) Debug(args ...interface{}) {
+	l.log(slog.LevelDebug, args)
+}
+
+func (l *slogLogger) Info(args ...interface{}) {
+	l.log(slog.LevelInfo, args)
+}
+
+func (l *slogLogger) Warn(args ...interface{}) {
+	l.log(slog.LevelWarn, args)
+}
+
+func (l *slogLogger) Error(args ...interface{}) {
+	l.log(slog.LevelError, args)
+}
+
+func (l *slogLogger) WithFields(fields Fields) Logger {
+	var attrs = make([]interface{}, 0, len(fields)*2)
+	for key, value := range fields {
+		attrs = append(attrs, key, value)
+	}
+	return &slogLogger{logger: l.logger.With(attrs...), ctx: l.ctx}
+}
+
+func (l *slogLogger) WithContext(ctx context.Context) Logger {
+	return &slogLogger{logger: l.logger, ctx: ctx}
+}
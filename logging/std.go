@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// stdLogger writes to the standard library logger, the level and fields are printed before the message
+type stdLogger struct {
+	logger *log.Logger
+	fields Fields
+}
+
+// NewStd standard library log adapter, nil means the standard logger of package log
+func NewStd(logger *log.Logger) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &stdLogger{logger: logger}
+}
+
+// Default the logger used by the subpackages when no logger is given, it writes to the standard logger of package log
+func Default() Logger {
+	return NewStd(nil)
+}
+
+func (l *stdLogger) print(level string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString("[" + level + "] ")
+	var keys = make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(fmt.Sprintf("%s:%+v    ", key, l.fields[key]))
+	}
+	b.WriteString(fmt.Sprint(args...))
+	_ = l.logger.Output(3, b.String())
+}
+
+func (l *stdLogger) Trace(args ...interface{}) {
+	l.print("trace", args)
+}
+
+func (l *stdLogger) Debug(args ...interface{}) {
+	l.print("debug", args)
+}
+
+func (l *stdLogger) Info(args ...interface{}) {
+	l.print("info", args)
+}
+
+func (l *stdLogger) Warn(args ...interface{}) {
+	l.print("warn", args)
+}
+
+func (l *stdLogger) Error(args ...interface{}) {
+	l.print("error", args)
+}
+
+func (l *stdLogger) WithFields(fields Fields) Logger {
+	var merged = make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &stdLogger{logger: l.logger, fields: merged}
+}
+
+// WithContext the standard logger doesn't use the context
+func (l *stdLogger) WithContext(_ context.Context) Logger {
+	return l
+}
+
+// nopLogger discards everything
+type nopLogger struct{}
+
+// Nop a logger discarding all entries
+func Nop() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Trace(_ ...interface{}) {}
+
+func (nopLogger) Debug(_ ...interface{}) {}
+
+func (nopLogger) Info(_ ...interface{}) {}
+
+func (nopLogger) Warn(_ ...interface{}) {}
+
+func (nopLogger) Error(_ ...interface{}) {}
+
+func (n nopLogger) WithFields(_ Fields) Logger {
+	return n
+}
+
+func (n nopLogger) WithContext(_ context.Context) Logger {
+	return n
+}
@@ -1,24 +1,191 @@
 package middleware
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/logging"
+	"github.com/spf13/viper"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-// CORS 全局允许跨域
-func CORS(c *gin.Context) {
-	if c.Request.Header.Get(`X-Requested-With`) != "" || c.Request.Header.Get(`Origin`) != "" {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", c.Request.Header.Get(`Origin`))
-		c.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "User-Agent, Referer, Accept, Sec-Fetch-Mode, Origin, Content-Type, Content-Length, Accept-Encoding, JWT,x-requested-with")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-	}
-	if c.Request.Method == "OPTIONS" {
-		c.AbortWithStatus(http.StatusOK)
-		c.Abort()
+var (
+	defaultAllowMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions}
+	defaultAllowHeaders = []string{"Origin", "Accept", "Content-Type", "Content-Length", "Accept-Encoding", "X-Requested-With", "JWT"}
+)
+
+// CORS configurable cross-origin resource sharing, loaded from the cors node
+//  allow_origins: exact origins such as https://example.com, wildcard subdomains such as https://*.example.com, or * for any origin
+//  allow_origin_regex: regular expressions matched against the whole origin
+//  credentials are only allowed for the origins matched by the exact, wildcard subdomain or regex rules, never for *
+//  requests from origins not allowed get no CORS headers, their preflight requests are rejected with 403
+type CORS struct {
+	Config struct {
+		AllowOrigins     []string `mapstructure:"allow_origins"`
+		AllowOriginRegex []string `mapstructure:"allow_origin_regex"`
+		AllowMethods     []string `mapstructure:"allow_methods"`  // default GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS
+		AllowHeaders     []string `mapstructure:"allow_headers"`  // default Origin, Accept, Content-Type, Content-Length, Accept-Encoding, X-Requested-With, JWT
+		ExposeHeaders    []string `mapstructure:"expose_headers"` // response headers readable by the scripts
+		AllowCredentials bool     `mapstructure:"allow_credentials"`
+		MaxAge           string   `mapstructure:"max_age"` // how long the preflight result can be cached, such as 12h
+	}
+
+	any      bool
+	exact    map[string]bool
+	suffixes [][2]string // scheme://, .domain of the wildcard subdomains
+	regexes  []*regexp.Regexp
+	logger   logging.Logger
+	rw       sync.RWMutex
+}
+
+// NewCORS create CORS, register it to the configurator to load the cors node
+func NewCORS() *CORS {
+	return &CORS{exact: make(map[string]bool), logger: logging.Default()}
+}
+
+// SetLogger the logger of the invalid rules, default logging.Default
+func (c *CORS) SetLogger(logger logging.Logger) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	c.logger = logger
+}
+
+func (c *CORS) Node() string {
+	return "cors"
+}
+
+// Describe the cors node is unmarshalled into Config
+func (c *CORS) Describe() interface{} {
+	return &c.Config
+}
+
+// OnChange reload the rules, if any regex is invalid, the error is logged and the previous rules are kept
+func (c *CORS) OnChange(viper *viper.Viper) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	var previous = c.Config
+	// the slices are decoded into new arrays, so that the previous config isn't changed
+	c.Config.AllowOrigins, c.Config.AllowOriginRegex, c.Config.AllowMethods, c.Config.AllowHeaders, c.Config.ExposeHeaders = nil, nil, nil, nil, nil
+	_ = viper.Unmarshal(&c.Config)
+
+	var regexes = make([]*regexp.Regexp, 0, len(c.Config.AllowOriginRegex))
+	for _, expr := range c.Config.AllowOriginRegex {
+		regex, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			c.logger.Error(fmt.Errorf("cors: the rules aren't reloaded, invalid allow_origin_regex %s: %w", expr, err))
+			c.Config = previous
+			return
+		}
+		regexes = append(regexes, regex)
+	}
+
+	c.any, c.exact, c.suffixes, c.regexes = false, make(map[string]bool), nil, regexes
+	for _, origin := range c.Config.AllowOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			c.any = true
+		case strings.Contains(origin, "://*."):
+			var parts = strings.SplitN(origin, "://*", 2)
+			c.suffixes = append(c.suffixes, [2]string{parts[0] + "://", parts[1]})
+		default:
+			c.exact[origin] = true
+		}
+	}
+}
+
+// match returns whether the origin is allowed, and whether it is allowed by a listed rule rather than *
+func (c *CORS) match(origin string) (allowed bool, listed bool) {
+	var lower = strings.ToLower(origin)
+	if c.exact[lower] {
+		return true, true
+	}
+	for _, suffix := range c.suffixes {
+		if strings.HasPrefix(lower, suffix[0]) && strings.HasSuffix(lower, suffix[1]) && len(lower) > len(suffix[0])+len(suffix[1]) {
+			return true, true
+		}
+	}
+	for _, regex := range c.regexes {
+		if regex.MatchString(origin) {
+			return true, true
+		}
+	}
+	return c.any, false
+}
+
+func (c *CORS) maxAge() string {
+	d, err := time.ParseDuration(c.Config.MaxAge)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(int(d / time.Second))
+}
+
+func orDefault(values []string, defaultValues []string) string {
+	if len(values) == 0 {
+		values = defaultValues
+	}
+	return strings.Join(values, ", ")
+}
+
+// Handler gin middleware, preflight requests are answered here without calling the next handlers
+func (c *CORS) Handler(ctx *gin.Context) {
+	var origin = ctx.GetHeader("Origin")
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+
+	var preflight = ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != ""
+	ctx.Writer.Header().Add("Vary", "Origin")
+	if !c.apply(ctx.Writer.Header(), origin, preflight) {
+		if preflight {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+		return
+	}
+
+	if preflight {
+		ctx.AbortWithStatus(http.StatusNoContent)
 		return
 	}
-	c.Next()
+	ctx.Next()
+}
+
+// apply set the CORS headers if the origin is allowed
+func (c *CORS) apply(header http.Header, origin string, preflight bool) bool {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	allowed, listed := c.match(origin)
+	if !allowed {
+		return false
+	}
+
+	if listed {
+		header.Set("Access-Control-Allow-Origin", origin)
+		if c.Config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+		header.Set("Access-Control-Allow-Methods", orDefault(c.Config.AllowMethods, defaultAllowMethods))
+		header.Set("Access-Control-Allow-Headers", orDefault(c.Config.AllowHeaders, defaultAllowHeaders))
+		if maxAge := c.maxAge(); maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+	} else if len(c.Config.ExposeHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(c.Config.ExposeHeaders, ", "))
+	}
+	return true
 }
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/crane/logging"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_Handler(t *testing.T) {
+	var cors = NewCORS()
+	var c, err = configurator.NewConfigurator("testdata/cors.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(cors)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.Use(cors.Handler)
+	router.Any("/", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	var request = func(method, origin string) *httptest.ResponseRecorder {
+		var req = httptest.NewRequest(method, "/", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for _, origin := range []string{"https://example.com", "https://api.example.org", "https://app-12.example.net"} {
+		var recorder = request(http.MethodGet, origin)
+		if recorder.Header().Get("Access-Control-Allow-Origin") != origin || recorder.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s should be allowed, got %v", origin, recorder.Header())
+		}
+		if recorder.Header().Get("Access-Control-Expose-Headers") != "X-Total-Count" {
+			t.Errorf("unexpected expose headers %v", recorder.Header())
+		}
+	}
+
+	for _, origin := range []string{"https://evil.com", "https://example.org.evil.com", "http://api.example.org", "https://app-x.example.net"} {
+		var recorder = request(http.MethodGet, origin)
+		if recorder.Header().Get("Access-Control-Allow-Origin") != "" || recorder.Code != http.StatusOK {
+			t.Errorf("%s should not be allowed, got %v", origin, recorder.Header())
+		}
+		if recorder := request(http.MethodOptions, origin); recorder.Code != http.StatusForbidden {
+			t.Errorf("the preflight of %s should be rejected, got %d", origin, recorder.Code)
+		}
+	}
+
+	var recorder = request(http.MethodOptions, "https://example.com")
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Access-Control-Allow-Methods") != "GET, POST" || recorder.Header().Get("Access-Control-Max-Age") != "43200" {
+		t.Errorf("unexpected preflight response %d %v", recorder.Code, recorder.Header())
+	}
+}
+
+func TestCORS_InvalidRegex(t *testing.T) {
+	var cors = NewCORS()
+	cors.SetLogger(logging.Nop())
+	var config = viper.New()
+	config.Set("allow_origins", []string{"https://example.com"})
+	cors.OnChange(config)
+
+	// the previous rules are kept
+	config.Set("allow_origins", []string{"https://other.com"})
+	config.Set("allow_origin_regex", []string{"https://(api.example.org"})
+	cors.OnChange(config)
+	if allowed, _ := cors.match("https://example.com"); !allowed {
+		t.Error("the previous rules should be kept")
+	}
+	if allowed, _ := cors.match("https://other.com"); allowed || cors.Config.AllowOrigins[0] != "https://example.com" {
+		t.Error("the invalid rules should not be applied")
+	}
+}
@@ -1,28 +1,61 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/kenretto/crane/response"
 	"github.com/kenretto/crane/sessions"
-	"math/rand"
+	"github.com/spf13/viper"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
-// CSRFToken csrf token
+var (
+	// ErrCSRFToken the submitted csrf token is missing or doesn't match the session
+	ErrCSRFToken = errors.New("csrf token error")
+	// ErrCSRFOrigin the origin or referer of the request isn't the current host or a trusted origin
+	ErrCSRFOrigin = errors.New("csrf origin error")
+)
+
+// CSRFToken csrf protection, loaded from the csrf node
+//  each session gets a random token, it is stored in the session and sent to the client in the cookie named session_key (not http only, so that the scripts can read it),
+//  requests with unsafe methods must submit it with the header_name header or the form_field form field,
+//  and their Origin (or Referer if Origin is absent) must be the current host or one of the trusted_origins
 type CSRFToken struct {
-	Domain     string `mapstructure:"domain"`
-	Length     int    `mapstructure:"length"`
-	SessionKey string `mapstructure:"session_key"`
-	Duration   string `mapstructure:"duration"`
-	Path       string `mapstructure:"path"`
-	Secure     bool   `mapstructure:"secure"`
-	HTTPOnly   bool   `mapstructure:"http_only"`
+	Domain         string   `mapstructure:"domain"`
+	Length         int      `mapstructure:"length"` // the number of random bytes, default 32
+	SessionKey     string   `mapstructure:"session_key"`
+	Duration       string   `mapstructure:"duration"`
+	Path           string   `mapstructure:"path"`
+	Secure         bool     `mapstructure:"secure"`
+	HTTPOnly       bool     `mapstructure:"http_only"`
+	HeaderName     string   `mapstructure:"header_name"`     // default X-CSRF-Token
+	FormField      string   `mapstructure:"form_field"`      // default _csrf
+	SafeMethods    []string `mapstructure:"safe_methods"`    // default GET, HEAD, OPTIONS, TRACE
+	ExemptPaths    []string `mapstructure:"exempt_paths"`    // paths not checked, a trailing * matches the prefix, such as /webhook/*
+	TrustedOrigins []string `mapstructure:"trusted_origins"` // such as https://admin.example.com
+
+	rw sync.RWMutex
+}
+
+func (c *CSRFToken) Node() string {
+	return "csrf"
+}
+
+// OnChange reload the configuration
+func (c *CSRFToken) OnChange(viper *viper.Viper) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	_ = viper.Unmarshal(c)
 }
 
-func (c CSRFToken) duration() time.Duration {
+func (c *CSRFToken) duration() time.Duration {
 	d, err := time.ParseDuration(c.Duration)
 	if err != nil {
 		return time.Hour * 2
@@ -30,44 +63,160 @@ func (c CSRFToken) duration() time.Duration {
 	return d
 }
 
-// Token get token
-func (c CSRFToken) Token() string {
-	rand.Seed(time.Now().UnixNano())
-	var token = make([]byte, c.Length)
-	for i := 0; i < c.Length; i++ {
-		token[i] = byte(rand.Intn(127))
+func (c *CSRFToken) sessionKey() string {
+	if c.SessionKey == "" {
+		return "csrf_token"
 	}
+	return c.SessionKey
+}
 
-	return base64.URLEncoding.EncodeToString(token)
+func (c *CSRFToken) headerName() string {
+	if c.HeaderName == "" {
+		return "X-CSRF-Token"
+	}
+	return c.HeaderName
 }
 
-// Valid csrf token valid
-func (c CSRFToken) Valid(ctx *gin.Context) error {
-	token, err := ctx.Cookie(c.SessionKey)
-	if err != nil || token == "" {
-		return errors.New("csrf token error")
+func (c *CSRFToken) formField() string {
+	if c.FormField == "" {
+		return "_csrf"
 	}
-	if token != sessions.Get(ctx, c.SessionKey) {
-		return errors.New("csrf token error")
+	return c.FormField
+}
+
+func (c *CSRFToken) safe(method string) bool {
+	var methods = c.SafeMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
 	}
+	return false
+}
 
+func (c *CSRFToken) exempt(path string) bool {
+	for _, exempt := range c.ExemptPaths {
+		if strings.HasSuffix(exempt, "*") && strings.HasPrefix(path, strings.TrimSuffix(exempt, "*")) || exempt == path {
+			return true
+		}
+	}
+	return false
+}
+
+// NewToken generate a random token with crypto/rand
+func (c *CSRFToken) NewToken() (string, error) {
+	var length = c.Length
+	if length <= 0 {
+		length = 32
+	}
+	var token = make([]byte, length)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// Token get the token of the current session, a new one is generated if the session has none,
+//  it can be rendered into the forms of the templates
+func (c *CSRFToken) Token(ctx *gin.Context) (string, error) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	return c.token(ctx)
+}
+
+func (c *CSRFToken) token(ctx *gin.Context) (string, error) {
+	var token, _ = sessions.Get[string](ctx, c.sessionKey())
+	if token == "" {
+		var err error
+		if token, err = c.NewToken(); err != nil {
+			return "", err
+		}
+		if err = sessions.Set(ctx, c.sessionKey(), token); err != nil {
+			return "", err
+		}
+	}
+	if cookie, err := ctx.Cookie(c.sessionKey()); err != nil || cookie != token {
+		ctx.SetCookie(c.sessionKey(), token, int(c.duration()/time.Second), c.Path, c.Domain, c.Secure, c.HTTPOnly)
+	}
+	return token, nil
+}
+
+// origin check the Origin header, or the Referer header if Origin is absent, requests with neither are left to the token check
+func (c *CSRFToken) origin(ctx *gin.Context) error {
+	var origin = ctx.GetHeader("Origin")
+	if origin == "" || origin == "null" {
+		var referer = ctx.GetHeader("Referer")
+		if referer == "" {
+			if origin == "null" {
+				return ErrCSRFOrigin
+			}
+			return nil
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return ErrCSRFOrigin
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return ErrCSRFOrigin
+	}
+	if strings.EqualFold(u.Host, ctx.Request.Host) {
+		return nil
+	}
+	for _, trusted := range c.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(trusted, "/"), origin) {
+			return nil
+		}
+	}
+	return ErrCSRFOrigin
+}
+
+// Valid csrf token valid
+func (c *CSRFToken) Valid(ctx *gin.Context) error {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	return c.valid(ctx)
+}
+
+func (c *CSRFToken) valid(ctx *gin.Context) error {
+	if err := c.origin(ctx); err != nil {
+		return err
+	}
+
+	var expected, _ = sessions.Get[string](ctx, c.sessionKey())
+	var token = ctx.GetHeader(c.headerName())
+	if token == "" {
+		token = ctx.PostForm(c.formField())
+	}
+	if expected == "" || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		return ErrCSRFToken
+	}
 	return nil
 }
 
-// Handler gin middleware
-func (c CSRFToken) Handler(ctx *gin.Context) {
-	switch ctx.Request.Method {
-	case http.MethodGet:
-		var token = c.Token()
-		sessions.Del(ctx, c.SessionKey)
-		sessions.Set(ctx, c.SessionKey, token)
-		ctx.SetCookie(c.SessionKey, token, int(c.duration()/time.Second), c.Path, c.Domain, c.Secure, c.HTTPOnly)
+// Handler gin middleware, it should be used after the session middleware
+func (c *CSRFToken) Handler(ctx *gin.Context) {
+	c.rw.RLock()
+	var err error
+	switch {
+	case c.safe(ctx.Request.Method):
+		_, err = c.token(ctx)
+	case c.exempt(ctx.Request.URL.Path):
 	default:
-		if err := c.Valid(ctx); err != nil {
-			response.Failed.Msg(err.Error()).End(ctx)
-			ctx.Abort()
-		}
-		sessions.Del(ctx, c.SessionKey)
-		ctx.Next()
+		err = c.valid(ctx)
+	}
+	c.rw.RUnlock()
+
+	if err != nil {
+		response.NewResponse(response.PermissionDenied.Code, nil, err.Error()).End(ctx, http.StatusForbidden)
+		ctx.Abort()
+		return
 	}
+	ctx.Next()
 }
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/sessions"
+	"github.com/kenretto/sessions/memstore"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFToken_Handler(t *testing.T) {
+	var csrf = new(CSRFToken)
+	var c, err = configurator.NewConfigurator("testdata/csrf.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(csrf)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.Use(sessions.Sessions("session", memstore.NewStore([]byte("secret"))), csrf.Handler)
+	router.Any("/*path", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	var cookies = recorder.Result().Cookies()
+	var token string
+	for _, cookie := range cookies {
+		if cookie.Name == "csrf_token" {
+			token = cookie.Value
+		}
+	}
+	if len(token) < 40 {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	var post = func(path, token, origin string) int {
+		var req = httptest.NewRequest(http.MethodPost, path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set("X-CSRF-Token", token)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	var cases = []struct {
+		path, token, origin string
+		code                int
+	}{
+		{"/", "", "", http.StatusForbidden},
+		{"/", token + "x", "", http.StatusForbidden},
+		{"/", token, "", http.StatusOK},
+		{"/", token, "http://example.com", http.StatusOK},
+		{"/", token, "https://admin.example.com", http.StatusOK},
+		{"/", token, "https://evil.com", http.StatusForbidden},
+		{"/webhook/pay", "", "https://evil.com", http.StatusOK},
+	}
+	for _, item := range cases {
+		if code := post(item.path, item.token, item.origin); code != item.code {
+			t.Errorf("%+v: got %d", item, code)
+		}
+	}
+}
@@ -1,19 +1,45 @@
 package middleware
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
-	"github.com/dgrijalva/jwt-go"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/kenretto/crane/database"
+	"github.com/kenretto/crane/logging"
 	"github.com/kenretto/crane/response"
+	"github.com/spf13/viper"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessToken the token type of the access tokens
+	AccessToken = "access"
+	// RefreshToken the token type of the refresh tokens
+	RefreshToken = "refresh"
+)
+
+var (
+	// ErrTokenType the token isn't the expected type, such as using a refresh token to access
+	ErrTokenType = errors.New("jwt: unexpected token type")
+	// ErrTokenCheck AuthInterface.Check failed
+	ErrTokenCheck = errors.New("jwt: token check failed")
 )
 
 // Claims 生成token的结构体
+//  the topic used to be Claims.ID, it's Topic now, and ID is the jti of the embedded jwt.RegisteredClaims,
+//  the callers reading the topic from claims.ID must use claims.Topic instead
 type Claims struct {
-	ID        interface{} // 唯一id
-	CheckData string      // 验证信息
-	jwt.StandardClaims
+	Topic     interface{} `json:"topic"`      // 唯一id
+	CheckData string      `json:"check_data"` // 验证信息
+	TokenType string      `json:"token_type"` // access or refresh
+	jwt.RegisteredClaims
 }
 
 // AuthInterface 参与 jwt 数据表结构体需要实现这些接口
@@ -23,21 +49,311 @@ type AuthInterface interface {
 	FindByTopic(topic interface{}) AuthInterface   // 根据唯一信息标识获取数据信息, 比如根据用户id获取用户信息,需要注意传入的数据类型
 	GetCheckData() string                          // 获取验证信息, jwt加密时, 改信息会一起进行加密, 解密时会解出来然后调用 Check 验证该信息的正确性, 如果是其他数据类型直接转string，比如是个结构体或者map, 直接转为json string
 	Check(ctx *gin.Context, checkData string) bool // 验证信息
-	ExpiredAt() int64                              // 返回过期时间,时间戳
+	ExpiredAt() int64                              // 返回 access token 过期时间,时间戳, 返回 0 则使用配置的 access_ttl
 }
 
-// Auth jwt认证对象
+// TokenPair the access token and the refresh token issued together
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // the lifetime of the access token in seconds
+}
+
+// Auth jwt认证对象, 从 jwt 节点加载配置
+//  tokens are read from the header_key header, or the Authorization header with the Bearer scheme,
+//  access tokens are signed by the active key, and verified with the key named by kid, see KeySet for the key rotation,
+//  refresh tokens are rotated, each of them can be used only once if Revoker is set
 type Auth struct {
-	// 在整个gin.Context 上线文中的 Get 操作的key名,可以获得 AuthEntity
-	ContextKey string
-	// JwtHeaderKey jwt token 在HTTP请求中的header名
-	HeaderKey string
-	// 默认为 false, 如果为 true , 将验证不通过后也会继续往下执行
-	Continue bool
-	// jwt 加密 key
-	Key        interface{}
-	keyFunc    jwt.Keyfunc
+	Config struct {
+		// ContextKey 在整个gin.Context 上线文中的 Get 操作的key名,可以获得 AuthEntity, 默认 auth
+		ContextKey string `mapstructure:"context_key"`
+		// HeaderKey jwt token 在HTTP请求中的header名, 默认 JWT
+		HeaderKey string `mapstructure:"header_key"`
+		// Continue 默认为 false, 如果为 true , 将验证不通过后也会继续往下执行
+		Continue   bool     `mapstructure:"continue"`
+		Issuer     string   `mapstructure:"issuer"`
+		Audience   []string `mapstructure:"audience"`
+		AccessTTL  string   `mapstructure:"access_ttl"`  // default 2h
+		RefreshTTL string   `mapstructure:"refresh_ttl"` // default 720h
+		Leeway     string   `mapstructure:"leeway"`      // the clock skew allowed when validating exp, nbf and iat
+		ActiveKid  string   `mapstructure:"active_kid"`
+		Keys       []JWTKey `mapstructure:"keys"`
+	}
+
 	AuthEntity AuthInterface
+	Revoker    Revoker
+
+	keys   *KeySet
+	logger logging.Logger
+	rw     sync.RWMutex
+}
+
+// NewAuth revoker can be nil, then the tokens can't be revoked and the refresh tokens can be reused until they expire
+func NewAuth(entity AuthInterface, revoker Revoker) *Auth {
+	return &Auth{AuthEntity: entity, Revoker: revoker, logger: logging.Default()}
+}
+
+// SetLogger the logger of the invalid keys on reload, default logging.Default
+func (auth *Auth) SetLogger(logger logging.Logger) {
+	auth.rw.Lock()
+	defer auth.rw.Unlock()
+	auth.logger = logger
+}
+
+func (auth *Auth) Node() string {
+	return "jwt"
+}
+
+// Describe the jwt node is unmarshalled into Config
+func (auth *Auth) Describe() interface{} {
+	return &auth.Config
+}
+
+// OnChange reload the keys, panic if any of them is invalid on the first load,
+//  on the later loads, the error is logged and the current config and keys are kept
+func (auth *Auth) OnChange(viper *viper.Viper) {
+	auth.rw.Lock()
+	defer auth.rw.Unlock()
+	var previous = auth.Config
+	// the slices are decoded into new arrays, so that the previous config isn't changed
+	auth.Config.Audience, auth.Config.Keys = nil, nil
+	_ = viper.Unmarshal(&auth.Config)
+
+	set, err := auth.keySet()
+	if err != nil {
+		if auth.keys == nil {
+			panic(err)
+		}
+		auth.logger.Error(fmt.Errorf("jwt: the keys aren't reloaded, %w", err))
+		auth.Config = previous
+		return
+	}
+	auth.keys = set
+}
+
+// keySet build the key set from Config
+func (auth *Auth) keySet() (*KeySet, error) {
+	var keys = make([]*Key, 0, len(auth.Config.Keys))
+	for _, config := range auth.Config.Keys {
+		key, err := config.Key()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(auth.Config.ActiveKid, keys...)
+}
+
+// SetKeys use the keys instead of the configured ones
+func (auth *Auth) SetKeys(keys *KeySet) {
+	auth.rw.Lock()
+	defer auth.rw.Unlock()
+	auth.keys = keys
+}
+
+// Keys the current key set
+func (auth *Auth) Keys() *KeySet {
+	auth.rw.RLock()
+	defer auth.rw.RUnlock()
+	return auth.keys
+}
+
+func (auth *Auth) contextKey() string {
+	auth.rw.RLock()
+	defer auth.rw.RUnlock()
+	if auth.Config.ContextKey == "" {
+		return "auth"
+	}
+	return auth.Config.ContextKey
+}
+
+func (auth *Auth) headerKey() string {
+	auth.rw.RLock()
+	defer auth.rw.RUnlock()
+	if auth.Config.HeaderKey == "" {
+		return "JWT"
+	}
+	return auth.Config.HeaderKey
+}
+
+func duration(s string, defaultValue time.Duration) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// jti random token id
+func jti() (string, error) {
+	var id = make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func (auth *Auth) claims(entity AuthInterface, tokenType string, now, expire time.Time) (Claims, error) {
+	id, err := jti()
+	if err != nil {
+		return Claims{}, err
+	}
+	return Claims{
+		Topic:     entity.GetTopic(),
+		CheckData: entity.GetCheckData(),
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    auth.Config.Issuer,
+			Audience:  auth.Config.Audience,
+			ExpiresAt: jwt.NewNumericDate(expire),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        id,
+		},
+	}, nil
+}
+
+// Issue issue an access token and a refresh token for the entity
+func (auth *Auth) Issue(entity AuthInterface) (*TokenPair, error) {
+	auth.rw.RLock()
+	defer auth.rw.RUnlock()
+	if auth.keys == nil {
+		return nil, errors.New("jwt: no keys configured")
+	}
+
+	var now = time.Now()
+	var expire = now.Add(duration(auth.Config.AccessTTL, 2*time.Hour))
+	if at := entity.ExpiredAt(); at > 0 {
+		expire = time.Unix(at, 0)
+	}
+	access, err := auth.claims(entity, AccessToken, now, expire)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := auth.claims(entity, RefreshToken, now, now.Add(duration(auth.Config.RefreshTTL, 720*time.Hour)))
+	if err != nil {
+		return nil, err
+	}
+
+	var pair = &TokenPair{TokenType: "Bearer", ExpiresIn: int64(time.Until(expire) / time.Second)}
+	if pair.AccessToken, err = auth.keys.Sign(access); err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = auth.keys.Sign(refresh); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Parse verify the signature and the standard claims (exp, nbf, iat, iss, aud), check the token type and the revocation
+func (auth *Auth) Parse(ctx context.Context, token string, tokenType string) (*Claims, error) {
+	auth.rw.RLock()
+	var keys, config, revoker = auth.keys, auth.Config, auth.Revoker
+	auth.rw.RUnlock()
+	if keys == nil {
+		return nil, errors.New("jwt: no keys configured")
+	}
+
+	var options = []jwt.ParserOption{
+		jwt.WithValidMethods(keys.Algorithms()),
+		jwt.WithLeeway(duration(config.Leeway, 0)),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if len(config.Audience) > 0 {
+		options = append(options, jwt.WithAudience(config.Audience...))
+	}
+
+	var claims = new(Claims)
+	if _, err := jwt.ParseWithClaims(token, claims, keys.Keyfunc, options...); err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrTokenType
+	}
+	if claims.ID == "" {
+		return nil, errors.New("jwt: token has no jti")
+	}
+	if revoker != nil {
+		revoked, err := revoker.Revoked(ctx, claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// Revoke revoke the token until it expires
+func (auth *Auth) Revoke(ctx context.Context, claims *Claims) error {
+	if auth.Revoker == nil {
+		return errors.New("jwt: no revoker")
+	}
+	return auth.Revoker.Revoke(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// Refresh exchange the refresh token for a new token pair, the refresh token is revoked, so it can't be used again
+func (auth *Auth) Refresh(c *gin.Context, refreshToken string) (*TokenPair, error) {
+	claims, err := auth.Parse(c, refreshToken, RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Revoker != nil {
+		if err = auth.Revoke(c, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	var entity = auth.AuthEntity.FindByTopic(claims.Topic)
+	if entity == nil || !entity.Check(c, claims.CheckData) {
+		return nil, ErrTokenCheck
+	}
+	return auth.Issue(entity)
+}
+
+// RefreshHandler gin handler exchanging the refresh_token of the json body or the form for a new token pair
+func (auth *Auth) RefreshHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBind(&req); err != nil {
+		response.NewResponse(response.AuthFail.Code, nil, err.Error()).End(c, http.StatusUnauthorized)
+		return
+	}
+	pair, err := auth.Refresh(c, req.RefreshToken)
+	if err != nil {
+		response.NewResponse(response.AuthFail.Code, nil, err.Error()).End(c, http.StatusUnauthorized)
+		return
+	}
+	response.NewResponse(response.Success.Code, pair).End(c)
+}
+
+// JWKSHandler gin handler publishing the public keys, usually at /.well-known/jwks.json
+func (auth *Auth) JWKSHandler(c *gin.Context) {
+	var keys = auth.Keys()
+	if keys == nil {
+		c.JSON(http.StatusOK, JWKS{Keys: []JWK{}})
+		return
+	}
+	c.JSON(http.StatusOK, keys.JWKS())
+}
+
+// token read the token from the header_key header, or the Authorization header with the Bearer scheme
+func (auth *Auth) token(c *gin.Context) string {
+	if token := c.GetHeader(auth.headerKey()); token != "" {
+		return token
+	}
+	var authorization = c.GetHeader("Authorization")
+	if len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		return authorization[7:]
+	}
+	return ""
 }
 
 // VerifyAuth 验证用户有效性
@@ -47,55 +363,46 @@ func VerifyAuth(auth *Auth) gin.HandlerFunc {
 
 // verifyAuth 验证用户有效性
 func (auth *Auth) verifyAuth(c *gin.Context) {
-	token := c.GetHeader(auth.HeaderKey)
-	if token != "" {
-		claims, err := ParseToken(token, auth.keyFunc)
-
+	if token := auth.token(c); token != "" {
+		claims, err := auth.Parse(c, token, AccessToken)
 		if err == nil {
-			var entity = auth.AuthEntity.FindByTopic(claims.ID)
-			if entity.Check(c, claims.CheckData) {
-				c.Set(auth.ContextKey, entity) // 向下设置用户信息,控制器可直接获取
-				c.Header(auth.HeaderKey, token)
+			var entity = auth.AuthEntity.FindByTopic(claims.Topic)
+			if entity != nil && entity.Check(c, claims.CheckData) {
+				c.Set(auth.contextKey(), entity) // 向下设置用户信息,控制器可直接获取
+				c.Set(auth.contextKey()+"_claims", claims)
 				c.Next()
 				return
 			}
-
-			if !auth.Continue {
-				response.AuthFail.Msg("auth failed").End(c, http.StatusUnauthorized)
-				c.Abort()
-				return
-			}
-			c.Next()
-			return
 		}
 	}
 
-	if !auth.Continue {
-		response.AuthFail.Msg("auth failed").End(c, http.StatusUnauthorized)
+	auth.rw.RLock()
+	var next = auth.Config.Continue
+	auth.rw.RUnlock()
+	if !next {
+		response.NewResponse(response.AuthFail.Code, nil, "auth failed").End(c, http.StatusUnauthorized)
 		c.Abort()
 		return
 	}
 	c.Next()
 }
 
-func newClaims(entity AuthInterface) Claims {
-	return Claims{
-		entity.GetTopic(),
-		entity.GetCheckData(),
-		jwt.StandardClaims{
-			ExpiresAt: entity.ExpiredAt(),
-		},
-	}
-}
-
-// NewToken 根据传入的结构体(非空结构体)返回一个token
+// NewToken 根据传入的结构体(非空结构体)返回一个 HS256 access token, 不包含 kid, 只能由只配置了一个 HS256 密钥的 Auth 验证,
+//  过期时间为 ExpiredAt, 返回 0 时为 2 小时, 包含 Auth.Parse 要求的 jti 和 iat, 但不包含 iss 和 aud, 配置了 issuer 或 audience 时无法通过验证
+//
+// Deprecated: use Auth.Issue, it signs with the configured keys and issues the refresh token as well
 func NewToken(entity AuthInterface, key interface{}) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims(entity))
-	rs, err := token.SignedString(key)
+	var now = time.Now()
+	var expire = now.Add(2 * time.Hour)
+	if at := entity.ExpiredAt(); at > 0 {
+		expire = time.Unix(at, 0)
+	}
+	var auth = new(Auth)
+	claims, err := auth.claims(entity, AccessToken, now, expire)
 	if err != nil {
 		return "", err
 	}
-	return rs, nil
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
 }
 
 // ParseToken 根据传入 token 得到 Claims 信息
@@ -0,0 +1,283 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// JWTKey the key config of the jwt node
+//  algorithm: HS256, HS384, HS512, RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA
+//  secret: the secret of the HS algorithms
+//  private_key, public_key: PEM content or the path of the PEM file, keys with only the public key can only verify tokens
+type JWTKey struct {
+	Kid        string `mapstructure:"kid"`
+	Algorithm  string `mapstructure:"algorithm"`
+	Secret     string `mapstructure:"secret"`
+	PrivateKey string `mapstructure:"private_key"`
+	PublicKey  string `mapstructure:"public_key"`
+}
+
+// Key a signing key identified by kid
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	sign   interface{}
+	verify interface{}
+}
+
+// NewKey create a key, for HS algorithms signKey is the []byte secret, the others use the private key as signKey,
+//  a public key creates a verify only key
+func NewKey(kid string, method jwt.SigningMethod, signKey interface{}) (*Key, error) {
+	var key = &Key{ID: kid, Method: method, sign: signKey}
+	switch k := signKey.(type) {
+	case []byte:
+		key.verify = k
+	case *rsa.PrivateKey:
+		key.verify = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.verify = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.verify = k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.sign, key.verify = nil, k
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %T", signKey)
+	}
+	return key, nil
+}
+
+// pem the PEM content, or the content of the file if the value is a path
+func pem(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
+
+// Key parse the key config
+func (config JWTKey) Key() (*Key, error) {
+	var method = jwt.GetSigningMethod(config.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("jwt: unsupported algorithm %s of key %s", config.Algorithm, config.Kid)
+	}
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if config.Secret == "" {
+			return nil, fmt.Errorf("jwt: the secret of key %s is empty", config.Kid)
+		}
+		return NewKey(config.Kid, method, []byte(config.Secret))
+	}
+
+	var content = config.PrivateKey
+	var private = content != ""
+	if !private {
+		content = config.PublicKey
+	}
+	if content == "" {
+		return nil, fmt.Errorf("jwt: key %s has neither private_key nor public_key", config.Kid)
+	}
+	data, err := pem(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if private {
+			key, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+		} else {
+			key, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		}
+	case *jwt.SigningMethodECDSA:
+		if private {
+			key, err = jwt.ParseECPrivateKeyFromPEM(data)
+		} else {
+			key, err = jwt.ParseECPublicKeyFromPEM(data)
+		}
+	case *jwt.SigningMethodEd25519:
+		if private {
+			key, err = jwt.ParseEdPrivateKeyFromPEM(data)
+		} else {
+			key, err = jwt.ParseEdPublicKeyFromPEM(data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt: key %s: %w", config.Kid, err)
+	}
+	return NewKey(config.Kid, method, key)
+}
+
+// KeySet the keys used to sign and verify tokens, tokens are signed by the active key,
+//  and verified by the key named by the kid header, so that the keys can be rotated by adding a new active key and removing the old one after the tokens signed by it expire
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+	order  []string
+}
+
+// NewKeySet the active key must be able to sign, an empty active creates a verify only set, such as the keys fetched from a JWKS endpoint
+func NewKeySet(active string, keys ...*Key) (*KeySet, error) {
+	var set = &KeySet{active: active, keys: make(map[string]*Key)}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("jwt: duplicate kid %s", key.ID)
+		}
+		set.keys[key.ID] = key
+		set.order = append(set.order, key.ID)
+	}
+	if key, ok := set.keys[active]; active != "" && (!ok || key.sign == nil) {
+		return nil, fmt.Errorf("jwt: the active key %s doesn't exist or can't sign", active)
+	}
+	return set, nil
+}
+
+// Sign sign the claims with the active key
+func (set *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, ok := set.keys[set.active]
+	if !ok {
+		return "", errors.New("jwt: the key set is verify only")
+	}
+	var token = jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.sign)
+}
+
+// Keyfunc find the verifying key by the kid header, the algorithm of the token must be the algorithm of the key
+func (set *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(set.order) == 1 {
+		kid = set.order[0]
+	}
+	key, ok := set.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown kid %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("jwt: unexpected algorithm %s of kid %s", token.Method.Alg(), kid)
+	}
+	return key.verify, nil
+}
+
+// Algorithms the algorithms of the keys
+func (set *KeySet) Algorithms() []string {
+	var result = make([]string, 0, len(set.order))
+	for _, kid := range set.order {
+		result = append(result, set.keys[kid].Method.Alg())
+	}
+	return result
+}
+
+// JWKS the public keys as a JSON Web Key Set, HMAC keys are secret and never published
+func (set *KeySet) JWKS() JWKS {
+	var result = JWKS{Keys: make([]JWK, 0, len(set.order))}
+	for _, kid := range set.order {
+		if jwk, err := EncodeJWK(set.keys[kid]); err == nil {
+			result.Keys = append(result.Keys, jwk)
+		}
+	}
+	return result
+}
+
+// JWKS JSON Web Key Set, RFC 7517
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK JSON Web Key of a public key, RFC 7517 and RFC 8037
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+var curves = map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+
+// EncodeJWK encode the public key of the key
+func EncodeJWK(key *Key) (JWK, error) {
+	var encode = base64.RawURLEncoding.EncodeToString
+	var jwk = JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+	switch k := key.verify.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty, jwk.N, jwk.E = "RSA", encode(k.N.Bytes()), encode(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		var size = (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty, jwk.Crv = "EC", k.Curve.Params().Name
+		jwk.X, jwk.Y = encode(k.X.FillBytes(make([]byte, size))), encode(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", encode(k)
+	default:
+		return JWK{}, errors.New("jwt: only public keys can be encoded as jwk")
+	}
+	return jwk, nil
+}
+
+// DecodeJWK decode the jwk as a verify only key
+func DecodeJWK(jwk JWK) (*Key, error) {
+	var decode = base64.RawURLEncoding.DecodeString
+	var method = jwt.GetSigningMethod(jwk.Alg)
+	var key crypto.PublicKey
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if method == nil {
+			method = jwt.SigningMethodRS256
+		}
+	case "EC":
+		curve, ok := curves[jwk.Crv]
+		if !ok {
+			return nil, fmt.Errorf("jwt: unsupported curve %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if method == nil {
+			method = map[string]jwt.SigningMethod{"P-256": jwt.SigningMethodES256, "P-384": jwt.SigningMethodES384, "P-521": jwt.SigningMethodES512}[jwk.Crv]
+		}
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwt: unsupported curve %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("jwt: invalid Ed25519 public key")
+		}
+		key = ed25519.PublicKey(x)
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("jwt: unsupported key type %s", jwk.Kty)
+	}
+	return NewKey(jwk.Kid, method, key)
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/kenretto/crane/redis"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked the token has been revoked
+var ErrTokenRevoked = errors.New("jwt: token revoked")
+
+// Revoker the blacklist of the revoked tokens, identified by jti,
+//  the token is kept in the blacklist until it expires,
+//  Revoke returns ErrTokenRevoked if the jti is already revoked, so that a refresh token can be used only once even if it is submitted concurrently
+type Revoker interface {
+	Revoke(ctx context.Context, jti string, until time.Time) error
+	Revoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RedisRevoker the blacklist stored in redis, shared by all the processes
+type RedisRevoker struct {
+	redis  *redis.Redis
+	prefix string
+}
+
+// NewRedisRevoker the key in redis is prefix + jti, the default prefix is jwt_revoked_
+func NewRedisRevoker(r *redis.Redis, prefix string) *RedisRevoker {
+	if prefix == "" {
+		prefix = "jwt_revoked_"
+	}
+	return &RedisRevoker{redis: r, prefix: prefix}
+}
+
+// Revoke add the jti to the blacklist until the time
+func (revoker *RedisRevoker) Revoke(ctx context.Context, jti string, until time.Time) error {
+	var ttl = time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	ok, err := revoker.redis.Instance().SetNX(ctx, revoker.prefix+jti, 1, ttl).Result()
+	if err == nil && !ok {
+		return ErrTokenRevoked
+	}
+	return err
+}
+
+// Revoked whether the jti is in the blacklist
+func (revoker *RedisRevoker) Revoked(ctx context.Context, jti string) (bool, error) {
+	n, err := revoker.redis.Instance().Exists(ctx, revoker.prefix+jti).Result()
+	return n > 0, err
+}
+
+// MemoryRevoker the blacklist in the memory of the current process, for tests and single process deployments
+type MemoryRevoker struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevoker memory blacklist
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{revoked: make(map[string]time.Time)}
+}
+
+// Revoke add the jti to the blacklist until the time, the expired entries are removed
+func (revoker *MemoryRevoker) Revoke(_ context.Context, jti string, until time.Time) error {
+	revoker.mu.Lock()
+	defer revoker.mu.Unlock()
+	var now = time.Now()
+	for key, expire := range revoker.revoked {
+		if now.After(expire) {
+			delete(revoker.revoked, key)
+		}
+	}
+	if _, ok := revoker.revoked[jti]; ok {
+		return ErrTokenRevoked
+	}
+	if until.After(now) {
+		revoker.revoked[jti] = until
+	}
+	return nil
+}
+
+// Revoked whether the jti is in the blacklist
+func (revoker *MemoryRevoker) Revoked(_ context.Context, jti string) (bool, error) {
+	revoker.mu.Lock()
+	defer revoker.mu.Unlock()
+	until, ok := revoker.revoked[jti]
+	return ok && time.Now().Before(until), nil
+}
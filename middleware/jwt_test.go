@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/crane/logging"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type member struct {
+	ID       int
+	Password string
+}
+
+func (member) TableName() string {
+	return "member"
+}
+
+func (m member) GetTopic() interface{} {
+	return m.ID
+}
+
+func (m member) FindByTopic(topic interface{}) AuthInterface {
+	// numbers are decoded as float64 from json
+	if id, ok := topic.(float64); ok && int(id) == 1 {
+		return member{ID: 1, Password: "hash"}
+	}
+	return nil
+}
+
+func (m member) GetCheckData() string {
+	return m.Password
+}
+
+func (m member) Check(_ *gin.Context, checkData string) bool {
+	return checkData == m.Password
+}
+
+func (m member) ExpiredAt() int64 {
+	return 0
+}
+
+func TestAuth(t *testing.T) {
+	var auth = NewAuth(member{}, NewMemoryRevoker())
+	var c, err = configurator.NewConfigurator("testdata/jwt.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(auth)
+
+	pair, err := auth.Issue(member{ID: 1, Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Parse(context.Background(), pair.RefreshToken, AccessToken); err != ErrTokenType {
+		t.Errorf("refresh token should not be used as access token, got %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.POST("/refresh", auth.RefreshHandler)
+	router.GET("/me", VerifyAuth(auth), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "%v", ctx.MustGet("auth").(AuthInterface).GetTopic())
+	})
+
+	var request = func(method, path, token string, body []byte) *httptest.ResponseRecorder {
+		var req = httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := request(http.MethodGet, "/me", pair.AccessToken, nil); recorder.Code != http.StatusOK || recorder.Body.String() != "1" {
+		t.Fatalf("unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := request(http.MethodGet, "/me", pair.RefreshToken, nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expect 401, got %d", recorder.Code)
+	}
+
+	var body, _ = json.Marshal(map[string]string{"refresh_token": pair.RefreshToken})
+	var recorder = request(http.MethodPost, "/refresh", "", body)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("refresh failed %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := request(http.MethodPost, "/refresh", "", body); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("the refresh token should be used only once, got %d", recorder.Code)
+	}
+
+	claims, err := auth.Parse(context.Background(), pair.AccessToken, AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != "crane" || claims.ID == "" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if err := auth.Revoke(context.Background(), claims); err != nil {
+		t.Fatal(err)
+	}
+	if recorder := request(http.MethodGet, "/me", pair.AccessToken, nil); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token should be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestAuth_InvalidKeys(t *testing.T) {
+	var auth = NewAuth(member{}, nil)
+	auth.SetLogger(logging.Nop())
+	var config = viper.New()
+	config.Set("active_kid", "2020-12")
+	config.Set("keys", []map[string]interface{}{{"kid": "2020-12", "algorithm": "HS256", "secret": "a-secret-long-enough-for-hs256"}})
+	auth.OnChange(config)
+	var keys = auth.Keys()
+
+	config.Set("active_kid", "2021-01")
+	config.Set("keys", []map[string]interface{}{{"kid": "2021-01", "algorithm": "unknown"}})
+	auth.OnChange(config)
+	if auth.Keys() != keys || auth.Config.ActiveKid != "2020-12" || auth.Config.Keys[0].Kid != "2020-12" {
+		t.Error("the current keys should be kept")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("the invalid keys should panic on the first load")
+		}
+	}()
+	var fresh = NewAuth(member{}, nil)
+	fresh.OnChange(config)
+}
+
+func TestNewToken(t *testing.T) {
+	var auth = NewAuth(member{}, nil)
+	var config = viper.New()
+	config.Set("keys", []map[string]interface{}{{"kid": "default", "algorithm": "HS256", "secret": "a-secret-long-enough-for-hs256"}})
+	auth.OnChange(config)
+
+	token, err := NewToken(member{ID: 1, Password: "hash"}, []byte("a-secret-long-enough-for-hs256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.GET("/me", VerifyAuth(auth), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "%v", ctx.MustGet("auth").(AuthInterface).GetTopic())
+	})
+	var req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("JWT", token)
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "1" {
+		t.Errorf("the token of NewToken should pass VerifyAuth, got %d %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	var keys []*Key
+	for kid, item := range map[string]struct {
+		method jwt.SigningMethod
+		key    interface{}
+	}{
+		"rsa":  {jwt.SigningMethodRS256, rsaKey},
+		"ec":   {jwt.SigningMethodES256, ecKey},
+		"ed":   {jwt.SigningMethodEdDSA, edKey},
+		"hmac": {jwt.SigningMethodHS256, []byte("secret")},
+	} {
+		key, err := NewKey(kid, item.method, item.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, active := range []string{"rsa", "ec", "ed"} {
+		set, err := NewKeySet(active, keys...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := set.Sign(jwt.RegisteredClaims{Subject: active})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// verify with the keys decoded from the published jwks
+		var jwks = set.JWKS()
+		if len(jwks.Keys) != 3 {
+			t.Fatalf("hmac keys should not be published, got %d keys", len(jwks.Keys))
+		}
+		var public []*Key
+		for _, jwk := range jwks.Keys {
+			key, err := DecodeJWK(jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			public = append(public, key)
+		}
+		verify, err := NewKeySet("", public...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var claims jwt.RegisteredClaims
+		if _, err := jwt.ParseWithClaims(token, &claims, verify.Keyfunc); err != nil || claims.Subject != active {
+			t.Errorf("%s: %v %+v", active, err, claims)
+		}
+	}
+}
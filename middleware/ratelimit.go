@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/logging"
+	"github.com/kenretto/crane/redis"
+	"github.com/kenretto/crane/response"
+	"github.com/spf13/viper"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitRule the rate limit rule of a route group
+//  algorithm: token_bucket (default) or sliding_window
+//  limit: the maximum number of requests in the window, for token_bucket the tokens are refilled at limit/window
+//  burst: the capacity of the token bucket, default limit, ignored by sliding_window
+//  key: ip (default), topic (the topic of the authenticated AuthInterface stored with context_key), route, or a name registered by RateLimit.KeyFunc
+type RateLimitRule struct {
+	Algorithm  string `mapstructure:"algorithm"`
+	Limit      int    `mapstructure:"limit"`
+	Burst      int    `mapstructure:"burst"`
+	Window     string `mapstructure:"window"` // default 1m
+	Key        string `mapstructure:"key"`
+	ContextKey string `mapstructure:"context_key"` // the context key of Auth, used by the topic key
+}
+
+func (rule RateLimitRule) window() time.Duration {
+	d, err := time.ParseDuration(rule.Window)
+	if err != nil || d <= 0 {
+		return time.Minute
+	}
+	return d
+}
+
+func (rule RateLimitRule) burst() int {
+	if rule.Burst > 0 {
+		return rule.Burst
+	}
+	return rule.Limit
+}
+
+// KeyFunc returns the key of the request being limited, requests with the same key share the limit,
+//  returning an empty string skips the limit
+type KeyFunc func(ctx *gin.Context) string
+
+// limitResult the result of a rate limit check
+type limitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration // the duration until the limit is fully restored
+	retryAfter time.Duration // the duration until the next request is allowed, only set when rejected
+}
+
+// limiter the storage of the counters
+type limiter interface {
+	allow(ctx context.Context, key string, rule RateLimitRule, now time.Time) (limitResult, error)
+}
+
+// RateLimit rate limiting middleware, the rules are configured by route group under the rate_limit node:
+//  rate_limit:
+//    prefix: rate_limit_
+//    rules:
+//      api: {algorithm: token_bucket, limit: 100, window: 1m, key: ip}
+//      login: {algorithm: sliding_window, limit: 5, window: 1m, key: route}
+//  then use RateLimit.Handler("api") as the middleware of the route group,
+//  the counters are stored in redis atomically by lua scripts, the memory is used if redis isn't available
+type RateLimit struct {
+	Config struct {
+		Prefix string                   `mapstructure:"prefix"` // the key prefix in redis, default rate_limit_
+		Rules  map[string]RateLimitRule `mapstructure:"rules"`
+	}
+
+	redis  *redis.Redis
+	memory *memoryLimiter
+	keys   map[string]KeyFunc
+	logger logging.Logger
+	rw     sync.RWMutex
+}
+
+// NewRateLimit the counters are stored in r, if r is nil, they are stored in the memory of the current process
+func NewRateLimit(r *redis.Redis) *RateLimit {
+	var rl = &RateLimit{redis: r, memory: newMemoryLimiter(), logger: logging.Default()}
+	rl.keys = map[string]KeyFunc{
+		"ip": func(ctx *gin.Context) string {
+			return ctx.ClientIP()
+		},
+		"route": func(ctx *gin.Context) string {
+			return ctx.Request.Method + " " + ctx.FullPath()
+		},
+	}
+	return rl
+}
+
+func (rl *RateLimit) Node() string {
+	return "rate_limit"
+}
+
+// Describe the rate_limit node is unmarshalled into Config
+func (rl *RateLimit) Describe() interface{} {
+	return &rl.Config
+}
+
+// SetLogger the logger of the invalid rules, default logging.Default
+func (rl *RateLimit) SetLogger(logger logging.Logger) {
+	rl.rw.Lock()
+	defer rl.rw.Unlock()
+	rl.logger = logger
+}
+
+// OnChange the rules take effect on the next request, the invalid rules are logged and dropped, so that their groups aren't limited
+func (rl *RateLimit) OnChange(viper *viper.Viper) {
+	rl.rw.Lock()
+	defer rl.rw.Unlock()
+	rl.Config.Prefix, rl.Config.Rules = "", nil
+	_ = viper.Unmarshal(&rl.Config)
+	for group, rule := range rl.Config.Rules {
+		if err := rl.validate(rule); err != nil {
+			rl.logger.Error(fmt.Errorf("rate limit: the rule %s is dropped: %w", group, err))
+			delete(rl.Config.Rules, group)
+		}
+	}
+}
+
+// validate check the algorithm and the key of the rule, the custom keys must be registered by KeyFunc before the rules are loaded
+func (rl *RateLimit) validate(rule RateLimitRule) error {
+	switch rule.Algorithm {
+	case "", "token_bucket", "sliding_window":
+	default:
+		return fmt.Errorf("unknown algorithm %s", rule.Algorithm)
+	}
+	switch rule.Key {
+	case "", "ip", "topic":
+		return nil
+	}
+	if _, ok := rl.keys[rule.Key]; !ok {
+		return fmt.Errorf("unknown key %s", rule.Key)
+	}
+	return nil
+}
+
+// KeyFunc register a custom key function, it can be referenced by name in the key of the rules
+func (rl *RateLimit) KeyFunc(name string, fn KeyFunc) {
+	rl.rw.Lock()
+	defer rl.rw.Unlock()
+	rl.keys[name] = fn
+}
+
+func (rl *RateLimit) rule(group string) (RateLimitRule, string, bool) {
+	rl.rw.RLock()
+	defer rl.rw.RUnlock()
+	rule, ok := rl.Config.Rules[group]
+	var prefix = rl.Config.Prefix
+	if prefix == "" {
+		prefix = "rate_limit_"
+	}
+	return rule, prefix, ok && rule.Limit > 0
+}
+
+func (rl *RateLimit) key(ctx *gin.Context, rule RateLimitRule) string {
+	switch rule.Key {
+	case "", "ip":
+		return ctx.ClientIP()
+	case "topic":
+		var contextKey = rule.ContextKey
+		if contextKey == "" {
+			contextKey = "auth"
+		}
+		if entity, ok := ctx.Get(contextKey); ok {
+			if auth, ok := entity.(AuthInterface); ok {
+				return fmt.Sprintf("topic:%v", auth.GetTopic())
+			}
+		}
+		// not authenticated, fall back to the client ip
+		return ctx.ClientIP()
+	}
+
+	rl.rw.RLock()
+	fn, ok := rl.keys[rule.Key]
+	rl.rw.RUnlock()
+	if !ok {
+		// the rules are validated when they are loaded, it can't happen
+		return ctx.ClientIP()
+	}
+	return fn(ctx)
+}
+
+func (rl *RateLimit) log() logging.Logger {
+	rl.rw.RLock()
+	defer rl.rw.RUnlock()
+	return rl.logger
+}
+
+// limiter the redis limiter if redis is available
+func (rl *RateLimit) limiter() limiter {
+	if rl.redis != nil {
+		if client := rl.redis.Instance(); client != nil {
+			return &redisLimiter{client: client}
+		}
+	}
+	return rl.memory
+}
+
+// Handler gin middleware limiting the requests with the rule of group, requests are not limited if the rule isn't configured
+func (rl *RateLimit) Handler(group string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		rule, prefix, ok := rl.rule(group)
+		if !ok {
+			ctx.Next()
+			return
+		}
+		var key = rl.key(ctx, rule)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		key = prefix + group + ":" + key
+
+		var now = time.Now()
+		result, err := rl.limiter().allow(ctx, key, rule, now)
+		if err != nil {
+			// redis is unavailable, limit in the memory of the current process
+			if result, err = rl.memory.allow(ctx, key, rule, now); err != nil {
+				rl.log().Error(err)
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(result.limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(result.remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(seconds(result.reset)))
+		if !result.allowed {
+			ctx.Header("Retry-After", strconv.Itoa(seconds(result.retryAfter)))
+			response.ManyRequest.End(ctx, http.StatusTooManyRequests)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// seconds round up to seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
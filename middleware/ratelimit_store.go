@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	goredis "github.com/go-redis/redis/v8"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// tokenBucketScript refill the bucket by the elapsed time and take a token
+//  KEYS[1] bucket key, ARGV: capacity, tokens per millisecond, now in milliseconds
+//  returns allowed, remaining, milliseconds until full, milliseconds until the next token
+var tokenBucketScript = goredis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+local retry = 0
+if allowed == 0 then
+	retry = math.ceil((1 - tokens) / rate)
+end
+return {allowed, math.floor(tokens), math.ceil((capacity - tokens) / rate), retry}
+`)
+
+// slidingWindowScript count the requests in the window with a sorted set scored by the request time
+//  KEYS[1] window key, ARGV: limit, window in milliseconds, now in milliseconds, unique member
+//  returns allowed, remaining, milliseconds until the oldest request leaves the window
+var slidingWindowScript = goredis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+local reset = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// redisLimiter counters shared by all the processes using the same redis
+type redisLimiter struct {
+	client goredis.Cmdable
+}
+
+func (l *redisLimiter) allow(ctx context.Context, key string, rule RateLimitRule, now time.Time) (limitResult, error) {
+	var window = rule.window()
+	var ms = now.UnixNano() / int64(time.Millisecond)
+	switch rule.Algorithm {
+	case "", "token_bucket":
+		var rate = float64(rule.Limit) / float64(window/time.Millisecond)
+		values, err := int64s(tokenBucketScript.Run(ctx, l.client, []string{key}, rule.burst(), rate, ms))
+		if err != nil {
+			return limitResult{}, err
+		}
+		return limitResult{
+			allowed:    values[0] == 1,
+			limit:      rule.burst(),
+			remaining:  int(values[1]),
+			reset:      time.Duration(values[2]) * time.Millisecond,
+			retryAfter: time.Duration(values[3]) * time.Millisecond,
+		}, nil
+	case "sliding_window":
+		var member = fmt.Sprintf("%d-%d", ms, rand.Int63())
+		values, err := int64s(slidingWindowScript.Run(ctx, l.client, []string{key}, rule.Limit, int64(window/time.Millisecond), ms, member))
+		if err != nil {
+			return limitResult{}, err
+		}
+		var result = limitResult{
+			allowed:   values[0] == 1,
+			limit:     rule.Limit,
+			remaining: int(values[1]),
+			reset:     time.Duration(values[2]) * time.Millisecond,
+		}
+		if !result.allowed {
+			result.retryAfter = result.reset
+		}
+		return result, nil
+	}
+	return limitResult{}, fmt.Errorf("rate limit: unknown algorithm %s", rule.Algorithm)
+}
+
+// int64s the integers returned by the scripts
+func int64s(cmd *goredis.Cmd) ([]int64, error) {
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, err
+	}
+	items, ok := result.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rate limit: unexpected script result %v", result)
+	}
+	var values = make([]int64, len(items))
+	for i, item := range items {
+		if values[i], ok = item.(int64); !ok {
+			return nil, fmt.Errorf("rate limit: unexpected script result %v", result)
+		}
+	}
+	return values, nil
+}
+
+// memoryEntry the state of a key, tokens and updated for token bucket, requests for sliding window
+type memoryEntry struct {
+	tokens   float64
+	updated  time.Time
+	requests []time.Time
+	expire   time.Time
+}
+
+// memoryLimiter counters in the memory of the current process, the expired keys are swept every minute
+type memoryLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	swept   time.Time
+}
+
+func newMemoryLimiter() *memoryLimiter {
+	return &memoryLimiter{entries: make(map[string]*memoryEntry)}
+}
+
+func (l *memoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	for key, entry := range l.entries {
+		if now.After(entry.expire) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+func (l *memoryLimiter) allow(_ context.Context, key string, rule RateLimitRule, now time.Time) (limitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	var window = rule.window()
+	switch rule.Algorithm {
+	case "", "token_bucket":
+		var capacity = float64(rule.burst())
+		var rate = float64(rule.Limit) / float64(window) // tokens per nanosecond
+		entry, ok := l.entries[key]
+		if !ok {
+			entry = &memoryEntry{tokens: capacity, updated: now}
+			l.entries[key] = entry
+		}
+		if elapsed := now.Sub(entry.updated); elapsed > 0 {
+			entry.tokens = math.Min(capacity, entry.tokens+float64(elapsed)*rate)
+		}
+		entry.updated = now
+
+		var result = limitResult{limit: rule.burst()}
+		if entry.tokens >= 1 {
+			entry.tokens--
+			result.allowed = true
+		} else {
+			result.retryAfter = time.Duration(math.Ceil((1 - entry.tokens) / rate))
+		}
+		result.remaining = int(entry.tokens)
+		result.reset = time.Duration(math.Ceil((capacity - entry.tokens) / rate))
+		entry.expire = now.Add(time.Duration(capacity / rate))
+		return result, nil
+	case "sliding_window":
+		entry, ok := l.entries[key]
+		if !ok {
+			entry = &memoryEntry{}
+			l.entries[key] = entry
+		}
+		var i = 0
+		for i < len(entry.requests) && !entry.requests[i].After(now.Add(-window)) {
+			i++
+		}
+		entry.requests = entry.requests[i:]
+
+		var result = limitResult{limit: rule.Limit}
+		if len(entry.requests) < rule.Limit {
+			entry.requests = append(entry.requests, now)
+			result.allowed = true
+		}
+		result.remaining = rule.Limit - len(entry.requests)
+		if len(entry.requests) > 0 {
+			result.reset = entry.requests[0].Add(window).Sub(now)
+		}
+		if !result.allowed {
+			result.retryAfter = result.reset
+		}
+		entry.expire = now.Add(window)
+		return result, nil
+	}
+	return limitResult{}, fmt.Errorf("rate limit: unknown algorithm %s", rule.Algorithm)
+}
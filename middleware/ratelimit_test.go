@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/configurator"
+	"github.com/kenretto/crane/logging"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit_Handler(t *testing.T) {
+	var rl = NewRateLimit(nil)
+	var c, err = configurator.NewConfigurator("testdata/ratelimit.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(rl)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.GET("/api", rl.Handler("api"), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	router.GET("/free", rl.Handler("undefined"), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	var request = func(path string) *httptest.ResponseRecorder {
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	for i := 0; i < 2; i++ {
+		if recorder := request("/api"); recorder.Code != http.StatusOK {
+			t.Fatalf("request %d should be allowed, got %d", i, recorder.Code)
+		}
+	}
+	var recorder = request("/api")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expect 429, got %d", recorder.Code)
+	}
+	if recorder.Header().Get("RateLimit-Limit") != "2" || recorder.Header().Get("RateLimit-Remaining") != "0" || recorder.Header().Get("Retry-After") != "30" {
+		t.Errorf("unexpected headers %v", recorder.Header())
+	}
+	if recorder := request("/free"); recorder.Code != http.StatusOK {
+		t.Errorf("the group without rule should not be limited, got %d", recorder.Code)
+	}
+}
+
+func TestMemoryLimiter_SlidingWindow(t *testing.T) {
+	var limiter = newMemoryLimiter()
+	var rule = RateLimitRule{Algorithm: "sliding_window", Limit: 2, Window: "1s"}
+	var now = time.Now()
+
+	for i, expect := range []bool{true, true, false} {
+		result, err := limiter.allow(context.Background(), "key", rule, now.Add(time.Duration(i)*100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.allowed != expect {
+			t.Errorf("request %d: expect %v", i, expect)
+		}
+	}
+
+	result, _ := limiter.allow(context.Background(), "key", rule, now.Add(time.Second+time.Millisecond))
+	if !result.allowed || result.remaining != 0 {
+		t.Errorf("the first request should have left the window, got %+v", result)
+	}
+}
+
+func TestRateLimit_InvalidRules(t *testing.T) {
+	var rl = NewRateLimit(nil)
+	rl.SetLogger(logging.Nop())
+	var config = viper.New()
+	config.Set("rules", map[string]interface{}{
+		"algorithm": map[string]interface{}{"algorithm": "token_bucker", "limit": 1},
+		"key":       map[string]interface{}{"limit": 1, "key": "uesr"},
+		"valid":     map[string]interface{}{"limit": 1},
+	})
+	rl.OnChange(config)
+	if _, ok := rl.Config.Rules["valid"]; !ok || len(rl.Config.Rules) != 1 {
+		t.Fatalf("the invalid rules should be dropped, got %v", rl.Config.Rules)
+	}
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	for _, group := range []string{"algorithm", "key"} {
+		router.GET("/"+group, rl.Handler(group), func(ctx *gin.Context) {
+			ctx.String(http.StatusOK, "ok")
+		})
+	}
+	for _, path := range []string{"/algorithm", "/algorithm", "/key", "/key"} {
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: the group of the dropped rule shouldn't be limited, got %d", path, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/logging"
+	"github.com/kenretto/crane/response"
+	"net/http"
+	"sync/atomic"
+)
+
+// Authorizer decides whether the subject (the topic of the AuthInterface) is allowed, see package rbac
+type Authorizer interface {
+	Can(ctx context.Context, subject, permission string) (bool, error)
+	CanRoute(ctx context.Context, subject, method, path string) (bool, error)
+}
+
+// authorization the authorizer and the context key set by SetAuthorizer
+type authorization struct {
+	authorizer Authorizer
+	contextKey string
+}
+
+// current the authorization, it's replaced as a whole so that the requests never see a partial one
+var current atomic.Value
+
+// SetAuthorizer set the authorizer used by Require and RequireRoute, it's safe to call it while serving,
+//  contextKey is the context key of Auth where the AuthInterface is stored, default auth
+func SetAuthorizer(a Authorizer, contextKey string) {
+	if contextKey == "" {
+		contextKey = "auth"
+	}
+	current.Store(authorization{authorizer: a, contextKey: contextKey})
+}
+
+// subject the topic of the authenticated entity, it should be used after VerifyAuth
+func subject(c *gin.Context, contextKey string) (string, bool) {
+	entity, ok := c.Get(contextKey)
+	if !ok {
+		return "", false
+	}
+	auth, ok := entity.(AuthInterface)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(auth.GetTopic()), true
+}
+
+// authorize the requests are rejected with 500 until SetAuthorizer is called
+func authorize(c *gin.Context, check func(authorizer Authorizer, subject string) (bool, error)) {
+	auth, _ := current.Load().(authorization)
+	if auth.authorizer == nil {
+		logging.Default().WithFields(logging.Fields{"path": c.Request.URL.Path}).Error("middleware: authorize: call SetAuthorizer first")
+		response.NewResponse(response.Failed.Code, nil, response.Failed.Message).End(c, http.StatusInternalServerError)
+		c.Abort()
+		return
+	}
+	user, ok := subject(c, auth.contextKey)
+	if !ok {
+		response.NewResponse(response.AuthFail.Code, nil, "auth failed").End(c, http.StatusUnauthorized)
+		c.Abort()
+		return
+	}
+	allowed, err := check(auth.authorizer, user)
+	if err != nil {
+		logging.Default().WithFields(logging.Fields{"subject": user, "path": c.Request.URL.Path}).Error(fmt.Errorf("middleware: authorize: %w", err))
+		response.NewResponse(response.Failed.Code, nil, response.Failed.Message).End(c, http.StatusInternalServerError)
+		c.Abort()
+		return
+	}
+	if !allowed {
+		response.NewResponse(response.PermissionDenied.Code, nil, response.PermissionDenied.Message).End(c, http.StatusForbidden)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// Require the authenticated entity must have all the permissions
+func Require(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize(c, func(authorizer Authorizer, subject string) (bool, error) {
+			for _, permission := range permissions {
+				allowed, err := authorizer.Can(c, subject, permission)
+				if err != nil || !allowed {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+	}
+}
+
+// RequireRoute the authenticated entity must have a permission matching the method and path of the request
+func RequireRoute(c *gin.Context) {
+	authorize(c, func(authorizer Authorizer, subject string) (bool, error) {
+		return authorizer.CanRoute(c, subject, c.Request.Method, c.Request.URL.Path)
+	})
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type staticAuthorizer map[string][]string
+
+func (a staticAuthorizer) Can(_ context.Context, subject, permission string) (bool, error) {
+	for _, p := range a[subject] {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a staticAuthorizer) CanRoute(_ context.Context, subject, method, path string) (bool, error) {
+	return a.Can(context.Background(), subject, method+" "+path)
+}
+
+func TestRequire(t *testing.T) {
+	SetAuthorizer(staticAuthorizer{"1": {"user.edit", "GET /admin"}}, "auth")
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.Use(func(ctx *gin.Context) {
+		if ctx.GetHeader("JWT") != "" {
+			ctx.Set("auth", member{ID: 1})
+		}
+	})
+	var ok = func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	}
+	router.GET("/edit", Require("user.edit"), ok)
+	router.GET("/delete", Require("user.edit", "user.delete"), ok)
+	router.GET("/admin", RequireRoute, ok)
+	router.POST("/admin", RequireRoute, ok)
+
+	var cases = []struct {
+		method, path string
+		login        bool
+		code         int
+	}{
+		{http.MethodGet, "/edit", false, http.StatusUnauthorized},
+		{http.MethodGet, "/edit", true, http.StatusOK},
+		{http.MethodGet, "/delete", true, http.StatusForbidden},
+		{http.MethodGet, "/admin", true, http.StatusOK},
+		{http.MethodPost, "/admin", true, http.StatusForbidden},
+	}
+	for _, item := range cases {
+		var req = httptest.NewRequest(item.method, item.path, nil)
+		if item.login {
+			req.Header.Set("JWT", "token")
+		}
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		if recorder.Code != item.code {
+			t.Errorf("%+v: got %d", item, recorder.Code)
+		}
+	}
+}
+
+type failedAuthorizer struct{}
+
+func (failedAuthorizer) Can(context.Context, string, string) (bool, error) {
+	return false, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func (failedAuthorizer) CanRoute(context.Context, string, string, string) (bool, error) {
+	return false, errors.New("dial tcp 10.0.0.1:3306: connection refused")
+}
+
+func TestRequire_Error(t *testing.T) {
+	SetAuthorizer(failedAuthorizer{}, "")
+	defer SetAuthorizer(staticAuthorizer{}, "")
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.GET("/edit", func(ctx *gin.Context) {
+		ctx.Set("auth", member{ID: 1})
+	}, Require("user.edit"))
+
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/edit", nil))
+	if recorder.Code != http.StatusInternalServerError || strings.Contains(recorder.Body.String(), "10.0.0.1") {
+		t.Errorf("the error should not be sent to the client, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// without the authorizer the requests are rejected instead of panicking
+	SetAuthorizer(nil, "")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/edit", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expect 500 without the authorizer, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,305 @@
+// Package oauth third party login with the OAuth2 authorization code flow and PKCE against OpenID Connect providers,
+//  the identities are mapped to the application entities by the Mapper and crane jwt tokens are issued for them,
+//  the state, nonce and code verifier are stored in the session, so the routes must be used after the session middleware
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kenretto/crane/middleware"
+	"github.com/kenretto/crane/response"
+	"github.com/kenretto/crane/sessions"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrProvider the provider isn't configured
+	ErrProvider = errors.New("oauth: unknown provider")
+	// ErrState the state of the callback is missing or doesn't match the session
+	ErrState = errors.New("oauth: state mismatch")
+	// ErrNoEntity the mapper returned no entity for the identity
+	ErrNoEntity = errors.New("oauth: no entity for the identity")
+)
+
+// Identity the identity verified by the id token
+type Identity struct {
+	Provider      string                 `json:"provider"`
+	Subject       string                 `json:"subject"`
+	Email         string                 `json:"email"`
+	EmailVerified bool                   `json:"email_verified"`
+	Name          string                 `json:"name"`
+	Picture       string                 `json:"picture"`
+	Claims        map[string]interface{} `json:"claims"` // all the claims of the id token
+}
+
+// Mapper find or create the application entity of the identity, the tokens are issued for the returned entity
+type Mapper func(ctx *gin.Context, identity *Identity) (middleware.AuthInterface, error)
+
+// OAuth the configured providers, loaded from the oauth node
+type OAuth struct {
+	Config struct {
+		RedirectBase string              `mapstructure:"redirect_base"` // such as https://example.com, default the scheme and host of the request
+		SessionKey   string              `mapstructure:"session_key"`   // the prefix of the session keys, default oauth_
+		Providers    map[string]Provider `mapstructure:"providers"`
+	}
+
+	Auth   *middleware.Auth
+	Mapper Mapper
+	Client *http.Client // the client requesting the providers, default a client with a 10s timeout
+
+	providers map[string]*provider
+	rw        sync.RWMutex
+}
+
+// NewOAuth the tokens of the mapped entities are issued by auth
+func NewOAuth(auth *middleware.Auth, mapper Mapper) *OAuth {
+	return &OAuth{Auth: auth, Mapper: mapper}
+}
+
+func (o *OAuth) Node() string {
+	return "oauth"
+}
+
+// Describe the oauth node is unmarshalled into Config
+func (o *OAuth) Describe() interface{} {
+	return &o.Config
+}
+
+// OnChange the discovery documents and the keys are fetched again on the next login
+func (o *OAuth) OnChange(viper *viper.Viper) {
+	o.rw.Lock()
+	defer o.rw.Unlock()
+	o.Config.Providers = nil
+	_ = viper.Unmarshal(&o.Config)
+
+	var client = o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	o.providers = make(map[string]*provider, len(o.Config.Providers))
+	for name, config := range o.Config.Providers {
+		o.providers[name] = &provider{config: config, client: client}
+	}
+}
+
+func (o *OAuth) provider(name string) (*provider, error) {
+	o.rw.RLock()
+	defer o.rw.RUnlock()
+	p, ok := o.providers[name]
+	if !ok {
+		return nil, ErrProvider
+	}
+	return p, nil
+}
+
+func (o *OAuth) sessionKey(name string) string {
+	o.rw.RLock()
+	defer o.rw.RUnlock()
+	if o.Config.SessionKey == "" {
+		return "oauth_" + name
+	}
+	return o.Config.SessionKey + name
+}
+
+// redirectURL the redirect_url of the provider, or the callback route next to the login route
+func (o *OAuth) redirectURL(c *gin.Context, p *provider) string {
+	if p.config.RedirectURL != "" {
+		return p.config.RedirectURL
+	}
+	var path = strings.TrimSuffix(c.Request.URL.Path, "/login") + "/callback"
+
+	o.rw.RLock()
+	defer o.rw.RUnlock()
+	if o.Config.RedirectBase != "" {
+		return strings.TrimSuffix(o.Config.RedirectBase, "/") + path
+	}
+	var scheme = "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + path
+}
+
+// flow the values of a login stored in the session until the callback
+type flow struct {
+	State       string `json:"state"`
+	Nonce       string `json:"nonce"`
+	Verifier    string `json:"verifier"`
+	RedirectURL string `json:"redirect_url"`
+}
+
+func random() (string, error) {
+	var b = make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newFlow(redirectURL string) (*flow, error) {
+	var f = &flow{RedirectURL: redirectURL}
+	var err error
+	if f.State, err = random(); err != nil {
+		return nil, err
+	}
+	if f.Nonce, err = random(); err != nil {
+		return nil, err
+	}
+	if f.Verifier, err = random(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// challenge the S256 code challenge of the verifier
+func challenge(verifier string) string {
+	var sum = sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL start a login, the flow is stored in the session and the authorization url of the provider is returned
+func (o *OAuth) AuthCodeURL(c *gin.Context, name string) (string, error) {
+	p, err := o.provider(name)
+	if err != nil {
+		return "", err
+	}
+	discovery, err := p.discover(c.Request.Context())
+	if err != nil {
+		return "", err
+	}
+	f, err := newFlow(o.redirectURL(c, p))
+	if err != nil {
+		return "", err
+	}
+	if err = sessions.Set(c, o.sessionKey(name), f); err != nil {
+		return "", err
+	}
+
+	var scopes = p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	var query = url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {f.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {f.State},
+		"nonce":                 {f.Nonce},
+		"code_challenge":        {challenge(f.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	var separator = "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange finish the login, check the state of the callback, exchange the code and verify the id token,
+//  the flow is removed from the session, so each state can be used only once
+func (o *OAuth) Exchange(c *gin.Context, name string) (*Identity, error) {
+	p, err := o.provider(name)
+	if err != nil {
+		return nil, err
+	}
+	var key = o.sessionKey(name)
+	f, ok := sessions.Get[flow](c, key)
+	if !ok {
+		return nil, ErrState
+	}
+	if err = sessions.Del(c, key); err != nil {
+		return nil, err
+	}
+	var state = c.Query("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(f.State)) != 1 {
+		return nil, ErrState
+	}
+	if e := c.Query("error"); e != "" {
+		return nil, errors.New("oauth: " + e + " " + c.Query("error_description"))
+	}
+
+	token, err := p.exchange(c.Request.Context(), c.Query("code"), f.RedirectURL, f.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := p.verify(c.Request.Context(), token.IDToken, f.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	var all = jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(token.IDToken, all); err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Provider:      name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+		Claims:        all,
+	}, nil
+}
+
+// Login gin handler redirecting to the authorization url of the :provider param
+func (o *OAuth) Login(c *gin.Context) {
+	u, err := o.AuthCodeURL(c, c.Param("provider"))
+	if errors.Is(err, ErrProvider) {
+		response.NewResponse(response.NotFound.Code, nil, err.Error()).End(c, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		response.NewResponse(response.Failed.Code, nil, err.Error()).End(c, http.StatusBadGateway)
+		return
+	}
+	c.Redirect(http.StatusFound, u)
+}
+
+// Callback gin handler finishing the login of the :provider param, the identity is mapped by the Mapper and a token pair is issued for the entity
+func (o *OAuth) Callback(c *gin.Context) {
+	identity, err := o.Exchange(c, c.Param("provider"))
+	if errors.Is(err, ErrProvider) {
+		response.NewResponse(response.NotFound.Code, nil, err.Error()).End(c, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		response.NewResponse(response.AuthFail.Code, nil, err.Error()).End(c, http.StatusUnauthorized)
+		return
+	}
+
+	entity, err := o.Mapper(c, identity)
+	if err == nil && entity == nil {
+		err = ErrNoEntity
+	}
+	if err != nil {
+		response.NewResponse(response.AuthFail.Code, nil, err.Error()).End(c, http.StatusUnauthorized)
+		return
+	}
+	pair, err := o.Auth.Issue(entity)
+	if err != nil {
+		response.NewResponse(response.Failed.Code, nil, err.Error()).End(c, http.StatusInternalServerError)
+		return
+	}
+	response.NewResponse(response.Success.Code, pair).End(c)
+}
+
+// Routes register GET /oauth/:provider/login and GET /oauth/:provider/callback
+func (o *OAuth) Routes(router gin.IRouter, middlewares ...gin.HandlerFunc) {
+	var group = router.Group("/oauth", middlewares...)
+	group.GET("/:provider/login", o.Login)
+	group.GET("/:provider/callback", o.Callback)
+}
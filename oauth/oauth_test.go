@@ -0,0 +1,214 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kenretto/crane/middleware"
+	"github.com/kenretto/crane/oauth/oauthtest"
+	"github.com/kenretto/sessions"
+	"github.com/kenretto/sessions/memstore"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type user struct {
+	Subject string
+}
+
+func (user) TableName() string {
+	return "user"
+}
+
+func (u user) GetTopic() interface{} {
+	return u.Subject
+}
+
+func (u user) FindByTopic(topic interface{}) middleware.AuthInterface {
+	return user{Subject: topic.(string)}
+}
+
+func (u user) GetCheckData() string {
+	return ""
+}
+
+func (u user) Check(*gin.Context, string) bool {
+	return true
+}
+
+func (u user) ExpiredAt() int64 {
+	return 0
+}
+
+func setup(t *testing.T) (*oauthtest.Server, *OAuth, *gin.Engine) {
+	var idp = oauthtest.NewServer("crane", "secret")
+	t.Cleanup(idp.Close)
+
+	var auth = middleware.NewAuth(user{}, nil)
+	key, err := middleware.NewKey("app", jwt.SigningMethodHS256, []byte("app secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := middleware.NewKeySet("app", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.SetKeys(keys)
+
+	var o = NewOAuth(auth, func(ctx *gin.Context, identity *Identity) (middleware.AuthInterface, error) {
+		if !identity.EmailVerified {
+			return nil, nil
+		}
+		return user{Subject: identity.Provider + ":" + identity.Subject}, nil
+	})
+	// the issuer of the stub provider is only known at runtime
+	var config = viper.New()
+	config.Set("providers", map[string]interface{}{
+		"stub": map[string]interface{}{"issuer": idp.Issuer, "client_id": "crane", "client_secret": "secret"},
+	})
+	o.OnChange(config)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	router.Use(sessions.Sessions("session", memstore.NewStore([]byte("secret"))))
+	o.Routes(router)
+	router.GET("/me", middleware.VerifyAuth(auth), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "%v", ctx.MustGet("auth").(middleware.AuthInterface).GetTopic())
+	})
+	return idp, o, router
+}
+
+// login start a login and let the stub provider approve it, returns the session cookies and the callback url
+func login(t *testing.T, router *gin.Engine) ([]*http.Cookie, *url.URL) {
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth/stub/login", nil))
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("login: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var client = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	rsp, err := client.Get(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: %d", rsp.StatusCode)
+	}
+	callback, err := url.Parse(rsp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Result().Cookies(), callback
+}
+
+func callback(router *gin.Engine, cookies []*http.Cookie, u *url.URL) *httptest.ResponseRecorder {
+	var req = httptest.NewRequest(http.MethodGet, u.RequestURI(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestOAuth(t *testing.T) {
+	var idp, _, router = setup(t)
+
+	cookies, u := login(t, router)
+	if u.Host != "example.com" || u.Path != "/oauth/stub/callback" {
+		t.Fatalf("unexpected callback %s", u)
+	}
+	var recorder = callback(router, cookies, u)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("callback: %d %s", recorder.Code, recorder.Body.String())
+	}
+	var body struct {
+		Data middleware.TokenPair `json:"data"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	var req = httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+body.Data.AccessToken)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	if recorder.Body.String() != "stub:1" {
+		t.Errorf("unexpected entity %q", recorder.Body.String())
+	}
+
+	// the state can be used only once
+	if recorder = callback(router, cookies, u); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("replayed callback: %d", recorder.Code)
+	}
+
+	// a forged state
+	cookies, u = login(t, router)
+	var query = u.Query()
+	query.Set("state", "forged")
+	u.RawQuery = query.Encode()
+	if recorder = callback(router, cookies, u); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("forged state: %d", recorder.Code)
+	}
+
+	// the keys are fetched again when the provider rotates them
+	if err := idp.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	cookies, u = login(t, router)
+	if recorder = callback(router, cookies, u); recorder.Code != http.StatusOK {
+		t.Errorf("rotated keys: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// unverified emails are rejected by the mapper
+	idp.Identity.EmailVerified = false
+	cookies, u = login(t, router)
+	if recorder = callback(router, cookies, u); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("unverified email: %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth/unknown/login", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: %d", recorder.Code)
+	}
+}
+
+func TestProvider_Verify(t *testing.T) {
+	var idp, o, _ = setup(t)
+	p, err := o.provider("stub")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims = func(iss, aud, nonce string) jwt.MapClaims {
+		return jwt.MapClaims{"iss": iss, "aud": aud, "sub": "1", "nonce": nonce, "exp": 4102444800}
+	}
+	var cases = []struct {
+		name   string
+		claims jwt.MapClaims
+		valid  bool
+	}{
+		{"valid", claims(idp.Issuer, "crane", "n"), true},
+		{"issuer", claims("https://evil.example.com", "crane", "n"), false},
+		{"audience", claims(idp.Issuer, "other", "n"), false},
+		{"nonce", claims(idp.Issuer, "crane", "other"), false},
+		{"expired", jwt.MapClaims{"iss": idp.Issuer, "aud": "crane", "nonce": "n", "exp": 1}, false},
+	}
+	for _, c := range cases {
+		token, err := idp.Sign(c.claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.verify(context.Background(), token, "n")
+		if (err == nil) != c.valid {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package oauthtest a stub OpenID Connect provider for the tests, it approves every authorization request without a login page
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kenretto/crane/middleware"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Identity the identity returned in the id tokens
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// authorization a code waiting for the token request
+type authorization struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// Server the stub provider, Issuer is the issuer to configure in the oauth node
+type Server struct {
+	*httptest.Server
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Identity     Identity
+
+	mu    sync.Mutex
+	keys  *middleware.KeySet
+	kid   int
+	codes map[string]authorization
+}
+
+// NewServer start a stub provider accepting the client, an empty secret accepts public clients
+func NewServer(clientID, clientSecret string) *Server {
+	var s = &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Identity:     Identity{Subject: "1", Email: "user@example.com", EmailVerified: true, Name: "user"},
+		codes:        make(map[string]authorization),
+	}
+	if err := s.Rotate(); err != nil {
+		panic(err)
+	}
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+	s.Issuer = s.URL
+	return s
+}
+
+// Rotate replace the signing key with a new one with another kid
+func (s *Server) Rotate() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kid++
+	var kid = fmt.Sprintf("stub-%d", s.kid)
+	k, err := middleware.NewKey(kid, jwt.SigningMethodRS256, key)
+	if err != nil {
+		return err
+	}
+	s.keys, err = middleware.NewKeySet(kid, k)
+	return err
+}
+
+// Sign sign the claims with the current key, the tests can use it to forge id tokens
+func (s *Server) Sign(claims jwt.Claims) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys.Sign(claims)
+}
+
+func (s *Server) write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) fail(w http.ResponseWriter, code, description string) {
+	s.write(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": description})
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	s.write(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(w, http.StatusOK, s.keys.JWKS())
+}
+
+// authorize approve the request and redirect to the redirect_uri with the code and the state
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != s.ClientID {
+		s.fail(w, "invalid_request", "unexpected response_type or client_id")
+		return
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		s.fail(w, "invalid_request", "PKCE S256 is required")
+		return
+	}
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		s.fail(w, "invalid_request", "invalid redirect_uri")
+		return
+	}
+
+	var b = make([]byte, 16)
+	_, _ = rand.Read(b)
+	var code = base64.RawURLEncoding.EncodeToString(b)
+	s.mu.Lock()
+	s.codes[code] = authorization{
+		clientID:    s.ClientID,
+		redirectURI: redirect.String(),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+	}
+	s.mu.Unlock()
+
+	var values = redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token exchange the code, each code can be used once, the code verifier must match the challenge
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		s.fail(w, "invalid_request", "POST form expected")
+		return
+	}
+	s.mu.Lock()
+	auth, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	var identity = s.Identity
+	s.mu.Unlock()
+
+	var sum = sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code":
+		s.fail(w, "unsupported_grant_type", "")
+	case !ok:
+		s.fail(w, "invalid_grant", "unknown code")
+	case r.PostForm.Get("client_id") != auth.clientID || r.PostForm.Get("client_secret") != s.ClientSecret:
+		s.fail(w, "invalid_client", "")
+	case r.PostForm.Get("redirect_uri") != auth.redirectURI:
+		s.fail(w, "invalid_grant", "redirect_uri mismatch")
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge:
+		s.fail(w, "invalid_grant", "code_verifier mismatch")
+	default:
+		var now = time.Now()
+		token, err := s.Sign(jwt.MapClaims{
+			"iss":            s.Issuer,
+			"aud":            auth.clientID,
+			"sub":            identity.Subject,
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Hour).Unix(),
+			"nonce":          auth.nonce,
+			"email":          identity.Email,
+			"email_verified": identity.EmailVerified,
+			"name":           identity.Name,
+			"picture":        identity.Picture,
+		})
+		if err != nil {
+			s.fail(w, "server_error", err.Error())
+			return
+		}
+		s.write(w, http.StatusOK, map[string]interface{}{
+			"access_token": "stub",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     token,
+		})
+	}
+}
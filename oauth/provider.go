@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kenretto/crane/middleware"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider the config of an OpenID Connect provider
+type Provider struct {
+	Issuer       string   `mapstructure:"issuer"` // the discovery document is fetched from issuer + /.well-known/openid-configuration
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`       // default openid, email, profile
+	RedirectURL  string   `mapstructure:"redirect_url"` // default the callback route of the current host
+}
+
+// Discovery the fields of the OpenID Connect discovery document used here
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// provider the discovery document and the keys of a provider, the keys are fetched again when the kid is unknown
+type provider struct {
+	config    Provider
+	client    *http.Client
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      *middleware.KeySet
+}
+
+func (p *provider) get(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oauth: GET %s: %s", u, rsp.Status)
+	}
+	return jsoniter.Unmarshal(body, v)
+}
+
+// discover fetch the discovery document once
+func (p *provider) discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	var discovery = new(Discovery)
+	if err := p.get(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oauth: the issuer of the discovery document %s doesn't match %s", discovery.Issuer, p.config.Issuer)
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// jwks the cached keys, stale is the set the caller failed to verify with, the keys are fetched again if they are still the stale ones,
+//  the id tokens come from the token endpoint rather than the clients, so an unknown kid means the provider rotated its keys
+func (p *provider) jwks(ctx context.Context, stale *middleware.KeySet) (*middleware.KeySet, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys != nil && p.keys != stale {
+		return p.keys, nil
+	}
+
+	var jwks middleware.JWKS
+	if err := p.get(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+	var keys = make([]*middleware.Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// skip the keys of the unsupported types
+		if key, err := middleware.DecodeJWK(jwk); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	set, err := middleware.NewKeySet("", keys...)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = set
+	return set, nil
+}
+
+// tokenResponse the response of the token endpoint
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// exchange exchange the authorization code for the tokens with the PKCE code verifier
+func (p *provider) exchange(ctx context.Context, code, redirectURL, verifier string) (*tokenResponse, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var form = url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var token = new(tokenResponse)
+	if err := jsoniter.Unmarshal(body, token); err != nil {
+		return nil, fmt.Errorf("oauth: token endpoint: %s", rsp.Status)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("oauth: token endpoint: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("oauth: the token response has no id_token")
+	}
+	return token, nil
+}
+
+// IDTokenClaims the claims of the id token
+type IDTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// verify verify the signature with the keys of the provider, and the iss, aud, exp and nonce claims
+func (p *provider) verify(ctx context.Context, token, nonce string) (*IDTokenClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var parse = func(keys *middleware.KeySet) (*IDTokenClaims, error) {
+		var claims = new(IDTokenClaims)
+		_, err := jwt.ParseWithClaims(token, claims, keys.Keyfunc,
+			jwt.WithValidMethods(keys.Algorithms()),
+			jwt.WithIssuer(discovery.Issuer),
+			jwt.WithAudience(p.config.ClientID),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(time.Minute),
+		)
+		return claims, err
+	}
+
+	keys, err := p.jwks(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := parse(keys)
+	if errors.Is(err, jwt.ErrTokenUnverifiable) {
+		// the provider may have rotated its keys
+		if keys, err = p.jwks(ctx, keys); err != nil {
+			return nil, err
+		}
+		claims, err = parse(keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("oauth: nonce mismatch")
+	}
+	return claims, nil
+}
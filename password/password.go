@@ -2,14 +2,13 @@ package password
 
 import (
 	"fmt"
+	"github.com/kenretto/crane/logging"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// ILogger logger
-type ILogger interface {
-	Error(args ...interface{})
-}
+// ILogger logger, see package logging for the adapters
+type ILogger = logging.Logger
 
 // Password password
 type Password struct {
@@ -24,6 +23,11 @@ func (pwd Password) Node() string {
 	return "password"
 }
 
+// Describe the password node is unmarshalled into Config
+func (pwd Password) Describe() interface{} {
+	return pwd.Config
+}
+
 // OnChange ...
 func (pwd Password) OnChange(viper *viper.Viper) {
 	_ = viper.Unmarshal(&pwd.Config)
@@ -34,11 +38,24 @@ func (pwd Password) Instance() Password {
 	return pwd
 }
 
+// WithLogger set the logger recording the hashing errors
+func (pwd Password) WithLogger(logger ILogger) Password {
+	pwd.l = logger
+	return pwd
+}
+
+func (pwd Password) logger() ILogger {
+	if pwd.l == nil {
+		return logging.Default()
+	}
+	return pwd.l
+}
+
 // Hash password hash
 func (pwd Password) Hash(token, password string) string {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(fmt.Sprintf("%s%s%s", pwd.Config.Token, password, token)), pwd.Config.Cost)
 	if err != nil {
-		pwd.l.Error(err)
+		pwd.logger().Error(err)
 	}
 
 	return string(bytes)
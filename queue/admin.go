@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/response"
+	"net/http"
+	"strconv"
+)
+
+// AdminRoutes register the routes managing the queues, the routes should be protected by the middlewares
+//  GET    /queue/queues                       the stats of the queues
+//  GET    /queue/queues/:queue/dead           the dead jobs of the queue, the latest first, query: offset, limit (default 20)
+//  POST   /queue/queues/:queue/dead/:id/retry move the dead job back to the queue, 409 if its unique key is taken
+//  DELETE /queue/queues/:queue/dead/:id       delete the dead job
+func AdminRoutes(router gin.IRouter, q *Queue, middlewares ...gin.HandlerFunc) {
+	var group = router.Group("/queue/queues", middlewares...)
+
+	group.GET("", func(ctx *gin.Context) {
+		stats, err := q.backend.Stats(ctx)
+		if err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusInternalServerError)
+			return
+		}
+		response.NewResponse(response.Success.Code, stats).End(ctx)
+	})
+
+	group.GET("/:queue/dead", func(ctx *gin.Context) {
+		offset, _ := strconv.ParseInt(ctx.Query("offset"), 10, 64)
+		limit, _ := strconv.ParseInt(ctx.DefaultQuery("limit", "20"), 10, 64)
+		if offset < 0 || limit <= 0 {
+			response.NewResponse(response.Failed.Code, nil, "invalid offset or limit").End(ctx, http.StatusBadRequest)
+			return
+		}
+		jobs, err := q.backend.Dead(ctx, ctx.Param("queue"), offset, limit)
+		if err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusInternalServerError)
+			return
+		}
+		response.NewResponse(response.Success.Code, jobs).End(ctx)
+	})
+
+	var end = func(ctx *gin.Context, err error) {
+		switch {
+		case err == nil:
+			response.NewResponse(response.Success.Code, nil).End(ctx)
+		case errors.Is(err, ErrNotFound):
+			response.NewResponse(response.NotFound.Code, nil, err.Error()).End(ctx, http.StatusNotFound)
+		case errors.Is(err, ErrDuplicate):
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusConflict)
+		default:
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusInternalServerError)
+		}
+	}
+
+	group.POST("/:queue/dead/:id/retry", func(ctx *gin.Context) {
+		end(ctx, q.backend.RetryDead(ctx, ctx.Param("queue"), ctx.Param("id")))
+	})
+
+	group.DELETE("/:queue/dead/:id", func(ctx *gin.Context) {
+		end(ctx, q.backend.DeleteDead(ctx, ctx.Param("queue"), ctx.Param("id")))
+	})
+}
@@ -0,0 +1,82 @@
+// Package queue the persistent job queue, the jobs are enqueued immediately, after a delay or at a time,
+//  and processed by the workers of each queue with retries, a dead letter queue and unique jobs,
+//  RedisBackend keeps the jobs in redis for the multi instance deployments, MemoryBackend keeps them in the process for the tests
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDuplicate a job with the same unique key is pending, running or retrying
+	ErrDuplicate = errors.New("queue: duplicate job")
+	// ErrNotFound the job isn't in the dead letter queue
+	ErrNotFound = errors.New("queue: job not found")
+	// ErrLostLease the visibility timeout of the job expired, it's reserved again or finished by another worker
+	ErrLostLease = errors.New("queue: the reservation of the job is lost")
+)
+
+// Job a job of a queue, the payload is the json of the payload of the task
+type Job struct {
+	ID         string    `json:"id"`
+	Queue      string    `json:"queue"`
+	Type       string    `json:"type"`
+	Payload    []byte    `json:"payload"`
+	Unique     string    `json:"unique,omitempty"`
+	MaxRetries int       `json:"max_retries"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"last_error,omitempty"`
+	RunAt      time.Time `json:"run_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	FailedAt   time.Time `json:"failed_at,omitempty"`
+	// Lease the reservation token set by Dequeue, Ack, Retry and Fail do nothing if the job has been reserved again since
+	Lease int64 `json:"-"`
+}
+
+func newJobID() string {
+	var b = make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Stats the number of the jobs of a queue
+//  ready: due and waiting for a worker
+//  scheduled: delayed or waiting for a retry
+//  running: reserved by the workers
+//  dead: failed after the retries
+type Stats struct {
+	Queue     string `json:"queue"`
+	Ready     int64  `json:"ready"`
+	Scheduled int64  `json:"scheduled"`
+	Running   int64  `json:"running"`
+	Dead      int64  `json:"dead"`
+}
+
+// Backend stores the jobs, the jobs are delivered at least once, a reserved job is delivered again if it isn't acked,
+//  retried or failed before the visibility timeout
+type Backend interface {
+	// Enqueue add the job, ErrDuplicate if the unique key of the job is taken
+	Enqueue(ctx context.Context, job *Job) error
+	// Dequeue reserve the next due job of the queue until the visibility timeout, the attempts of the job are increased,
+	//  Job.Lease is set to the token of the reservation, nil if there's none
+	Dequeue(ctx context.Context, queue string, visibility time.Duration) (*Job, error)
+	// Ack remove the finished job, ErrLostLease if the reservation of the job is lost
+	Ack(ctx context.Context, job *Job) error
+	// Retry run the job again at runAt, ErrLostLease if the reservation of the job is lost
+	Retry(ctx context.Context, job *Job, runAt time.Time) error
+	// Fail move the job to the dead letter queue, ErrLostLease if the reservation of the job is lost
+	Fail(ctx context.Context, job *Job) error
+	// Stats the stats of the queues
+	Stats(ctx context.Context) ([]Stats, error)
+	// Dead the jobs in the dead letter queue of the queue, the latest first
+	Dead(ctx context.Context, queue string, offset, limit int64) ([]*Job, error)
+	// RetryDead move the dead job back to the queue, its attempts are reset,
+	//  ErrDuplicate if its unique key is taken by another job since it failed
+	RetryDead(ctx context.Context, queue, id string) error
+	// DeleteDead delete the dead job
+	DeleteDead(ctx context.Context, queue, id string) error
+}
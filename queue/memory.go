@@ -0,0 +1,224 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryQueue the jobs of a queue, the times are when the jobs are due, the visibility deadlines and when the jobs failed
+type memoryQueue struct {
+	jobs      map[string]Job
+	scheduled map[string]time.Time
+	running   map[string]time.Time
+	dead      map[string]time.Time
+	unique    map[string]string
+	attempts  map[string]int
+}
+
+// MemoryBackend keeps the jobs in the process, they are lost when the process exits
+type MemoryBackend struct {
+	mu     sync.Mutex
+	queues map[string]*memoryQueue
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{queues: make(map[string]*memoryQueue)}
+}
+
+func (backend *MemoryBackend) queue(name string) *memoryQueue {
+	var q, ok = backend.queues[name]
+	if !ok {
+		q = &memoryQueue{
+			jobs:      make(map[string]Job),
+			scheduled: make(map[string]time.Time),
+			running:   make(map[string]time.Time),
+			dead:      make(map[string]time.Time),
+			unique:    make(map[string]string),
+			attempts:  make(map[string]int),
+		}
+		backend.queues[name] = q
+	}
+	return q
+}
+
+// reserved whether the job is still reserved by the lease of job
+func (q *memoryQueue) reserved(job *Job) bool {
+	deadline, ok := q.running[job.ID]
+	return ok && deadline.UnixNano() == job.Lease
+}
+
+// release remove the unique key of the job
+func (q *memoryQueue) release(job *Job) {
+	if job.Unique != "" && q.unique[job.Unique] == job.ID {
+		delete(q.unique, job.Unique)
+	}
+}
+
+func (backend *MemoryBackend) Enqueue(_ context.Context, job *Job) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(job.Queue)
+	if job.Unique != "" {
+		if _, ok := q.unique[job.Unique]; ok {
+			return ErrDuplicate
+		}
+		q.unique[job.Unique] = job.ID
+	}
+	q.jobs[job.ID] = *job
+	q.scheduled[job.ID] = job.RunAt
+	return nil
+}
+
+func (backend *MemoryBackend) Dequeue(_ context.Context, queue string, visibility time.Duration) (*Job, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(queue)
+	var now = time.Now()
+	for id, deadline := range q.running {
+		if !deadline.After(now) {
+			delete(q.running, id)
+			q.scheduled[id] = now
+		}
+	}
+
+	var next string
+	for id, runAt := range q.scheduled {
+		if !runAt.After(now) && (next == "" || runAt.Before(q.scheduled[next])) {
+			next = id
+		}
+	}
+	if next == "" {
+		return nil, nil
+	}
+	delete(q.scheduled, next)
+	if _, ok := q.jobs[next]; !ok {
+		delete(q.attempts, next)
+		return nil, nil
+	}
+	var deadline = now.Add(visibility)
+	q.running[next] = deadline
+	q.attempts[next]++
+	var job = q.jobs[next]
+	job.Attempts, job.Lease = q.attempts[next], deadline.UnixNano()
+	return &job, nil
+}
+
+func (backend *MemoryBackend) Ack(_ context.Context, job *Job) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(job.Queue)
+	if !q.reserved(job) {
+		return ErrLostLease
+	}
+	q.release(job)
+	delete(q.jobs, job.ID)
+	delete(q.running, job.ID)
+	delete(q.attempts, job.ID)
+	return nil
+}
+
+func (backend *MemoryBackend) Retry(_ context.Context, job *Job, runAt time.Time) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(job.Queue)
+	if !q.reserved(job) {
+		return ErrLostLease
+	}
+	delete(q.running, job.ID)
+	q.jobs[job.ID] = *job
+	q.scheduled[job.ID] = runAt
+	return nil
+}
+
+func (backend *MemoryBackend) Fail(_ context.Context, job *Job) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(job.Queue)
+	if !q.reserved(job) {
+		return ErrLostLease
+	}
+	q.release(job)
+	delete(q.running, job.ID)
+	q.jobs[job.ID] = *job
+	q.dead[job.ID] = job.FailedAt
+	return nil
+}
+
+func (backend *MemoryBackend) Stats(_ context.Context) ([]Stats, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var now = time.Now()
+	var stats = make([]Stats, 0, len(backend.queues))
+	for name, q := range backend.queues {
+		var s = Stats{Queue: name, Running: int64(len(q.running)), Dead: int64(len(q.dead))}
+		for _, runAt := range q.scheduled {
+			if runAt.After(now) {
+				s.Scheduled++
+			} else {
+				s.Ready++
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Queue < stats[j].Queue
+	})
+	return stats, nil
+}
+
+func (backend *MemoryBackend) Dead(_ context.Context, queue string, offset, limit int64) ([]*Job, error) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(queue)
+	var jobs = make([]*Job, 0, len(q.dead))
+	for id := range q.dead {
+		var job = q.jobs[id]
+		jobs = append(jobs, &job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].FailedAt.After(jobs[j].FailedAt)
+	})
+	if offset >= int64(len(jobs)) {
+		return []*Job{}, nil
+	}
+	jobs = jobs[offset:]
+	if limit > 0 && limit < int64(len(jobs)) {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (backend *MemoryBackend) RetryDead(_ context.Context, queue, id string) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(queue)
+	if _, ok := q.dead[id]; !ok {
+		return ErrNotFound
+	}
+	// the unique key was released when the job failed
+	if job := q.jobs[id]; job.Unique != "" {
+		if owner, ok := q.unique[job.Unique]; ok && owner != id {
+			return ErrDuplicate
+		}
+		q.unique[job.Unique] = id
+	}
+	delete(q.dead, id)
+	delete(q.attempts, id)
+	q.scheduled[id] = time.Now()
+	return nil
+}
+
+func (backend *MemoryBackend) DeleteDead(_ context.Context, queue, id string) error {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var q = backend.queue(queue)
+	if _, ok := q.dead[id]; !ok {
+		return ErrNotFound
+	}
+	delete(q.dead, id)
+	delete(q.jobs, id)
+	delete(q.attempts, id)
+	return nil
+}
@@ -0,0 +1,348 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kenretto/crane/logging"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultQueue the queue of the tasks defined without a queue
+const DefaultQueue = "default"
+
+// Options the options of a queue
+//  Concurrency: the number of the workers, default 1
+//  MaxRetries: how many times a failed job is retried, default 3, negative disables the retries
+//  Backoff: the delay before the first retry, doubled on each retry up to MaxBackoff, default 1s and 1h
+//  Visibility: how long a job is reserved by a worker, the job is delivered again if the worker doesn't finish it in time,
+//   the context of the handler is cancelled after it, default 5m
+//  Poll: how long the workers wait when the queue is empty, default 1s
+type Options struct {
+	Concurrency int
+	MaxRetries  int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Visibility  time.Duration
+	Poll        time.Duration
+}
+
+func (options Options) withDefaults() Options {
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = 3
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = time.Hour
+	}
+	if options.Visibility <= 0 {
+		options.Visibility = 5 * time.Minute
+	}
+	if options.Poll <= 0 {
+		options.Poll = time.Second
+	}
+	return options
+}
+
+// backoff the delay before the retry after the attempt
+func (options Options) backoff(attempts int) time.Duration {
+	var delay = options.Backoff
+	for i := 1; i < attempts && delay < options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > options.MaxBackoff {
+		delay = options.MaxBackoff
+	}
+	return delay
+}
+
+// handler decodes the payload of the job and calls the handler of the task
+type handler func(ctx context.Context, job *Job) error
+
+// Queue the workers of the queues, the tasks are defined by Define
+//  var jobs = queue.New(queue.NewRedisBackend(crane.Redis(), "", ""), logger)
+//  jobs.Configure("emails", queue.Options{Concurrency: 4})
+//  var welcome = queue.Define(jobs, "emails", "welcome", func(ctx context.Context, user User) error {
+//      return mailer.SendWelcome(ctx, user)
+//  })
+//  crane.Manage("queue", jobs)
+//  _, err := welcome.EnqueueIn(ctx, user, time.Hour)
+type Queue struct {
+	backend Backend
+	logger  logging.Logger
+
+	mu       sync.RWMutex
+	options  map[string]Options
+	handlers map[string]handler
+	stop     chan struct{}
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// New logger nil uses logging.Default
+func New(backend Backend, logger logging.Logger) *Queue {
+	if logger == nil {
+		logger = logging.Default()
+	}
+	return &Queue{backend: backend, logger: logger, options: make(map[string]Options), handlers: make(map[string]handler)}
+}
+
+// Backend the backend of the queue
+func (q *Queue) Backend() Backend {
+	return q.backend
+}
+
+// Configure set the options of the queue, call it before Start
+func (q *Queue) Configure(queue string, options Options) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.options[queue] = options.withDefaults()
+}
+
+func (q *Queue) queueOptions(queue string) Options {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if options, ok := q.options[queue]; ok {
+		return options
+	}
+	return Options{}.withDefaults()
+}
+
+func (q *Queue) handler(typ string) handler {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.handlers[typ]
+}
+
+// Task the jobs of a type, the payloads of T are encoded as json
+type Task[T any] struct {
+	queue *Queue
+	name  string
+	on    string
+}
+
+// Define define the task processed by the workers of the queue, the name is the type of its jobs and must be unique,
+//  empty queue means DefaultQueue, the queue is started with the default options if it isn't configured
+func Define[T any](q *Queue, queue, name string, fn func(ctx context.Context, payload T) error) *Task[T] {
+	if queue == "" {
+		queue = DefaultQueue
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.handlers[name]; ok {
+		panic("queue: the task " + name + " is defined twice")
+	}
+	q.handlers[name] = func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := jsoniter.Unmarshal(job.Payload, &payload); err != nil {
+			return err
+		}
+		return fn(ctx, payload)
+	}
+	if _, ok := q.options[queue]; !ok {
+		q.options[queue] = Options{}.withDefaults()
+	}
+	return &Task[T]{queue: q, name: name, on: queue}
+}
+
+// EnqueueOptions the options of a job
+//  Queue: default the queue of the task
+//  At: run the job at the time, zero means now
+//  Delay: run the job after the delay, used if At is zero
+//  Unique: the key deduplicating the jobs, the job isn't enqueued while a job with the same key is pending, running or retrying
+//  MaxRetries: default the retries of the queue, negative disables the retries
+type EnqueueOptions struct {
+	Queue      string
+	At         time.Time
+	Delay      time.Duration
+	Unique     string
+	MaxRetries int
+}
+
+// Enqueue run the job now, returns the id of the job
+func (task *Task[T]) Enqueue(ctx context.Context, payload T) (string, error) {
+	return task.EnqueueWith(ctx, payload, EnqueueOptions{})
+}
+
+// EnqueueIn run the job after the delay
+func (task *Task[T]) EnqueueIn(ctx context.Context, payload T, delay time.Duration) (string, error) {
+	return task.EnqueueWith(ctx, payload, EnqueueOptions{Delay: delay})
+}
+
+// EnqueueAt run the job at the time
+func (task *Task[T]) EnqueueAt(ctx context.Context, payload T, at time.Time) (string, error) {
+	return task.EnqueueWith(ctx, payload, EnqueueOptions{At: at})
+}
+
+// EnqueueWith enqueue the job with the options, ErrDuplicate if the unique key is taken
+func (task *Task[T]) EnqueueWith(ctx context.Context, payload T, options EnqueueOptions) (string, error) {
+	data, err := jsoniter.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	if options.Queue == "" {
+		options.Queue = task.on
+	}
+	var now = time.Now()
+	var job = &Job{
+		ID:         newJobID(),
+		Queue:      options.Queue,
+		Type:       task.name,
+		Payload:    data,
+		Unique:     options.Unique,
+		MaxRetries: options.MaxRetries,
+		RunAt:      options.At,
+		CreatedAt:  now,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = now.Add(options.Delay)
+	}
+	if job.MaxRetries == 0 {
+		job.MaxRetries = task.queue.queueOptions(options.Queue).MaxRetries
+	}
+	if job.MaxRetries < 0 {
+		job.MaxRetries = 0
+	}
+	if err = task.queue.backend.Enqueue(ctx, job); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Init implements the Lifecycle of crane
+func (q *Queue) Init(context.Context) error {
+	return nil
+}
+
+// Start start the workers of the configured queues and the queues of the defined tasks
+func (q *Queue) Start(context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stop != nil {
+		return nil
+	}
+	q.stop = make(chan struct{})
+	var ctx context.Context
+	ctx, q.cancel = context.WithCancel(context.Background())
+	var queues = make([]string, 0, len(q.options))
+	for queue := range q.options {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		for i := 0; i < q.options[queue].Concurrency; i++ {
+			q.wg.Add(1)
+			go q.work(ctx, queue, q.options[queue], q.stop)
+		}
+	}
+	return nil
+}
+
+// Stop stop the workers, the running jobs are waited until ctx is done, then their contexts are cancelled
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.stop == nil {
+		q.mu.Unlock()
+		return nil
+	}
+	close(q.stop)
+	var cancel = q.cancel
+	q.stop, q.cancel = nil, nil
+	q.mu.Unlock()
+
+	var done = make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		cancel()
+		return nil
+	case <-ctx.Done():
+		cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// work process the jobs of the queue until stop is closed
+func (q *Queue) work(ctx context.Context, queue string, options Options, stop chan struct{}) {
+	defer q.wg.Done()
+	var logger = q.logger.WithFields(logging.Fields{"queue": queue})
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		job, err := q.backend.Dequeue(ctx, queue, options.Visibility)
+		if err != nil {
+			logger.Error(err)
+		}
+		if job == nil {
+			select {
+			case <-stop:
+				return
+			case <-time.After(options.Poll):
+			}
+			continue
+		}
+		q.process(ctx, job, options, logger)
+	}
+}
+
+// process run the job, and ack, retry or fail it by the result
+func (q *Queue) process(ctx context.Context, job *Job, options Options, logger logging.Logger) {
+	logger = logger.WithFields(logging.Fields{"job": job.ID, "type": job.Type, "attempts": job.Attempts})
+	var fn = q.handler(job.Type)
+	var err error
+	if fn == nil {
+		err = fmt.Errorf("queue: the task %s isn't defined", job.Type)
+	} else {
+		err = q.call(ctx, fn, job, options.Visibility)
+	}
+
+	switch {
+	case err == nil:
+		err = q.backend.Ack(ctx, job)
+	case fn != nil && job.Attempts <= job.MaxRetries:
+		logger.Warn(err)
+		job.LastError = err.Error()
+		err = q.backend.Retry(ctx, job, time.Now().Add(options.backoff(job.Attempts)))
+	default:
+		logger.Error(err)
+		job.LastError, job.FailedAt = err.Error(), time.Now()
+		err = q.backend.Fail(ctx, job)
+	}
+	switch {
+	case errors.Is(err, ErrLostLease):
+		// the job has been delivered to another worker after the visibility timeout
+		logger.Warn(err)
+	case err != nil:
+		logger.Error(err)
+	}
+}
+
+// call call the handler within the visibility timeout, a panic is returned as an error
+func (q *Queue) call(ctx context.Context, fn handler, job *Job, visibility time.Duration) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, visibility)
+	defer cancel()
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("queue: the job panicked: %v", e)
+		}
+	}()
+	return fn(ctx, job)
+}
@@ -0,0 +1,240 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type email struct {
+	To string `json:"to"`
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	var deadline = time.Now().Add(3 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var backend = NewMemoryBackend()
+	var q = New(backend, nil)
+	q.Configure("emails", Options{Concurrency: 2, MaxRetries: 2, Backoff: 10 * time.Millisecond, Poll: 10 * time.Millisecond})
+
+	var sent = make(chan string, 10)
+	var welcome = Define(q, "emails", "welcome", func(ctx context.Context, payload email) error {
+		sent <- payload.To
+		return nil
+	})
+	var failures int32
+	var flaky = Define(q, "emails", "flaky", func(ctx context.Context, payload email) error {
+		if atomic.AddInt32(&failures, 1) < 3 {
+			return errors.New("temporary")
+		}
+		sent <- payload.To
+		return nil
+	})
+	var broken = Define(q, "emails", "broken", func(ctx context.Context, payload email) error {
+		panic("broken")
+	})
+
+	var ctx = context.Background()
+	if err := q.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Stop(ctx)
+
+	if _, err := welcome.Enqueue(ctx, email{To: "now"}); err != nil {
+		t.Fatal(err)
+	}
+	if to := <-sent; to != "now" {
+		t.Errorf("expect now, got %s", to)
+	}
+
+	var start = time.Now()
+	if _, err := welcome.EnqueueIn(ctx, email{To: "later"}, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if to := <-sent; to != "later" || time.Since(start) < 200*time.Millisecond {
+		t.Errorf("the delayed job ran too early or is wrong: %s after %s", to, time.Since(start))
+	}
+
+	if _, err := flaky.Enqueue(ctx, email{To: "retried"}); err != nil {
+		t.Fatal(err)
+	}
+	if to := <-sent; to != "retried" || atomic.LoadInt32(&failures) != 3 {
+		t.Errorf("expect the job to succeed on the third attempt, got %s after %d", to, failures)
+	}
+
+	id, err := broken.Enqueue(ctx, email{To: "dead"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dead []*Job
+	waitFor(t, func() bool {
+		dead, _ = backend.Dead(ctx, "emails", 0, 10)
+		return len(dead) == 1
+	})
+	if dead[0].ID != id || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Errorf("unexpected dead job %+v", dead[0])
+	}
+}
+
+func TestQueue_Unique(t *testing.T) {
+	var q = New(NewMemoryBackend(), nil)
+	var task = Define(q, "", "report", func(ctx context.Context, payload int) error {
+		return nil
+	})
+	var ctx = context.Background()
+	if _, err := task.EnqueueWith(ctx, 1, EnqueueOptions{Unique: "daily"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := task.EnqueueWith(ctx, 2, EnqueueOptions{Unique: "daily"}); err != ErrDuplicate {
+		t.Fatalf("expect ErrDuplicate, got %v", err)
+	}
+
+	job, _ := q.Backend().Dequeue(ctx, DefaultQueue, time.Minute)
+	if err := q.Backend().Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := task.EnqueueWith(ctx, 3, EnqueueOptions{Unique: "daily"}); err != nil {
+		t.Errorf("the unique key should be released after the job finished, got %v", err)
+	}
+}
+
+func TestMemoryBackend_LostLease(t *testing.T) {
+	var backend = NewMemoryBackend()
+	var ctx = context.Background()
+	var job = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now()}
+	if err := backend.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first worker is too slow, the job is delivered to the second worker after the visibility timeout
+	slow, _ := backend.Dequeue(ctx, "default", 30*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	fast, _ := backend.Dequeue(ctx, "default", time.Minute)
+	if slow == nil || fast == nil || fast.ID != slow.ID || fast.Lease == slow.Lease {
+		t.Fatalf("the job should be reserved again, got %+v %+v", slow, fast)
+	}
+	if err := backend.Ack(ctx, slow); err != ErrLostLease {
+		t.Errorf("expect ErrLostLease on ack, got %v", err)
+	}
+	if err := backend.Retry(ctx, slow, time.Now()); err != ErrLostLease {
+		t.Errorf("expect ErrLostLease on retry, got %v", err)
+	}
+	slow.FailedAt = time.Now()
+	if err := backend.Fail(ctx, slow); err != ErrLostLease {
+		t.Errorf("expect ErrLostLease on fail, got %v", err)
+	}
+
+	if err := backend.Ack(ctx, fast); err != nil {
+		t.Fatal(err)
+	}
+	if next, _ := backend.Dequeue(ctx, "default", time.Minute); next != nil {
+		t.Errorf("the acked job should not be delivered again, got %+v", next)
+	}
+	stats, _ := backend.Stats(ctx)
+	if len(stats) != 1 || stats[0].Ready != 0 || stats[0].Running != 0 || stats[0].Dead != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestMemoryBackend_RetryDead(t *testing.T) {
+	var backend = NewMemoryBackend()
+	var ctx = context.Background()
+	var fail = func(unique string) *Job {
+		var job = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now(), Unique: unique}
+		_ = backend.Enqueue(ctx, job)
+		job, _ = backend.Dequeue(ctx, "default", time.Minute)
+		job.FailedAt = time.Now()
+		_ = backend.Fail(ctx, job)
+		return job
+	}
+
+	var dead = fail("daily")
+	var pending = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now().Add(time.Hour), Unique: "daily"}
+	if err := backend.Enqueue(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.RetryDead(ctx, "default", dead.ID); err != ErrDuplicate {
+		t.Fatalf("expect ErrDuplicate, got %v", err)
+	}
+
+	var other = fail("weekly")
+	if err := backend.RetryDead(ctx, "default", other.ID); err != nil {
+		t.Fatal(err)
+	}
+	var duplicate = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now(), Unique: "weekly"}
+	if err := backend.Enqueue(ctx, duplicate); err != ErrDuplicate {
+		t.Errorf("the retried job should take its unique key back, got %v", err)
+	}
+}
+
+func TestMemoryBackend_Visibility(t *testing.T) {
+	var backend = NewMemoryBackend()
+	var ctx = context.Background()
+	var job = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now()}
+	if err := backend.Enqueue(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := backend.Dequeue(ctx, "default", 50*time.Millisecond)
+	if first == nil || first.Attempts != 1 {
+		t.Fatalf("unexpected job %+v", first)
+	}
+	if again, _ := backend.Dequeue(ctx, "default", 50*time.Millisecond); again != nil {
+		t.Fatal("the reserved job should not be delivered again before the visibility timeout")
+	}
+	time.Sleep(60 * time.Millisecond)
+	again, _ := backend.Dequeue(ctx, "default", 50*time.Millisecond)
+	if again == nil || again.ID != job.ID || again.Attempts != 2 {
+		t.Fatalf("the expired job should be delivered again, got %+v", again)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	var backend = NewMemoryBackend()
+	var q = New(backend, nil)
+	var ctx = context.Background()
+	var job = &Job{ID: newJobID(), Queue: "default", Type: "test", RunAt: time.Now()}
+	_ = backend.Enqueue(ctx, job)
+	job, _ = backend.Dequeue(ctx, "default", time.Minute)
+	job.FailedAt = time.Now()
+	_ = backend.Fail(ctx, job)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	AdminRoutes(router, q)
+	var request = func(method, path string) *httptest.ResponseRecorder {
+		var recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		return recorder
+	}
+
+	if recorder := request(http.MethodGet, "/queue/queues"); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodGet, "/queue/queues/default/dead?limit=10"); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodPost, "/queue/queues/default/dead/"+job.ID+"/retry"); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodDelete, "/queue/queues/default/dead/"+job.ID); recorder.Code != http.StatusNotFound {
+		t.Errorf("the retried job isn't dead, expect 404, got %d", recorder.Code)
+	}
+	stats, _ := backend.Stats(ctx)
+	if len(stats) != 1 || stats[0].Ready != 1 || stats[0].Dead != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
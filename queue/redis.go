@@ -0,0 +1,318 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	credis "github.com/kenretto/crane/redis"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// enqueueScript KEYS: jobs, scheduled, unique; ARGV: id, job, run at, unique key
+var enqueueScript = redis.NewScript(`
+if ARGV[4] ~= "" and redis.call("HSETNX", KEYS[3], ARGV[4], ARGV[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
+// dequeueScript KEYS: jobs, scheduled, running, attempts; ARGV: now, visibility deadline
+//  the jobs whose visibility timeout expired are scheduled again first
+var dequeueScript = redis.NewScript(`
+local expired = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1], "LIMIT", 0, 100)
+for _, id in ipairs(expired) do
+	redis.call("ZREM", KEYS[3], id)
+	redis.call("ZADD", KEYS[2], ARGV[1], id)
+end
+local ids = redis.call("ZRANGEBYSCORE", KEYS[2], "-inf", ARGV[1], "LIMIT", 0, 1)
+if #ids == 0 then
+	return false
+end
+redis.call("ZREM", KEYS[2], ids[1])
+local data = redis.call("HGET", KEYS[1], ids[1])
+if not data then
+	redis.call("HDEL", KEYS[4], ids[1])
+	return false
+end
+redis.call("ZADD", KEYS[3], ARGV[2], ids[1])
+local attempts = redis.call("HINCRBY", KEYS[4], ids[1], 1)
+return {data, attempts}
+`)
+
+// ackScript KEYS: jobs, running, unique, attempts; ARGV: id, unique key, lease
+//  0 if the job isn't reserved by the lease
+var ackScript = redis.NewScript(`
+if tonumber(redis.call("ZSCORE", KEYS[2], ARGV[1])) ~= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+redis.call("ZREM", KEYS[2], ARGV[1])
+redis.call("HDEL", KEYS[4], ARGV[1])
+if ARGV[2] ~= "" and redis.call("HGET", KEYS[3], ARGV[2]) == ARGV[1] then
+	redis.call("HDEL", KEYS[3], ARGV[2])
+end
+return 1
+`)
+
+// moveScript KEYS: jobs, running, target, unique; ARGV: id, job, score, unique key, whether the unique key is released, lease
+//  the job is moved to the scheduled jobs for a retry, or to the dead jobs, 0 if the job isn't reserved by the lease
+var moveScript = redis.NewScript(`
+if tonumber(redis.call("ZSCORE", KEYS[2], ARGV[1])) ~= tonumber(ARGV[6]) then
+	return 0
+end
+redis.call("ZREM", KEYS[2], ARGV[1])
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[3], ARGV[3], ARGV[1])
+if ARGV[5] == "1" and ARGV[4] ~= "" and redis.call("HGET", KEYS[4], ARGV[4]) == ARGV[1] then
+	redis.call("HDEL", KEYS[4], ARGV[4])
+end
+return 1
+`)
+
+// retryDeadScript KEYS: dead, jobs, attempts, scheduled, unique; ARGV: id, now
+//  the unique key released when the job failed is taken back first, -1 if another job has taken it, 0 if the job isn't dead
+var retryDeadScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return 0
+end
+local data = redis.call("HGET", KEYS[2], ARGV[1])
+if data then
+	local unique = cjson.decode(data)["unique"]
+	if type(unique) == "string" and unique ~= "" and redis.call("HSETNX", KEYS[5], unique, ARGV[1]) == 0
+		and redis.call("HGET", KEYS[5], unique) ~= ARGV[1] then
+		return -1
+	end
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("HDEL", KEYS[3], ARGV[1])
+redis.call("ZADD", KEYS[4], ARGV[2], ARGV[1])
+return 1
+`)
+
+// RedisBackend keeps the jobs of each queue in a hash, and their ids in the sorted sets of the scheduled, running and dead jobs,
+//  the keys of a queue share a hash tag so that it works on a cluster
+type RedisBackend struct {
+	redis      *credis.Redis
+	connection string
+	prefix     string
+}
+
+// NewRedisBackend connection is the connection of the redis node, default default, prefix is the prefix of the keys, default queue:
+func NewRedisBackend(r *credis.Redis, connection, prefix string) *RedisBackend {
+	if connection == "" {
+		connection = credis.DefaultConnection
+	}
+	if prefix == "" {
+		prefix = "queue:"
+	}
+	return &RedisBackend{redis: r, connection: connection, prefix: prefix}
+}
+
+func (backend *RedisBackend) client() (redis.Cmdable, error) {
+	var client = backend.redis.Connection(backend.connection)
+	if client == nil {
+		return nil, errors.New("queue: the redis connection " + backend.connection + " isn't configured")
+	}
+	return client, nil
+}
+
+func (backend *RedisBackend) key(queue, name string) string {
+	return backend.prefix + "{" + queue + "}:" + name
+}
+
+func milliseconds(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func (backend *RedisBackend) Enqueue(ctx context.Context, job *Job) error {
+	client, err := backend.client()
+	if err != nil {
+		return err
+	}
+	data, err := jsoniter.Marshal(job)
+	if err != nil {
+		return err
+	}
+	var keys = []string{backend.key(job.Queue, "jobs"), backend.key(job.Queue, "scheduled"), backend.key(job.Queue, "unique")}
+	ok, err := enqueueScript.Run(ctx, client, keys, job.ID, data, milliseconds(job.RunAt), job.Unique).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrDuplicate
+	}
+	return client.SAdd(ctx, backend.prefix+"queues", job.Queue).Err()
+}
+
+func (backend *RedisBackend) Dequeue(ctx context.Context, queue string, visibility time.Duration) (*Job, error) {
+	client, err := backend.client()
+	if err != nil {
+		return nil, err
+	}
+	var now = time.Now()
+	var deadline = now.Add(visibility)
+	var keys = []string{backend.key(queue, "jobs"), backend.key(queue, "scheduled"), backend.key(queue, "running"), backend.key(queue, "attempts")}
+	reply, err := dequeueScript.Run(ctx, client, keys, milliseconds(now), milliseconds(deadline)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, ok := reply.([]interface{})
+	if !ok || len(result) != 2 {
+		return nil, errors.New("queue: unexpected reply of the dequeue script")
+	}
+	var job = new(Job)
+	data, _ := result[0].(string)
+	if err = jsoniter.UnmarshalFromString(data, job); err != nil {
+		return nil, err
+	}
+	attempts, _ := result[1].(int64)
+	job.Attempts, job.Lease = int(attempts), deadline.UnixMilli()
+	return job, nil
+}
+
+func (backend *RedisBackend) Ack(ctx context.Context, job *Job) error {
+	client, err := backend.client()
+	if err != nil {
+		return err
+	}
+	var keys = []string{backend.key(job.Queue, "jobs"), backend.key(job.Queue, "running"), backend.key(job.Queue, "unique"), backend.key(job.Queue, "attempts")}
+	acked, err := ackScript.Run(ctx, client, keys, job.ID, job.Unique, job.Lease).Int()
+	if err == nil && acked == 0 {
+		return ErrLostLease
+	}
+	return err
+}
+
+func (backend *RedisBackend) move(ctx context.Context, job *Job, target string, score time.Time, release bool) error {
+	client, err := backend.client()
+	if err != nil {
+		return err
+	}
+	data, err := jsoniter.Marshal(job)
+	if err != nil {
+		return err
+	}
+	var keys = []string{backend.key(job.Queue, "jobs"), backend.key(job.Queue, "running"), backend.key(job.Queue, target), backend.key(job.Queue, "unique")}
+	var released = "0"
+	if release {
+		released = "1"
+	}
+	moved, err := moveScript.Run(ctx, client, keys, job.ID, data, milliseconds(score), job.Unique, released, job.Lease).Int()
+	if err == nil && moved == 0 {
+		return ErrLostLease
+	}
+	return err
+}
+
+func (backend *RedisBackend) Retry(ctx context.Context, job *Job, runAt time.Time) error {
+	return backend.move(ctx, job, "scheduled", runAt, false)
+}
+
+func (backend *RedisBackend) Fail(ctx context.Context, job *Job) error {
+	return backend.move(ctx, job, "dead", job.FailedAt, true)
+}
+
+func (backend *RedisBackend) Stats(ctx context.Context) ([]Stats, error) {
+	client, err := backend.client()
+	if err != nil {
+		return nil, err
+	}
+	queues, err := client.SMembers(ctx, backend.prefix+"queues").Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(queues)
+	var now = milliseconds(time.Now())
+	var stats = make([]Stats, len(queues))
+	for i, queue := range queues {
+		var ready, scheduled, running, dead *redis.IntCmd
+		_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			ready = pipe.ZCount(ctx, backend.key(queue, "scheduled"), "-inf", now)
+			scheduled = pipe.ZCount(ctx, backend.key(queue, "scheduled"), "("+now, "+inf")
+			running = pipe.ZCard(ctx, backend.key(queue, "running"))
+			dead = pipe.ZCard(ctx, backend.key(queue, "dead"))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = Stats{Queue: queue, Ready: ready.Val(), Scheduled: scheduled.Val(), Running: running.Val(), Dead: dead.Val()}
+	}
+	return stats, nil
+}
+
+func (backend *RedisBackend) Dead(ctx context.Context, queue string, offset, limit int64) ([]*Job, error) {
+	client, err := backend.client()
+	if err != nil {
+		return nil, err
+	}
+	var stop int64 = -1
+	if limit > 0 {
+		stop = offset + limit - 1
+	}
+	ids, err := client.ZRevRange(ctx, backend.key(queue, "dead"), offset, stop).Result()
+	if err != nil || len(ids) == 0 {
+		return []*Job{}, err
+	}
+	values, err := client.HMGet(ctx, backend.key(queue, "jobs"), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+	var jobs = make([]*Job, 0, len(values))
+	for _, value := range values {
+		if data, ok := value.(string); ok {
+			var job = new(Job)
+			if err = jsoniter.UnmarshalFromString(data, job); err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (backend *RedisBackend) RetryDead(ctx context.Context, queue, id string) error {
+	client, err := backend.client()
+	if err != nil {
+		return err
+	}
+	var keys = []string{backend.key(queue, "dead"), backend.key(queue, "jobs"), backend.key(queue, "attempts"), backend.key(queue, "scheduled"), backend.key(queue, "unique")}
+	moved, err := retryDeadScript.Run(ctx, client, keys, id, milliseconds(time.Now())).Int()
+	switch {
+	case err != nil:
+		return err
+	case moved == 0:
+		return ErrNotFound
+	case moved < 0:
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (backend *RedisBackend) DeleteDead(ctx context.Context, queue, id string) error {
+	client, err := backend.client()
+	if err != nil {
+		return err
+	}
+	removed, err := client.ZRem(ctx, backend.key(queue, "dead"), id).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrNotFound
+	}
+	_, err = client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, backend.key(queue, "jobs"), id)
+		pipe.HDel(ctx, backend.key(queue, "attempts"), id)
+		return nil
+	})
+	return err
+}
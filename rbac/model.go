@@ -0,0 +1,63 @@
+package rbac
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Role a role, it inherits all the permissions of its child roles, the roles whose ParentID is its ID,
+//  such as admin as the parent of editor, admin is granted everything editor is granted, but not vice versa
+type Role struct {
+	ID          uint      `gorm:"column:id;primaryKey"`
+	Name        string    `gorm:"column:name;type:varchar(64);uniqueIndex"`
+	ParentID    *uint     `gorm:"column:parent_id;index"`
+	Description string    `gorm:"column:description;type:varchar(255)"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+func (Role) TableName() string {
+	return "rbac_roles"
+}
+
+// Permission a named permission, it can also protect routes with Method and Path,
+//  Method: the http method, empty or * for any method
+//  Path: the route path, * matches a segment and a trailing /** matches any suffix, such as /admin/users/* or /admin/**
+type Permission struct {
+	ID          uint      `gorm:"column:id;primaryKey"`
+	Name        string    `gorm:"column:name;type:varchar(128);uniqueIndex"`
+	Method      string    `gorm:"column:method;type:varchar(16)"`
+	Path        string    `gorm:"column:path;type:varchar(255)"`
+	Description string    `gorm:"column:description;type:varchar(255)"`
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+func (Permission) TableName() string {
+	return "rbac_permissions"
+}
+
+// RolePermission the permissions granted to the roles
+type RolePermission struct {
+	RoleID       uint `gorm:"column:role_id;primaryKey"`
+	PermissionID uint `gorm:"column:permission_id;primaryKey"`
+}
+
+func (RolePermission) TableName() string {
+	return "rbac_role_permissions"
+}
+
+// UserRole the roles assigned to the users, UserID is the topic of middleware.AuthInterface formatted as string
+type UserRole struct {
+	UserID string `gorm:"column:user_id;type:varchar(64);primaryKey"`
+	RoleID uint   `gorm:"column:role_id;primaryKey"`
+}
+
+func (UserRole) TableName() string {
+	return "rbac_user_roles"
+}
+
+// Migrate create or update the tables
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Role{}, &Permission{}, &RolePermission{}, &UserRole{})
+}
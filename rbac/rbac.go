@@ -0,0 +1,324 @@
+// Package rbac role based access control, the roles and permissions are stored with gorm and cached in redis,
+//  use it with middleware.SetAuthorizer, middleware.Require and middleware.RequireRoute
+package rbac
+
+import (
+	"context"
+	"errors"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kenretto/crane/redis"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Grants the roles and permissions of a user, including the ones inherited from the child roles
+type Grants struct {
+	Roles       []string     `json:"roles"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Has whether the permission is granted
+func (grants *Grants) Has(permission string) bool {
+	for _, p := range grants.Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows whether any granted permission matches the route
+func (grants *Grants) Allows(method, path string) bool {
+	return matchAny(grants.Permissions, method, path)
+}
+
+func matchAny(permissions []Permission, method, path string) bool {
+	for _, p := range permissions {
+		if p.Path != "" && (p.Method == "" || p.Method == "*" || strings.EqualFold(p.Method, method)) && Match(p.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match match the path with the pattern, * matches a segment, a trailing /** matches any suffix
+func Match(pattern, path string) bool {
+	var patterns = strings.Split(strings.Trim(pattern, "/"), "/")
+	var segments = strings.Split(strings.Trim(path, "/"), "/")
+	for i, p := range patterns {
+		if p == "**" && i == len(patterns)-1 {
+			return true
+		}
+		if i >= len(segments) || (p != "*" && p != segments[i]) {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
+
+// expand the ids of the roles and all their descendants
+func expand(roles []Role, assigned []uint) []uint {
+	var children = make(map[uint][]uint, len(roles))
+	for _, role := range roles {
+		if role.ParentID != nil {
+			children[*role.ParentID] = append(children[*role.ParentID], role.ID)
+		}
+	}
+
+	var visited = make(map[uint]bool)
+	var result = make([]uint, 0, len(assigned))
+	var pending = append([]uint(nil), assigned...)
+	for len(pending) > 0 {
+		var id = pending[0]
+		pending = pending[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		result = append(result, id)
+		pending = append(pending, children[id]...)
+	}
+	return result
+}
+
+// Enforcer loads the grants of the users from the database and caches them in redis, loaded from the rbac node
+type Enforcer struct {
+	Config struct {
+		Default     string `mapstructure:"default"`      // deny (default) or allow, the decision of the routes not protected by any permission
+		Database    string `mapstructure:"database"`     // the connection name of the database node, default master
+		CacheTTL    string `mapstructure:"cache_ttl"`    // default 10m
+		CachePrefix string `mapstructure:"cache_prefix"` // default rbac_
+	}
+
+	db    func(db ...string) *gorm.DB
+	redis *redis.Redis
+	rw    sync.RWMutex
+}
+
+// NewEnforcer db is usually crane.ORM, r can be nil to disable the cache
+func NewEnforcer(db func(db ...string) *gorm.DB, r *redis.Redis) *Enforcer {
+	return &Enforcer{db: db, redis: r}
+}
+
+func (e *Enforcer) Node() string {
+	return "rbac"
+}
+
+// Describe the rbac node is unmarshalled into Config
+func (e *Enforcer) Describe() interface{} {
+	return &e.Config
+}
+
+// OnChange the configuration takes effect on the next check
+func (e *Enforcer) OnChange(viper *viper.Viper) {
+	e.rw.Lock()
+	defer e.rw.Unlock()
+	_ = viper.Unmarshal(&e.Config)
+}
+
+func (e *Enforcer) database() *gorm.DB {
+	e.rw.RLock()
+	defer e.rw.RUnlock()
+	if e.Config.Database == "" {
+		return e.db()
+	}
+	return e.db(e.Config.Database)
+}
+
+func (e *Enforcer) prefix() string {
+	e.rw.RLock()
+	defer e.rw.RUnlock()
+	if e.Config.CachePrefix == "" {
+		return "rbac_"
+	}
+	return e.Config.CachePrefix
+}
+
+func (e *Enforcer) ttl() time.Duration {
+	e.rw.RLock()
+	defer e.rw.RUnlock()
+	d, err := time.ParseDuration(e.Config.CacheTTL)
+	if err != nil {
+		return 10 * time.Minute
+	}
+	return d
+}
+
+// cached get the value from the cache, or load and cache it, the cache keys contain the policy version, so Invalidate drops all of them
+func (e *Enforcer) cached(ctx context.Context, name string, value interface{}, load func() error) error {
+	if e.redis == nil || e.redis.Instance() == nil || e.ttl() <= 0 {
+		return load()
+	}
+	var client = e.redis.Instance()
+	version, err := client.Get(ctx, e.prefix()+"version").Result()
+	if err != nil {
+		version = "0"
+	}
+	var key = e.prefix() + version + ":" + name
+	if data, err := client.Get(ctx, key).Bytes(); err == nil && jsoniter.Unmarshal(data, value) == nil {
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+	if data, err := jsoniter.Marshal(value); err == nil {
+		client.Set(ctx, key, data, e.ttl())
+	}
+	return nil
+}
+
+// Grants the grants of the user
+func (e *Enforcer) Grants(ctx context.Context, user string) (*Grants, error) {
+	var grants = new(Grants)
+	err := e.cached(ctx, "user:"+user, grants, func() error {
+		var db = e.database().WithContext(ctx)
+		var roles []Role
+		if err := db.Find(&roles).Error; err != nil {
+			return err
+		}
+		var assigned []uint
+		if err := db.Model(&UserRole{}).Where("user_id = ?", user).Pluck("role_id", &assigned).Error; err != nil {
+			return err
+		}
+
+		var ids = expand(roles, assigned)
+		var names = make(map[uint]string, len(roles))
+		for _, role := range roles {
+			names[role.ID] = role.Name
+		}
+		grants.Roles = make([]string, 0, len(ids))
+		for _, id := range ids {
+			grants.Roles = append(grants.Roles, names[id])
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return db.Distinct(Permission{}.TableName()+".*").
+			Joins("JOIN "+RolePermission{}.TableName()+" ON "+RolePermission{}.TableName()+".permission_id = "+Permission{}.TableName()+".id").
+			Where(RolePermission{}.TableName()+".role_id IN ?", ids).
+			Find(&grants.Permissions).Error
+	})
+	return grants, err
+}
+
+// routes all the permissions protecting routes
+func (e *Enforcer) routes(ctx context.Context) ([]Permission, error) {
+	var permissions []Permission
+	err := e.cached(ctx, "routes", &permissions, func() error {
+		return e.database().WithContext(ctx).Where("path <> ''").Find(&permissions).Error
+	})
+	return permissions, err
+}
+
+// Can whether the user has the permission
+func (e *Enforcer) Can(ctx context.Context, user, permission string) (bool, error) {
+	grants, err := e.Grants(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return grants.Has(permission), nil
+}
+
+// CanRoute whether the user can access the route, routes not protected by any permission are decided by the default config
+func (e *Enforcer) CanRoute(ctx context.Context, user, method, path string) (bool, error) {
+	grants, err := e.Grants(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	if grants.Allows(method, path) {
+		return true, nil
+	}
+
+	routes, err := e.routes(ctx)
+	if err != nil {
+		return false, err
+	}
+	if matchAny(routes, method, path) {
+		return false, nil
+	}
+	e.rw.RLock()
+	defer e.rw.RUnlock()
+	return e.Config.Default == "allow", nil
+}
+
+// Invalidate drop the cached grants of all users, it's called by the methods changing the policy,
+//  call it after changing the tables directly
+func (e *Enforcer) Invalidate(ctx context.Context) error {
+	if e.redis == nil || e.redis.Instance() == nil {
+		return nil
+	}
+	return e.redis.Instance().Incr(ctx, e.prefix()+"version").Err()
+}
+
+func (e *Enforcer) role(db *gorm.DB, name string) (Role, error) {
+	var role Role
+	err := db.Where("name = ?", name).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return role, errors.New("rbac: role " + name + " not found")
+	}
+	return role, err
+}
+
+// AssignRole assign the role to the user
+func (e *Enforcer) AssignRole(ctx context.Context, user, roleName string) error {
+	var db = e.database().WithContext(ctx)
+	role, err := e.role(db, roleName)
+	if err != nil {
+		return err
+	}
+	if err = db.Where(UserRole{UserID: user, RoleID: role.ID}).FirstOrCreate(&UserRole{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate(ctx)
+}
+
+// UnassignRole remove the role from the user
+func (e *Enforcer) UnassignRole(ctx context.Context, user, roleName string) error {
+	var db = e.database().WithContext(ctx)
+	role, err := e.role(db, roleName)
+	if err != nil {
+		return err
+	}
+	if err = db.Where("user_id = ? AND role_id = ?", user, role.ID).Delete(&UserRole{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate(ctx)
+}
+
+// Grant grant the permission to the role
+func (e *Enforcer) Grant(ctx context.Context, roleName, permission string) error {
+	var db = e.database().WithContext(ctx)
+	role, err := e.role(db, roleName)
+	if err != nil {
+		return err
+	}
+	var p Permission
+	if err = db.Where("name = ?", permission).First(&p).Error; err != nil {
+		return err
+	}
+	if err = db.Where(RolePermission{RoleID: role.ID, PermissionID: p.ID}).FirstOrCreate(&RolePermission{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate(ctx)
+}
+
+// Revoke revoke the permission from the role
+func (e *Enforcer) Revoke(ctx context.Context, roleName, permission string) error {
+	var db = e.database().WithContext(ctx)
+	role, err := e.role(db, roleName)
+	if err != nil {
+		return err
+	}
+	var p Permission
+	if err = db.Where("name = ?", permission).First(&p).Error; err != nil {
+		return err
+	}
+	if err = db.Where("role_id = ? AND permission_id = ?", role.ID, p.ID).Delete(&RolePermission{}).Error; err != nil {
+		return err
+	}
+	return e.Invalidate(ctx)
+}
@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	var cases = []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"/admin/users", "/admin/users", true},
+		{"/admin/users", "/admin/users/1", false},
+		{"/admin/users/*", "/admin/users/1", true},
+		{"/admin/users/*", "/admin/users/1/edit", false},
+		{"/admin/**", "/admin/users/1/edit", true},
+		{"/admin/**", "/member", false},
+	}
+	for _, item := range cases {
+		if Match(item.pattern, item.path) != item.match {
+			t.Errorf("%s %s: expect %v", item.pattern, item.path, item.match)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	var admin, editor uint = 1, 2
+	var roles = []Role{
+		{ID: admin, Name: "admin"},
+		{ID: editor, Name: "editor", ParentID: &admin},
+		{ID: 3, Name: "writer", ParentID: &editor},
+		{ID: 4, Name: "guest"},
+	}
+	// the parent roles inherit the permissions of the child roles
+	if ids := expand(roles, []uint{1, 4}); !reflect.DeepEqual(ids, []uint{1, 4, 2, 3}) {
+		t.Errorf("unexpected roles %v", ids)
+	}
+	if ids := expand(roles, []uint{3}); !reflect.DeepEqual(ids, []uint{3}) {
+		t.Errorf("the child role should not inherit the parent, got %v", ids)
+	}
+
+	// circular parents don't loop forever
+	roles[0].ParentID = &editor
+	if ids := expand(roles, []uint{2}); !reflect.DeepEqual(ids, []uint{2, 1, 3}) {
+		t.Errorf("unexpected roles %v", ids)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	var grants = Grants{Permissions: []Permission{
+		{Name: "user.edit", Method: "PUT", Path: "/admin/users/*"},
+		{Name: "report.view", Path: "/admin/reports/**"},
+	}}
+	if !grants.Has("user.edit") || grants.Has("user.delete") {
+		t.Error("unexpected Has result")
+	}
+	if !grants.Allows("PUT", "/admin/users/1") || grants.Allows("DELETE", "/admin/users/1") || !grants.Allows("GET", "/admin/reports/2020/12") {
+		t.Error("unexpected Allows result")
+	}
+}
@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// json the members of the sorted sets and the streams must encode the same value to the same bytes, so the map keys are sorted
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Hash redis hash 操作
+//  字段的值以可读的方式保存, 字符串, 数字和布尔值保存为字符串, time.Time 保存为 RFC3339, []byte 原样保存, 其他类型保存为 json,
+//  所以 hash 可以被其他语言直接读取, 也可以直接 HIncrBy
+type Hash struct {
+	client redis.Cmdable
+	key    string
+	ctx    context.Context
+}
+
+// formatField 将字段的值转为 redis 保存的字符串
+func formatField(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	data, err := json.Marshal(v.Interface())
+	return string(data), err
+}
+
+// parseField 将 redis 保存的字符串解析到 v, v 必须可以被设置
+func parseField(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		v.SetBool(b)
+		return err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		v.SetInt(i)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		v.SetUint(i)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		v.SetFloat(f)
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		v.Set(reflect.ValueOf(t))
+		return err
+	}
+	return json.Unmarshal([]byte(s), v.Addr().Interface())
+}
+
+// fieldName 字段在 hash 中的名称, 优先使用 redis tag, "-" 表示忽略该字段
+func fieldName(field reflect.StructField) string {
+	if field.PkgPath != "" {
+		return ""
+	}
+	var name = strings.Split(field.Tag.Get("redis"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// HSet 设置字段的值
+func (h *Hash) HSet(field string, val interface{}) error {
+	value, err := formatField(reflect.ValueOf(val))
+	if err != nil {
+		return err
+	}
+	return h.client.HSet(h.ctx, h.key, field, value).Err()
+}
+
+// HGet 获取字段的值并绑定到 val, val 必须是一个引用, 字段不存在时返回 redis.Nil
+func (h *Hash) HGet(field string, val interface{}) error {
+	value, err := h.client.HGet(h.ctx, h.key, field).Result()
+	if err != nil {
+		return err
+	}
+	return parseField(value, reflect.ValueOf(val).Elem())
+}
+
+// HSetStruct 将结构的导出字段写入 hash, 字段名默认为结构的字段名, 可以用 redis tag 指定, 比如
+//  type User struct {
+//      Name    string    `redis:"name"`
+//      Age     int       `redis:"age"`
+//      Roles   []string  `redis:"roles"`
+//      Secret  string    `redis:"-"`
+//  }
+func (h *Hash) HSetStruct(val interface{}) error {
+	var v = reflect.Indirect(reflect.ValueOf(val))
+	if v.Kind() != reflect.Struct {
+		return errors.New("redis: HSetStruct expects a struct")
+	}
+	var values = make([]interface{}, 0, v.NumField()*2)
+	for i := 0; i < v.NumField(); i++ {
+		var name = fieldName(v.Type().Field(i))
+		if name == "" {
+			continue
+		}
+		value, err := formatField(v.Field(i))
+		if err != nil {
+			return err
+		}
+		values = append(values, name, value)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return h.client.HSet(h.ctx, h.key, values...).Err()
+}
+
+// HGetStruct 将 hash 的字段绑定到结构, val 必须是结构的引用, hash 中不存在的字段保持不变, hash 不存在时返回 redis.Nil
+func (h *Hash) HGetStruct(val interface{}) error {
+	var v = reflect.ValueOf(val)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("redis: HGetStruct expects a pointer to a struct")
+	}
+	values, err := h.client.HGetAll(h.ctx, h.key).Result()
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return redis.Nil
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		var name = fieldName(v.Type().Field(i))
+		value, ok := values[name]
+		if name == "" || !ok {
+			continue
+		}
+		if err = parseField(value, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HDel 删除字段, 返回删除的数量
+func (h *Hash) HDel(fields ...string) (int64, error) {
+	return h.client.HDel(h.ctx, h.key, fields...).Result()
+}
+
+// HExists 字段是否存在
+func (h *Hash) HExists(field string) (bool, error) {
+	return h.client.HExists(h.ctx, h.key, field).Result()
+}
+
+// HLen 字段的数量
+func (h *Hash) HLen() (int64, error) {
+	return h.client.HLen(h.ctx, h.key).Result()
+}
+
+// HKeys 所有的字段名
+func (h *Hash) HKeys() ([]string, error) {
+	return h.client.HKeys(h.ctx, h.key).Result()
+}
+
+// HIncrBy 将整数字段增加 incr, 返回增加后的值
+func (h *Hash) HIncrBy(field string, incr int64) (int64, error) {
+	return h.client.HIncrBy(h.ctx, h.key, field, incr).Result()
+}
@@ -0,0 +1,307 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotObtained the lock is held by another owner
+	ErrNotObtained = errors.New("redis: lock not obtained")
+	// ErrLockLost the lock expired or was taken by another owner before it's released
+	ErrLockLost = errors.New("redis: lock lost")
+)
+
+// acquireScript set the lock and increase its fencing token, the keys share a hash tag so that it works on a cluster
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0
+`)
+
+// renewScript extend the lock only if it's still held by the owner
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript delete the lock only if it's still held by the owner
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Locker distributed locks on one or more connections of the redis node, with more than one connection the locks are
+//  acquired with the Redlock algorithm, a lock is obtained when it's set on the majority of the connections
+//  var locker = crane.Redis().Locker()
+//  lock, err := locker.Lock(ctx, "payment:"+orderID, 10*time.Second)
+//  if err != nil {
+//      return err
+//  }
+//  defer lock.Release(context.Background())
+//  // pass lock.Token() to the storage, which rejects the writes with a smaller token, only on one connection
+type Locker struct {
+	// Prefix the prefix of the keys, default lock:
+	Prefix string
+	// Retries how many times TryLock retries, 0 means trying once
+	Retries int
+	// Backoff the first delay between the retries, doubled on each retry up to MaxBackoff, default 50ms and 1s
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// NoRenew disables the automatic renewal, the lock then expires after its ttl even if it's still held
+	NoRenew bool
+
+	redis       *Redis
+	connections []string
+}
+
+// Locker the locker on the connections, default the default connection
+func (s *Redis) Locker(connections ...string) *Locker {
+	if len(connections) == 0 {
+		connections = []string{DefaultConnection}
+	}
+	return &Locker{Prefix: "lock:", Backoff: 50 * time.Millisecond, MaxBackoff: time.Second, redis: s, connections: connections}
+}
+
+// Lock obtain the lock on the default connection, waiting until it's released or ctx is done
+func (s *Redis) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return s.Locker().Lock(ctx, key, ttl)
+}
+
+// TryLock try to obtain the lock on the default connection once, ErrNotObtained if it's held by another owner
+func (s *Redis) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return s.Locker().TryLock(ctx, key, ttl)
+}
+
+func (locker *Locker) clients() ([]redis.Cmdable, error) {
+	var clients = make([]redis.Cmdable, len(locker.connections))
+	for i, name := range locker.connections {
+		if clients[i] = locker.redis.Connection(name); clients[i] == nil {
+			return nil, errors.New("redis: the connection " + name + " isn't configured")
+		}
+	}
+	return clients, nil
+}
+
+func (locker *Locker) quorum() int {
+	return len(locker.connections)/2 + 1
+}
+
+func (locker *Locker) keys(key string) []string {
+	// the hash tag keeps the lock and its token in the same slot
+	var tagged = locker.Prefix + "{" + key + "}"
+	return []string{tagged, tagged + ":token"}
+}
+
+// backoff the jittered delay before the retry
+func (locker *Locker) backoff(retry int) time.Duration {
+	var delay = locker.Backoff
+	for i := 0; i < retry && delay < locker.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > locker.MaxBackoff {
+		delay = locker.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(mrand.Int63n(int64(delay/2)+1))
+}
+
+// Lock obtain the lock, waiting until it's released or ctx is done
+func (locker *Locker) Lock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for retry := 0; ; retry++ {
+		lock, err := locker.acquire(ctx, key, ttl)
+		if err != ErrNotObtained {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(locker.backoff(retry)):
+		}
+	}
+}
+
+// TryLock try to obtain the lock, retried Retries times, ErrNotObtained if it's still held by another owner
+func (locker *Locker) TryLock(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	for retry := 0; ; retry++ {
+		lock, err := locker.acquire(ctx, key, ttl)
+		if err != ErrNotObtained || retry >= locker.Retries {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(locker.backoff(retry)):
+		}
+	}
+}
+
+// acquire set the lock on the connections, the lock is released on all of them if it isn't obtained on the majority in time
+func (locker *Locker) acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	clients, err := locker.clients()
+	if err != nil {
+		return nil, err
+	}
+	var value = make([]byte, 16)
+	if _, err = rand.Read(value); err != nil {
+		return nil, err
+	}
+	var lock = &Lock{
+		locker:  locker,
+		clients: clients,
+		keys:    locker.keys(key),
+		value:   base64.RawURLEncoding.EncodeToString(value),
+		ttl:     ttl,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		lost:    make(chan struct{}),
+	}
+
+	var start = time.Now()
+	var obtained int
+	var errs []error
+	for _, client := range clients {
+		token, err := acquireScript.Run(ctx, client, lock.keys, lock.value, ttl.Milliseconds()).Int64()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if token > 0 {
+			obtained++
+		}
+		// the counters of the connections differ, so the max of them isn't monotonic, Redlock has no fencing token
+		if len(clients) == 1 {
+			lock.token = token
+		}
+	}
+	// the clock drift of Redlock
+	var validity = ttl - time.Since(start) - ttl/100 - 2*time.Millisecond
+	if obtained < locker.quorum() || validity <= 0 {
+		_ = lock.release(context.Background())
+		if len(errs) >= locker.quorum() {
+			return nil, errs[0]
+		}
+		return nil, ErrNotObtained
+	}
+
+	if locker.NoRenew {
+		close(lock.done)
+	} else {
+		go lock.renew()
+	}
+	return lock, nil
+}
+
+// Lock an obtained lock
+type Lock struct {
+	locker  *Locker
+	clients []redis.Cmdable
+	keys    []string
+	value   string
+	token   int64
+	ttl     time.Duration
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+	lost chan struct{}
+}
+
+// Key the key of the lock in redis
+func (lock *Lock) Key() string {
+	return lock.keys[0]
+}
+
+// Token the fencing token, it increases every time the key is locked, so the storage protected by the lock can reject
+//  the writes of an owner whose lock has expired, it's always 0 with Redlock, since the counters of the connections
+//  are independent and none of them increases on every lock
+func (lock *Lock) Token() int64 {
+	return lock.token
+}
+
+// Lost closed when the renewal fails, the lock may be held by another owner after that
+func (lock *Lock) Lost() <-chan struct{} {
+	return lock.lost
+}
+
+// extend extend the lock on the connections, whether it's still held on the majority
+func (lock *Lock) extend(ctx context.Context, ttl time.Duration) bool {
+	var extended int
+	for _, client := range lock.clients {
+		if n, err := renewScript.Run(ctx, client, lock.keys[:1], lock.value, ttl.Milliseconds()).Int64(); err == nil && n == 1 {
+			extended++
+		}
+	}
+	return extended >= lock.locker.quorum()
+}
+
+// renew extend the lock every third of the ttl until it's released
+func (lock *Lock) renew() {
+	defer close(lock.done)
+	var ticker = time.NewTicker(lock.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), lock.ttl/3)
+			var ok = lock.extend(ctx, lock.ttl)
+			cancel()
+			if !ok {
+				close(lock.lost)
+				return
+			}
+		}
+	}
+}
+
+// Refresh extend the lock to ttl, ErrLockLost if it isn't held anymore
+func (lock *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if !lock.extend(ctx, ttl) {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (lock *Lock) release(ctx context.Context) error {
+	var released int
+	var err error
+	for _, client := range lock.clients {
+		n, e := releaseScript.Run(ctx, client, lock.keys[:1], lock.value).Int64()
+		if e != nil && err == nil {
+			err = e
+		}
+		if n == 1 {
+			released++
+		}
+	}
+	if released < lock.locker.quorum() && err == nil {
+		err = ErrLockLost
+	}
+	return err
+}
+
+// Release stop the renewal and delete the lock, ErrLockLost if it had expired or been taken by another owner
+func (lock *Lock) Release(ctx context.Context) error {
+	var err = ErrLockLost
+	lock.once.Do(func() {
+		close(lock.stop)
+		<-lock.done
+		err = lock.release(ctx)
+	})
+	return err
+}
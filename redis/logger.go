@@ -1,28 +1,11 @@
 package redis
 
-import "log"
+import "github.com/kenretto/crane/logging"
 
-// ILogger logger interface
-type ILogger interface {
-	Warn(args ...interface{})
-	Error(args ...interface{})
-}
-
-// DefaultLogger default logger
-type DefaultLogger struct {
-}
-
-// Error error...
-func (d DefaultLogger) Error(args ...interface{}) {
-	log.Println(args...)
-}
-
-// Warn warn...
-func (d DefaultLogger) Warn(args ...interface{}) {
-	log.Println(args...)
-}
+// ILogger logger interface, see package logging for the adapters
+type ILogger = logging.Logger
 
 // NewDefaultLogger default logger
-func NewDefaultLogger() *DefaultLogger {
-	return &DefaultLogger{}
+func NewDefaultLogger() ILogger {
+	return logging.Default()
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/gob"
 	"github.com/go-redis/redis/v8"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -29,9 +30,13 @@ type RBinding struct {
 
 // NewDefaultRBinding Gets a default binding for the rbinding operation
 func NewDefaultRBinding(ctx context.Context, r *Redis) *RBinding {
+	var log = r.logger
+	if log == nil {
+		log = NewDefaultLogger()
+	}
 	return &RBinding{
 		client: r,
-		log:    r.logger,
+		log:    log,
 		ctx:    ctx,
 	}
 }
@@ -40,8 +45,10 @@ func NewDefaultRBinding(ctx context.Context, r *Redis) *RBinding {
 func (r *RBinding) Set(key string, val interface{}, expiration time.Duration) (err error) {
 	var buffer = new(bytes.Buffer)
 	err = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(val))
-	r.client.Instance().Set(r.ctx, key, buffer.Bytes(), expiration)
-	return
+	if err != nil {
+		return err
+	}
+	return r.client.Instance().Set(r.ctx, key, buffer.Bytes(), expiration).Err()
 }
 
 // Get redis get
@@ -86,8 +93,8 @@ func (r *RBinding) GetSet(key string, val interface{}) (err error) {
 }
 
 // Del redis del
-func (r *RBinding) Del(key string) {
-	r.client.Instance().Del(r.ctx, key)
+func (r *RBinding) Del(keys ...string) error {
+	return r.client.Instance().Del(r.ctx, keys...).Err()
 }
 
 // SetNX set nx
@@ -154,6 +161,45 @@ func (r *RBinding) Sets(key string) *Set {
 	return &Set{client: r.client.Instance(), key: key, logger: r.log, ctx: r.ctx}
 }
 
+// Hash redis hash 结构, 可以与 struct 互相映射
+func (r *RBinding) Hash(key string) *Hash {
+	return &Hash{client: r.client.Instance(), key: key, ctx: r.ctx}
+}
+
+// encode gob 序列化 val
+func encode(val interface{}) ([]byte, error) {
+	var buffer = new(bytes.Buffer)
+	err := gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(val))
+	return buffer.Bytes(), err
+}
+
+// encodeAll gob 序列化所有的 values
+func encodeAll(values []interface{}) ([]interface{}, error) {
+	var val = make([]interface{}, len(values))
+	for i := range values {
+		data, err := encode(values[i])
+		if err != nil {
+			return nil, err
+		}
+		val[i] = data
+	}
+	return val, nil
+}
+
+// decodeSlice 将 redis 返回的 values 解析为 container 类型的切片
+func decodeSlice(values []string, container interface{}) (interface{}, error) {
+	var typ = reflect.TypeOf(container)
+	var items = reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
+	for i := range values {
+		var val = reflect.New(typ)
+		if err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val); err != nil {
+			return items.Interface(), err
+		}
+		items.Index(i).Set(val.Elem())
+	}
+	return items.Interface(), nil
+}
+
 // List redis list 数据结构
 type List struct {
 	client redis.Cmdable
@@ -162,19 +208,17 @@ type List struct {
 }
 
 // Len list len
-func (l *List) Len() int64 {
-	return l.client.LLen(l.ctx, l.key).Val()
+func (l *List) Len() (int64, error) {
+	return l.client.LLen(l.ctx, l.key).Result()
 }
 
 // LPush redis Left Push
-func (l *List) LPush(values ...interface{}) {
-	var val = make([]interface{}, len(values))
-	for i := range values {
-		var buffer = new(bytes.Buffer)
-		_ = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(values[i]))
-		val[i] = buffer.Bytes()
+func (l *List) LPush(values ...interface{}) error {
+	val, err := encodeAll(values)
+	if err != nil {
+		return err
 	}
-	l.client.LPush(l.ctx, l.key, val...)
+	return l.client.LPush(l.ctx, l.key, val...).Err()
 }
 
 // LPop redis Left pop
@@ -189,14 +233,12 @@ func (l *List) LPop(val interface{}) error {
 }
 
 // RPush redis right Push
-func (l *List) RPush(values ...interface{}) {
-	var val = make([]interface{}, len(values))
-	for i := range values {
-		var buffer = new(bytes.Buffer)
-		_ = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(values[i]))
-		val[i] = buffer.Bytes()
+func (l *List) RPush(values ...interface{}) error {
+	val, err := encodeAll(values)
+	if err != nil {
+		return err
 	}
-	l.client.RPush(l.ctx, l.key, val...)
+	return l.client.RPush(l.ctx, l.key, val...).Err()
 }
 
 // RPop redis right pop
@@ -210,6 +252,52 @@ func (l *List) RPop(val interface{}) error {
 	return err
 }
 
+// BLPop 阻塞的 Left pop, 最多等待 timeout, 0 表示一直等待, 超时返回 redis.Nil
+func (l *List) BLPop(timeout time.Duration, val interface{}) error {
+	values, err := l.client.BLPop(l.ctx, timeout, l.key).Result()
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(strings.NewReader(values[1])).DecodeValue(reflect.ValueOf(val))
+}
+
+// BRPop 阻塞的 right pop, 最多等待 timeout, 0 表示一直等待, 超时返回 redis.Nil
+func (l *List) BRPop(timeout time.Duration, val interface{}) error {
+	values, err := l.client.BRPop(l.ctx, timeout, l.key).Result()
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(strings.NewReader(values[1])).DecodeValue(reflect.ValueOf(val))
+}
+
+// BRPopLPush 阻塞的从本 list 右侧取出一个值放入 destination 的左侧, 并绑定到 val, 超时返回 redis.Nil
+//  常用于可靠队列, 处理完成后从 destination 中 LRem 掉该值
+func (l *List) BRPopLPush(destination string, timeout time.Duration, val interface{}) error {
+	data, err := l.client.BRPopLPush(l.ctx, l.key, destination, timeout).Bytes()
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(data)).DecodeValue(reflect.ValueOf(val))
+}
+
+// LRange 返回指定区间的元素, container 同 Set.SDiff
+func (l *List) LRange(container interface{}, start, stop int64) (interface{}, error) {
+	values, err := l.client.LRange(l.ctx, l.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeSlice(values, container)
+}
+
+// LRem 移除 count 个等于 value 的元素, count 为 0 时移除所有
+func (l *List) LRem(count int64, value interface{}) (int64, error) {
+	data, err := encode(value)
+	if err != nil {
+		return 0, err
+	}
+	return l.client.LRem(l.ctx, l.key, count, data).Result()
+}
+
 // Set redis set 操作
 type Set struct {
 	client redis.Cmdable
@@ -219,159 +307,93 @@ type Set struct {
 }
 
 // SAdd 向集合添加一个或多个成员
-func (set *Set) SAdd(members ...interface{}) {
-	var val = make([]interface{}, len(members))
-	for i := range members {
-		var buffer = new(bytes.Buffer)
-		_ = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(members[i]))
-		val[i] = buffer.Bytes()
+func (set *Set) SAdd(members ...interface{}) error {
+	val, err := encodeAll(members)
+	if err != nil {
+		return err
 	}
-
-	set.client.SAdd(set.ctx, set.key, val...)
+	return set.client.SAdd(set.ctx, set.key, val...).Err()
 }
 
-// SCard 向集合添加一个或多个成员
-func (set *Set) SCard() int64 {
-	return set.client.SCard(set.ctx, set.key).Val()
+// SCard 获取集合的成员数
+func (set *Set) SCard() (int64, error) {
+	return set.client.SCard(set.ctx, set.key).Result()
 }
 
 // SDiff 返回第一个集合与其他集合之间的差异。
 //  container 是一个结构, 比如这个 set 的值是 string, container 就是 string, 值是struct,container就传struct, 不需要是个引用
-func (set *Set) SDiff(container interface{}, diffKeys ...string) interface{} {
-	var keys []string
-	keys = append(keys, set.key)
-	keys = append(keys, diffKeys...)
-
-	var values = set.client.SDiff(set.ctx, keys...).Val()
-	var typ = reflect.TypeOf(container)
-
-	newInstance := reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
-	items := reflect.New(newInstance.Type())
-	items.Elem().Set(newInstance)
-
-	var val = reflect.New(typ)
-	for i := range values {
-		err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val)
-		if err != nil {
-			set.logger.Warn(err)
-		}
-		items.Elem().Index(i).Set(val.Elem())
+func (set *Set) SDiff(container interface{}, diffKeys ...string) (interface{}, error) {
+	values, err := set.client.SDiff(set.ctx, append([]string{set.key}, diffKeys...)...).Result()
+	if err != nil {
+		return nil, err
 	}
-
-	return items.Elem().Interface()
+	return decodeSlice(values, container)
 }
 
 // SInter 返回给定所有集合的交集
-func (set *Set) SInter(container interface{}, diffKeys ...string) interface{} {
-	var keys []string
-	keys = append(keys, set.key)
-	keys = append(keys, diffKeys...)
-
-	var values = set.client.SInter(set.ctx, keys...).Val()
-	var typ = reflect.TypeOf(container)
-
-	newInstance := reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
-	items := reflect.New(newInstance.Type())
-	items.Elem().Set(newInstance)
-
-	var val = reflect.New(typ)
-	for i := range values {
-		err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val)
-		if err != nil {
-			set.logger.Warn(err)
-		}
-		items.Elem().Index(i).Set(val.Elem())
+func (set *Set) SInter(container interface{}, diffKeys ...string) (interface{}, error) {
+	values, err := set.client.SInter(set.ctx, append([]string{set.key}, diffKeys...)...).Result()
+	if err != nil {
+		return nil, err
 	}
+	return decodeSlice(values, container)
+}
 
-	return items.Elem().Interface()
+// SIsMember 判断 member 是否是集合的成员
+func (set *Set) SIsMember(member interface{}) (bool, error) {
+	data, err := encode(member)
+	if err != nil {
+		return false, err
+	}
+	return set.client.SIsMember(set.ctx, set.key, data).Result()
 }
 
 // SMembers 返回集合中的所有成员
-func (set *Set) SMembers(container interface{}) interface{} {
-	var values = set.client.SMembers(set.ctx, set.key).Val()
-	var typ = reflect.TypeOf(container)
-
-	newInstance := reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
-	items := reflect.New(newInstance.Type())
-	items.Elem().Set(newInstance)
-
-	var val = reflect.New(typ)
-	for i := range values {
-		err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val)
-		if err != nil {
-			set.logger.Warn(err)
-		}
-		items.Elem().Index(i).Set(val.Elem())
+func (set *Set) SMembers(container interface{}) (interface{}, error) {
+	values, err := set.client.SMembers(set.ctx, set.key).Result()
+	if err != nil {
+		return nil, err
 	}
-
-	return items.Elem().Interface()
+	return decodeSlice(values, container)
 }
 
 // SMove 将 member 元素从 source 集合移动到 destination 集合
 func (set *Set) SMove(destination string, member interface{}) error {
-	var buffer = new(bytes.Buffer)
-	_ = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(member))
-	return set.client.SMove(set.ctx, set.key, destination, buffer.Bytes()).Err()
+	data, err := encode(member)
+	if err != nil {
+		return err
+	}
+	return set.client.SMove(set.ctx, set.key, destination, data).Err()
 }
 
 // SPop 移除并返回集合中的一个或多个随机元素
-func (set *Set) SPop(container interface{}, n ...int64) interface{} {
+func (set *Set) SPop(container interface{}, n ...int64) (interface{}, error) {
 	var count int64 = 1
 	if len(n) == 1 {
-		count = n[1]
+		count = n[0]
 	}
 
-	values := set.client.SPopN(set.ctx, set.key, count).Val()
-	var typ = reflect.TypeOf(container)
-
-	newInstance := reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
-	items := reflect.New(newInstance.Type())
-	items.Elem().Set(newInstance)
-
-	var val = reflect.New(typ)
-	for i := range values {
-		err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val)
-		if err != nil {
-			set.logger.Warn(err)
-		}
-		items.Elem().Index(i).Set(val.Elem())
+	values, err := set.client.SPopN(set.ctx, set.key, count).Result()
+	if err != nil {
+		return nil, err
 	}
-
-	return items.Elem().Interface()
+	return decodeSlice(values, container)
 }
 
 // SRem 移除集合中一个或多个成员
-func (set *Set) SRem(members ...interface{}) int64 {
-	var val = make([]interface{}, len(members))
-	for i := range members {
-		var buffer = new(bytes.Buffer)
-		_ = gob.NewEncoder(buffer).EncodeValue(reflect.ValueOf(members[i]))
-		val[i] = buffer.Bytes()
+func (set *Set) SRem(members ...interface{}) (int64, error) {
+	val, err := encodeAll(members)
+	if err != nil {
+		return 0, err
 	}
-	return set.client.SRem(set.ctx, set.key, val...).Val()
+	return set.client.SRem(set.ctx, set.key, val...).Result()
 }
 
 // SUnion 返回所有给定集合的并集
-func (set *Set) SUnion(container interface{}, diffKeys ...string) interface{} {
-	var keys []string
-	keys = append(keys, set.key)
-	keys = append(keys, diffKeys...)
-
-	var values = set.client.SUnion(set.ctx, keys...).Val()
-	var typ = reflect.TypeOf(container)
-
-	newInstance := reflect.MakeSlice(reflect.SliceOf(typ), len(values), len(values))
-	items := reflect.New(newInstance.Type())
-	items.Elem().Set(newInstance)
-
-	var val = reflect.New(typ)
-	for i := range values {
-		err := gob.NewDecoder(bytes.NewReader([]byte(values[i]))).DecodeValue(val)
-		if err != nil {
-			set.logger.Warn(err)
-		}
-		items.Elem().Index(i).Set(val.Elem())
+func (set *Set) SUnion(container interface{}, diffKeys ...string) (interface{}, error) {
+	values, err := set.client.SUnion(set.ctx, append([]string{set.key}, diffKeys...)...).Result()
+	if err != nil {
+		return nil, err
 	}
-
-	return items.Elem().Interface()
+	return decodeSlice(values, container)
 }
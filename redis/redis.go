@@ -5,37 +5,194 @@ import (
 	"crypto/tls"
 	"github.com/go-redis/redis/v8"
 	"github.com/spf13/viper"
+	"io"
 	"net"
+	"reflect"
+	"sort"
 	"sync"
 	"time"
 )
 
-// Redis redis
+// DefaultConnection the name of the connection configured by the fields of the redis node itself
+const DefaultConnection = "default"
+
+// Redis redis, loaded from the redis node
+//  the fields of the node configure the default connection, the connections field configures the named connections,
+//  the other components can share them by name instead of opening their own pools, such as the store of the sessions and captcha nodes
+//  redis:
+//    addr: 127.0.0.1:6379
+//    connections:
+//      cache:
+//        redis_type: default
+//        addr: 127.0.0.1:6380
 type Redis struct {
-	logger ILogger
-	Config Config
-	r      redis.Cmdable
+	logger      ILogger
+	Config      Config
+	Connections map[string]Config
+	// CloseDelay how long the replaced and the removed clients are kept open after a configuration change,
+	//  so that the requests using them can finish, default DefaultCloseDelay
+	CloseDelay time.Duration
 
-	rw sync.RWMutex
+	configs   map[string]Config
+	clients   map[string]redis.Cmdable
+	retired   map[redis.Cmdable]*time.Timer
+	listeners []func(name string, client redis.Cmdable)
+	rw        sync.RWMutex
 }
 
+// DefaultCloseDelay the default CloseDelay, the same as the default shutdown duration of the http server
+const DefaultCloseDelay = 30 * time.Second
+
 func (s *Redis) Node() string {
 	return "redis"
 }
 
-// Instance get redis instance
+// Describe the redis node is unmarshalled into Config, and the connections field into Connections
+func (s *Redis) Describe() interface{} {
+	return &struct {
+		*Config     `mapstructure:",squash"`
+		Connections *map[string]Config `mapstructure:"connections"`
+	}{&s.Config, &s.Connections}
+}
+
+// Instance get the default connection
 func (s *Redis) Instance() redis.Cmdable {
+	return s.Connection(DefaultConnection)
+}
+
+// Connection get the named connection, nil if it isn't configured,
+//  the connection is replaced when the configuration changes, so get it every time instead of keeping it
+func (s *Redis) Connection(name string) redis.Cmdable {
 	s.rw.RLock()
 	defer s.rw.RUnlock()
-	return s.r
+	return s.clients[name]
 }
 
-// OnChange when the configuration file changes, a redis object will be re instantiated
-func (s *Redis) OnChange(viper *viper.Viper) {
+// Names the names of the configured connections
+func (s *Redis) Names() []string {
+	s.rw.RLock()
+	defer s.rw.RUnlock()
+	var names = make([]string, 0, len(s.clients))
+	for name := range s.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OnReload fn is called with the new client after a connection is created or replaced by a configuration change,
+//  and with nil after it's removed, the old client is closed CloseDelay after the listeners return
+func (s *Redis) OnReload(fn func(name string, client redis.Cmdable)) {
 	s.rw.Lock()
 	defer s.rw.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// OnChange when the configuration file changes, the changed connections are re instantiated, the unchanged ones are kept
+func (s *Redis) OnChange(viper *viper.Viper) {
+	s.rw.Lock()
 	_ = viper.Unmarshal(&s.Config)
-	s.r = s.Config.NewRedis()
+	s.Connections = nil
+	_ = viper.UnmarshalKey("connections", &s.Connections)
+
+	var configs = make(map[string]Config, len(s.Connections)+1)
+	if s.Config.RedisType != "" {
+		configs[DefaultConnection] = s.Config
+	}
+	for name, config := range s.Connections {
+		configs[name] = config
+	}
+
+	var clients = make(map[string]redis.Cmdable, len(configs))
+	var changed = make(map[string]redis.Cmdable)
+	var old []redis.Cmdable
+	for name, config := range configs {
+		if client, ok := s.clients[name]; ok && equal(s.configs[name], config) {
+			clients[name] = client
+			continue
+		}
+		clients[name] = config.NewRedis()
+		changed[name] = clients[name]
+		if client, ok := s.clients[name]; ok {
+			old = append(old, client)
+		}
+	}
+	for name, client := range s.clients {
+		if _, ok := configs[name]; !ok {
+			changed[name] = nil
+			old = append(old, client)
+		}
+	}
+	s.configs, s.clients = configs, clients
+	var listeners = s.listeners
+	s.rw.Unlock()
+
+	for name, client := range changed {
+		for _, listener := range listeners {
+			listener(name, client)
+		}
+	}
+	for _, client := range old {
+		s.retire(client)
+	}
+}
+
+// retire close the client after CloseDelay, the callers may still be using it
+func (s *Redis) retire(client redis.Cmdable) {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	var delay = s.CloseDelay
+	if delay <= 0 {
+		delay = DefaultCloseDelay
+	}
+	if s.retired == nil {
+		s.retired = make(map[redis.Cmdable]*time.Timer)
+	}
+	s.retired[client] = time.AfterFunc(delay, func() {
+		s.rw.Lock()
+		delete(s.retired, client)
+		s.rw.Unlock()
+		_ = closeClient(client)
+	})
+}
+
+// Close close all the connections, the retired ones are closed immediately
+func (s *Redis) Close() error {
+	s.rw.Lock()
+	defer s.rw.Unlock()
+	var err error
+	for _, client := range s.clients {
+		if e := closeClient(client); e != nil && err == nil {
+			err = e
+		}
+	}
+	for client, timer := range s.retired {
+		if timer.Stop() {
+			_ = closeClient(client)
+		}
+	}
+	s.clients, s.configs, s.retired = nil, nil, nil
+	return err
+}
+
+// equal whether the configs create the same client, the functions are compared by their pointers
+func equal(a, b Config) bool {
+	var fn = func(x, y interface{}) bool {
+		return reflect.ValueOf(x).Pointer() == reflect.ValueOf(y).Pointer()
+	}
+	if !fn(a.NewClient, b.NewClient) || !fn(a.Dialer, b.Dialer) || !fn(a.OnConnect, b.OnConnect) || !fn(a.ClusterSlots, b.ClusterSlots) {
+		return false
+	}
+	a.NewClient, a.Dialer, a.OnConnect, a.ClusterSlots = nil, nil, nil, nil
+	b.NewClient, b.Dialer, b.OnConnect, b.ClusterSlots = nil, nil, nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func closeClient(client redis.Cmdable) error {
+	if closer, ok := client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 // SetLogger set logger
@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/go-redis/redis/v8"
 	"github.com/kenretto/crane/configurator"
+	"github.com/spf13/viper"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -189,3 +191,213 @@ func TestRBinding_Set(t *testing.T) {
 	NewDefaultRBinding(context.Background(), r).Sets("set_4").SAdd(user{Username: "aa", Age: 1}, user{Username: "cc", Age: 3})
 	t.Log(NewDefaultRBinding(context.Background(), r).Sets("set_3").SDiff(user{}, "set_4"))
 }
+
+func TestRedis_Connections(t *testing.T) {
+	var r = &Redis{CloseDelay: time.Second}
+	var reloaded = make(map[string]redis.Cmdable)
+	r.OnReload(func(name string, client redis.Cmdable) {
+		reloaded[name] = client
+	})
+
+	var config = viper.New()
+	config.Set("redis_type", "default")
+	config.Set("addr", "127.0.0.1:6379")
+	config.Set("connections", map[string]interface{}{
+		"cache":    map[string]interface{}{"redis_type": "default", "addr": "127.0.0.1:6379", "db": 1},
+		"sessions": map[string]interface{}{"redis_type": "default", "addr": "127.0.0.1:6379", "db": 2},
+	})
+	r.OnChange(config)
+	if names := r.Names(); len(names) != 3 || names[0] != "cache" || names[1] != DefaultConnection || names[2] != "sessions" {
+		t.Fatalf("unexpected connections %v", names)
+	}
+	if r.Instance() != r.Connection(DefaultConnection) || len(reloaded) != 3 {
+		t.Fatalf("unexpected reloads %v", reloaded)
+	}
+	var cache, sessions = r.Connection("cache"), r.Connection("sessions")
+
+	reloaded = make(map[string]redis.Cmdable)
+	config.Set("connections", map[string]interface{}{
+		"cache": map[string]interface{}{"redis_type": "default", "addr": "127.0.0.1:6379", "db": 3},
+	})
+	r.OnChange(config)
+	if r.Connection("cache") == cache || reloaded["cache"] != r.Connection("cache") {
+		t.Error("the changed connection should be replaced")
+	}
+	if client, ok := reloaded["sessions"]; !ok || client != nil || r.Connection("sessions") != nil {
+		t.Error("the removed connection should be reported with nil")
+	}
+	if _, ok := reloaded[DefaultConnection]; ok {
+		t.Error("the unchanged connection should be kept")
+	}
+	// the removed connection is kept open for the requests using it, and closed after CloseDelay
+	if err := sessions.(*redis.Client).Ping(context.Background()).Err(); err == redis.ErrClosed {
+		t.Error("the removed connection should be kept open until the delay")
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if err := sessions.(*redis.Client).Ping(context.Background()).Err(); err != redis.ErrClosed {
+		t.Errorf("the removed connection should be closed, got %v", err)
+	}
+	_ = r.Close()
+}
+
+func TestLocker_Backoff(t *testing.T) {
+	var locker = new(Redis).Locker("a", "b", "c")
+	if locker.quorum() != 2 {
+		t.Errorf("unexpected quorum %d", locker.quorum())
+	}
+	for retry, max := range map[int]time.Duration{0: 50 * time.Millisecond, 1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 10: time.Second} {
+		if delay := locker.backoff(retry); delay < max/2 || delay > max {
+			t.Errorf("retry %d: unexpected delay %s", retry, delay)
+		}
+	}
+	if _, err := locker.TryLock(context.Background(), "job", time.Second); err == nil {
+		t.Error("the connections aren't configured")
+	}
+}
+
+func TestLocker_Lock(t *testing.T) {
+	var r = new(Redis)
+	var c, err = configurator.NewConfigurator("testdata/redis.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(r)
+	var ctx = context.Background()
+	if err = r.Instance().Ping(ctx).Err(); err != nil {
+		t.Skip("redis isn't available:", err)
+	}
+
+	lock, err := r.Lock(ctx, "test_lock", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.TryLock(ctx, "test_lock", time.Second); err != ErrNotObtained {
+		t.Errorf("expected ErrNotObtained, got %v", err)
+	}
+	// the lock is renewed while it's held
+	time.Sleep(500 * time.Millisecond)
+	select {
+	case <-lock.Lost():
+		t.Fatal("the lock shouldn't be lost")
+	default:
+	}
+	if _, err = r.TryLock(ctx, "test_lock", time.Second); err != ErrNotObtained {
+		t.Errorf("the renewed lock should be held, got %v", err)
+	}
+	if err = lock.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := r.TryLock(ctx, "test_lock", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer next.Release(ctx)
+	if next.Token() <= lock.Token() {
+		t.Errorf("the fencing token should increase, %d <= %d", next.Token(), lock.Token())
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err = r.Lock(waitCtx, "test_lock", time.Second); err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+}
+
+func TestHash_Fields(t *testing.T) {
+	type profile struct {
+		Name     string    `redis:"name"`
+		Age      uint8     `redis:"age"`
+		Score    float64   `redis:"score"`
+		Active   bool      `redis:"active"`
+		Avatar   []byte    `redis:"avatar"`
+		Roles    []string  `redis:"roles"`
+		Birthday time.Time `redis:"birthday"`
+		Secret   string    `redis:"-"`
+		Nickname string
+	}
+	var in = profile{Name: "medivh", Age: 25, Score: 1.5, Active: true, Avatar: []byte("a.png"), Roles: []string{"admin"},
+		Birthday: time.Date(1995, 1, 2, 0, 0, 0, 0, time.UTC), Secret: "secret", Nickname: "m"}
+	var fields = make(map[string]string)
+	var v = reflect.ValueOf(in)
+	for i := 0; i < v.NumField(); i++ {
+		if name := fieldName(v.Type().Field(i)); name != "" {
+			value, err := formatField(v.Field(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fields[name] = value
+		}
+	}
+	if _, ok := fields["Secret"]; ok || fields["age"] != "25" || fields["roles"] != `["admin"]` || fields["Nickname"] != "m" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	var out profile
+	v = reflect.ValueOf(&out).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if value, ok := fields[fieldName(v.Type().Field(i))]; ok {
+			if err := parseField(value, v.Field(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	in.Secret = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unexpected struct %+v", out)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	var r = new(Redis)
+	var c, err = configurator.NewConfigurator("testdata/redis.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(r)
+	var ctx = context.Background()
+	if err = r.Instance().Ping(ctx).Err(); err != nil {
+		t.Skip("redis isn't available:", err)
+	}
+	var binding = NewDefaultRBinding(ctx, r)
+	_ = binding.Del("test_hash", "test_zset", "test_stream", "test_blist")
+
+	var u = user{Username: "medivh", Age: 25}
+	if err = binding.Hash("test_hash").HSetStruct(u); err != nil {
+		t.Fatal(err)
+	}
+	var out user
+	if err = binding.Hash("test_hash").HGetStruct(&out); err != nil || out.Username != u.Username || out.Age != u.Age {
+		t.Errorf("unexpected hash %+v %v", out, err)
+	}
+
+	var ranking = NewZSet[user](binding, "test_zset")
+	if err = ranking.ZAdd(Z[user]{Score: 10, Member: user{Username: "a"}}, Z[user]{Score: 20, Member: user{Username: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if rank, err := ranking.ZRevRank(user{Username: "b"}); err != nil || rank != 0 {
+		t.Errorf("unexpected rank %d %v", rank, err)
+	}
+	if members, err := ranking.ZRangeByScore("15", "+inf", 0, 0); err != nil || len(members) != 1 || members[0].Member.Username != "b" {
+		t.Errorf("unexpected members %v %v", members, err)
+	}
+
+	var stream = NewStream[user](binding, "test_stream")
+	if err = stream.CreateGroup("test", "0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Add(u, 100); err != nil {
+		t.Fatal(err)
+	}
+	messages, err := stream.ReadGroup("test", "consumer", 10, time.Second)
+	if err != nil || len(messages) != 1 || messages[0].Value.Username != u.Username {
+		t.Fatalf("unexpected messages %v %v", messages, err)
+	}
+	if n, err := stream.Ack("test", messages[0].ID); err != nil || n != 1 {
+		t.Errorf("unexpected ack %d %v", n, err)
+	}
+
+	if err = binding.List("test_blist").BLPop(100*time.Millisecond, &out); err != redis.Nil {
+		t.Errorf("expected redis.Nil after the timeout, got %v", err)
+	}
+}
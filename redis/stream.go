@@ -0,0 +1,142 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"strings"
+	"time"
+)
+
+// streamField 消息的值以 json 保存在这个字段中
+const streamField = "data"
+
+// Message 流中的一条消息
+type Message[T any] struct {
+	ID    string
+	Value T
+}
+
+// Stream redis 流, 支持消费者组, 消息的值以 json 保存
+//  var orders = redis.NewStream[Order](redis.NewDefaultRBinding(ctx, crane.Redis()), "orders")
+//  _ = orders.CreateGroup("billing", "0")
+//  messages, err := orders.ReadGroup("billing", hostname, 10, 5*time.Second)
+//  // 处理完成后确认
+//  _, err = orders.Ack("billing", ids...)
+type Stream[T any] struct {
+	client redis.Cmdable
+	key    string
+	ctx    context.Context
+}
+
+// NewStream 返回一个指定 key 的流操作
+func NewStream[T any](r *RBinding, key string) *Stream[T] {
+	return &Stream[T]{client: r.client.Instance(), key: key, ctx: r.ctx}
+}
+
+func (s *Stream[T]) decode(values []redis.XMessage) ([]Message[T], error) {
+	var messages = make([]Message[T], len(values))
+	for i := range values {
+		messages[i].ID = values[i].ID
+		data, _ := values[i].Values[streamField].(string)
+		if err := json.Unmarshal([]byte(data), &messages[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// Add 添加一条消息, 返回消息的 id, maxLen 大于 0 时流的长度大约保持在 maxLen
+func (s *Stream[T]) Add(val T, maxLen int64) (string, error) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return "", err
+	}
+	return s.client.XAdd(s.ctx, &redis.XAddArgs{
+		Stream:       s.key,
+		MaxLenApprox: maxLen,
+		Values:       map[string]interface{}{streamField: string(data)},
+	}).Result()
+}
+
+// Len 消息的数量
+func (s *Stream[T]) Len() (int64, error) {
+	return s.client.XLen(s.ctx, s.key).Result()
+}
+
+// Range 返回 id 在 start 和 stop 之间的消息, "-" 和 "+" 表示最小和最大的 id
+func (s *Stream[T]) Range(start, stop string) ([]Message[T], error) {
+	values, err := s.client.XRange(s.ctx, s.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(values)
+}
+
+// Del 删除消息, 返回删除的数量
+func (s *Stream[T]) Del(ids ...string) (int64, error) {
+	return s.client.XDel(s.ctx, s.key, ids...).Result()
+}
+
+// CreateGroup 创建消费者组, start 是组开始消费的 id, "0" 表示从头开始, "$" 表示只消费新的消息,
+//  流不存在时会创建, 组已经存在时不返回错误
+func (s *Stream[T]) CreateGroup(group, start string) error {
+	err := s.client.XGroupCreateMkStream(s.ctx, s.key, group, start).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// ReadGroup 以组中的 consumer 读取最多 count 条新消息, 没有消息时最多等待 block, 0 表示一直等待, 负数表示不等待, 超时返回空的消息列表,
+//  读取的消息在 Ack 之前处于 pending 状态, 可以被 Claim 给其他的消费者
+func (s *Stream[T]) ReadGroup(group, consumer string, count int64, block time.Duration) ([]Message[T], error) {
+	return s.readGroup(group, consumer, ">", count, block)
+}
+
+// ReadPending 读取 consumer 已经读取但是还没有 Ack 的消息, 用于消费者重启后继续处理
+func (s *Stream[T]) ReadPending(group, consumer string, count int64) ([]Message[T], error) {
+	return s.readGroup(group, consumer, "0", count, -1)
+}
+
+func (s *Stream[T]) readGroup(group, consumer, id string, count int64, block time.Duration) ([]Message[T], error) {
+	streams, err := s.client.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{s.key, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil || len(streams) == 0 {
+		return nil, err
+	}
+	return s.decode(streams[0].Messages)
+}
+
+// Ack 确认消息已经处理, 返回确认的数量
+func (s *Stream[T]) Ack(group string, ids ...string) (int64, error) {
+	return s.client.XAck(s.ctx, s.key, group, ids...).Result()
+}
+
+// Pending 组中 pending 消息的概况
+func (s *Stream[T]) Pending(group string) (*redis.XPending, error) {
+	return s.client.XPending(s.ctx, s.key, group).Result()
+}
+
+// Claim 将空闲超过 minIdle 的 pending 消息转给 consumer, 用于接管崩溃的消费者的消息
+func (s *Stream[T]) Claim(group, consumer string, minIdle time.Duration, ids ...string) ([]Message[T], error) {
+	values, err := s.client.XClaim(s.ctx, &redis.XClaimArgs{
+		Stream:   s.key,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(values)
+}
@@ -0,0 +1,194 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+)
+
+// Z 有序集合的成员和分数
+type Z[T any] struct {
+	Score  float64
+	Member T
+}
+
+// ZSet redis 有序集合, 成员以 json 保存, 相同的值总是得到相同的成员, 所以可以直接用值查询分数和排名
+type ZSet[T any] struct {
+	client redis.Cmdable
+	key    string
+	ctx    context.Context
+}
+
+// NewZSet 返回一个指定 key 的有序集合操作
+//  var ranking = redis.NewZSet[Player](redis.NewDefaultRBinding(ctx, crane.Redis()), "ranking")
+func NewZSet[T any](r *RBinding, key string) *ZSet[T] {
+	return &ZSet[T]{client: r.client.Instance(), key: key, ctx: r.ctx}
+}
+
+func (z *ZSet[T]) encode(member T) (string, error) {
+	data, err := json.Marshal(member)
+	return string(data), err
+}
+
+func (z *ZSet[T]) decode(values []string) ([]T, error) {
+	var members = make([]T, len(values))
+	for i := range values {
+		if err := json.Unmarshal([]byte(values[i]), &members[i]); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+func (z *ZSet[T]) decodeZ(values []redis.Z) ([]Z[T], error) {
+	var members = make([]Z[T], len(values))
+	for i := range values {
+		members[i].Score = values[i].Score
+		if err := json.Unmarshal([]byte(values[i].Member.(string)), &members[i].Member); err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+// ZAdd 添加成员, 已存在的成员更新分数
+func (z *ZSet[T]) ZAdd(members ...Z[T]) error {
+	var values = make([]*redis.Z, len(members))
+	for i := range members {
+		member, err := z.encode(members[i].Member)
+		if err != nil {
+			return err
+		}
+		values[i] = &redis.Z{Score: members[i].Score, Member: member}
+	}
+	return z.client.ZAdd(z.ctx, z.key, values...).Err()
+}
+
+// ZIncrBy 将成员的分数增加 incr, 返回增加后的分数
+func (z *ZSet[T]) ZIncrBy(member T, incr float64) (float64, error) {
+	m, err := z.encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return z.client.ZIncrBy(z.ctx, z.key, incr, m).Result()
+}
+
+// ZScore 成员的分数, 成员不存在时返回 redis.Nil
+func (z *ZSet[T]) ZScore(member T) (float64, error) {
+	m, err := z.encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return z.client.ZScore(z.ctx, z.key, m).Result()
+}
+
+// ZRank 成员按分数从小到大的排名, 从 0 开始, 成员不存在时返回 redis.Nil
+func (z *ZSet[T]) ZRank(member T) (int64, error) {
+	m, err := z.encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return z.client.ZRank(z.ctx, z.key, m).Result()
+}
+
+// ZRevRank 成员按分数从大到小的排名, 从 0 开始, 成员不存在时返回 redis.Nil
+func (z *ZSet[T]) ZRevRank(member T) (int64, error) {
+	m, err := z.encode(member)
+	if err != nil {
+		return 0, err
+	}
+	return z.client.ZRevRank(z.ctx, z.key, m).Result()
+}
+
+// ZRem 移除成员, 返回移除的数量
+func (z *ZSet[T]) ZRem(members ...T) (int64, error) {
+	var values = make([]interface{}, len(members))
+	for i := range members {
+		m, err := z.encode(members[i])
+		if err != nil {
+			return 0, err
+		}
+		values[i] = m
+	}
+	return z.client.ZRem(z.ctx, z.key, values...).Result()
+}
+
+// ZCard 成员的数量
+func (z *ZSet[T]) ZCard() (int64, error) {
+	return z.client.ZCard(z.ctx, z.key).Result()
+}
+
+// ZCount 分数在 min 和 max 之间的成员数量, min 和 max 的格式同 redis, 比如 "-inf", "(1", "+inf"
+func (z *ZSet[T]) ZCount(min, max string) (int64, error) {
+	return z.client.ZCount(z.ctx, z.key, min, max).Result()
+}
+
+// ZRange 按分数从小到大返回排名在 start 和 stop 之间的成员
+func (z *ZSet[T]) ZRange(start, stop int64) ([]T, error) {
+	values, err := z.client.ZRange(z.ctx, z.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decode(values)
+}
+
+// ZRevRange 按分数从大到小返回排名在 start 和 stop 之间的成员
+func (z *ZSet[T]) ZRevRange(start, stop int64) ([]T, error) {
+	values, err := z.client.ZRevRange(z.ctx, z.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decode(values)
+}
+
+// ZRangeWithScores 同 ZRange, 同时返回分数
+func (z *ZSet[T]) ZRangeWithScores(start, stop int64) ([]Z[T], error) {
+	values, err := z.client.ZRangeWithScores(z.ctx, z.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decodeZ(values)
+}
+
+// ZRevRangeWithScores 同 ZRevRange, 同时返回分数
+func (z *ZSet[T]) ZRevRangeWithScores(start, stop int64) ([]Z[T], error) {
+	values, err := z.client.ZRevRangeWithScores(z.ctx, z.key, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decodeZ(values)
+}
+
+// ZRangeByScore 按分数从小到大返回分数在 min 和 max 之间的成员, 从第 offset 个开始最多返回 count 个, count 为 0 时不限制
+func (z *ZSet[T]) ZRangeByScore(min, max string, offset, count int64) ([]Z[T], error) {
+	values, err := z.client.ZRangeByScoreWithScores(z.ctx, z.key, z.by(min, max, offset, count)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decodeZ(values)
+}
+
+// ZRevRangeByScore 同 ZRangeByScore, 按分数从大到小
+func (z *ZSet[T]) ZRevRangeByScore(min, max string, offset, count int64) ([]Z[T], error) {
+	values, err := z.client.ZRevRangeByScoreWithScores(z.ctx, z.key, z.by(min, max, offset, count)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return z.decodeZ(values)
+}
+
+func (z *ZSet[T]) by(min, max string, offset, count int64) *redis.ZRangeBy {
+	if count == 0 {
+		count = -1
+	}
+	return &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: count}
+}
+
+// ZRemRangeByScore 移除分数在 min 和 max 之间的成员, 返回移除的数量
+func (z *ZSet[T]) ZRemRangeByScore(min, max string) (int64, error) {
+	return z.client.ZRemRangeByScore(z.ctx, z.key, min, max).Result()
+}
+
+// ZRemRangeByRank 移除排名在 start 和 stop 之间的成员, 返回移除的数量
+func (z *ZSet[T]) ZRemRangeByRank(start, stop int64) (int64, error) {
+	return z.client.ZRemRangeByRank(z.ctx, z.key, start, stop).Result()
+}
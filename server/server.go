@@ -7,7 +7,6 @@ import (
 	"github.com/gin-gonic/gin/binding"
 	"github.com/kenretto/crane/util/stack"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"net"
 	"net/http"
@@ -75,7 +74,8 @@ func (httpServer *HTTPServer) Router() *gin.Engine {
 	return httpServer.handler.router
 }
 
-func (httpServer *HTTPServer) shutdownDuration() time.Duration {
+// ShutdownDuration the maximum duration to wait for the service to shut down gracefully, default 30s
+func (httpServer *HTTPServer) ShutdownDuration() time.Duration {
 	duration, err := time.ParseDuration(httpServer.ShutdownWaitDuration)
 	if err != nil {
 		return time.Second * 30
@@ -95,7 +95,7 @@ func (httpServer *HTTPServer) close() {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), httpServer.shutdownDuration())
+	ctx, cancel := context.WithTimeout(context.Background(), httpServer.ShutdownDuration())
 	_ = httpServer.server.Shutdown(ctx)
 	httpServer.logger.Info("service stop")
 	cancel()
@@ -207,9 +207,9 @@ func (httpServer *HTTPServer) GINRecovery(ctx *gin.Context) {
 
 			if gin.Mode() != gin.ReleaseMode {
 				httpServer.logger.Error(string(httpRequest))
-				var errors = make([]logrus.Fields, 0)
+				var errors = make([]Fields, 0)
 				for i := 0; i < len(printStack); i++ {
-					errors = append(errors, logrus.Fields{
+					errors = append(errors, Fields{
 						"func":   printStack[i]["func"],
 						"source": printStack[i]["source"],
 						"file":   fmt.Sprintf("%s:%d", printStack[i]["file"], printStack[i]["line"]),
@@ -0,0 +1,214 @@
+package sessions
+
+import (
+	"context"
+	"encoding/base32"
+	"errors"
+	"github.com/gorilla/securecookie"
+	gsessions "github.com/gorilla/sessions"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/kenretto/sessions"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Record the session stored in the database
+type Record struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	Data      []byte
+	ExpiresAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+func (Record) TableName() string {
+	return "sessions"
+}
+
+// IndexRecord a session bound to a user, the index of the database driver
+type IndexRecord struct {
+	User      string `gorm:"column:user_id;primaryKey;size:128"`
+	ID        string `gorm:"primaryKey;size:64"`
+	Info      []byte
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (IndexRecord) TableName() string {
+	return "session_index"
+}
+
+// Migrate create the tables of the database store and its index
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Record{}, &IndexRecord{})
+}
+
+// DatabaseStore keeps the values in the database and only the signed session id in the cookie,
+//  the expired records are deleted by Sweep, see Sessions.SweepJob
+type DatabaseStore struct {
+	db      func() *gorm.DB
+	codecs  []securecookie.Codec
+	options *gsessions.Options
+}
+
+// NewDatabaseStore the key pairs sign and encrypt the session id, see KeyPairs
+func NewDatabaseStore(db func() *gorm.DB, keyPairs ...[]byte) *DatabaseStore {
+	return &DatabaseStore{
+		db:      db,
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		options: &gsessions.Options{Path: "/", MaxAge: 86400},
+	}
+}
+
+func (store *DatabaseStore) Options(options sessions.Options) {
+	store.options = options.ToGorillaOptions()
+}
+
+// Get the session of the request, it's cached in the registry of the request
+func (store *DatabaseStore) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(store, name)
+}
+
+// New load the session by the id of the cookie, a new session is returned if the cookie is invalid or the session has expired
+func (store *DatabaseStore) New(r *http.Request, name string) (*gsessions.Session, error) {
+	var session = gsessions.NewSession(store, name)
+	var options = *store.options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err = securecookie.DecodeMulti(name, cookie.Value, &session.ID, store.codecs...); err != nil {
+		return session, err
+	}
+
+	var record Record
+	err = store.db().WithContext(r.Context()).Where("id = ? AND expires_at > ?", session.ID, time.Now()).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err = (securecookie.GobEncoder{}).Deserialize(record.Data, &session.Values); err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save save the session, a negative MaxAge deletes it
+func (store *DatabaseStore) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	var db = store.db().WithContext(r.Context())
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := db.Where("id = ?", session.ID).Delete(&Record{}).Error; err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+	}
+	data, err := (securecookie.GobEncoder{}).Serialize(session.Values)
+	if err != nil {
+		return err
+	}
+	// a session cookie without Max-Age is kept for a day in the database
+	var age = time.Duration(session.Options.MaxAge) * time.Second
+	if age == 0 {
+		age = 24 * time.Hour
+	}
+	var record = Record{ID: session.ID, Data: data, ExpiresAt: time.Now().Add(age), UpdatedAt: time.Now()}
+	if err = db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, store.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Sweep delete the expired sessions and the expired entries of the index, returns the number of the deleted sessions
+func (store *DatabaseStore) Sweep(ctx context.Context) (int64, error) {
+	var now = time.Now()
+	var result = store.db().WithContext(ctx).Where("expires_at <= ?", now).Delete(&Record{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, store.db().WithContext(ctx).Where("expires_at <= ?", now).Delete(&IndexRecord{}).Error
+}
+
+// DatabaseIndex keeps the sessions of the users in the database, so that the bindings are shared by the instances like the sessions
+type DatabaseIndex struct {
+	db func() *gorm.DB
+}
+
+func NewDatabaseIndex(db func() *gorm.DB) *DatabaseIndex {
+	return &DatabaseIndex{db: db}
+}
+
+// Add the expiry of all the sessions of the user is extended, as RedisIndex does with the hash of the user
+func (index *DatabaseIndex) Add(ctx context.Context, user string, info SessionInfo, ttl time.Duration) error {
+	data, err := jsoniter.Marshal(info)
+	if err != nil {
+		return err
+	}
+	// an index without ttl is kept for a day, as the sessions without Max-Age
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	var expires = time.Now().Add(ttl)
+	return index.db().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var record = IndexRecord{User: user, ID: info.ID, Info: data, ExpiresAt: expires}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Model(&IndexRecord{}).Where("user_id = ?", user).Update("expires_at", expires).Error
+	})
+}
+
+func (index *DatabaseIndex) Get(ctx context.Context, user, id string) (SessionInfo, bool, error) {
+	var info SessionInfo
+	var record IndexRecord
+	err := index.db().WithContext(ctx).Where("user_id = ? AND id = ? AND expires_at > ?", user, id, time.Now()).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return info, false, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+	return info, jsoniter.Unmarshal(record.Info, &info) == nil, nil
+}
+
+func (index *DatabaseIndex) List(ctx context.Context, user string) ([]SessionInfo, error) {
+	var records []IndexRecord
+	if err := index.db().WithContext(ctx).Where("user_id = ? AND expires_at > ?", user, time.Now()).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	var infos = make([]SessionInfo, 0, len(records))
+	for _, record := range records {
+		var info SessionInfo
+		if jsoniter.Unmarshal(record.Info, &info) == nil {
+			infos = append(infos, info)
+		}
+	}
+	return sortSessions(infos), nil
+}
+
+func (index *DatabaseIndex) Remove(ctx context.Context, user string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return index.db().WithContext(ctx).Where("user_id = ? AND id IN ?", user, ids).Delete(&IndexRecord{}).Error
+}
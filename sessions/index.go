@@ -0,0 +1,150 @@
+package sessions
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SessionInfo the metadata of a session bound to a user
+type SessionInfo struct {
+	ID        string    `json:"id"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Index the index of the sessions of the users, a bound session is revoked by removing it from the index
+type Index interface {
+	// Add add or replace the session of the user, ttl is how long the user's index is kept without changes
+	Add(ctx context.Context, user string, info SessionInfo, ttl time.Duration) error
+	// Get the session of the user, false if it isn't in the index
+	Get(ctx context.Context, user, id string) (SessionInfo, bool, error)
+	// List the sessions of the user, ordered by the creation time
+	List(ctx context.Context, user string) ([]SessionInfo, error)
+	// Remove remove the sessions of the user
+	Remove(ctx context.Context, user string, ids ...string) error
+}
+
+func sortSessions(infos []SessionInfo) []SessionInfo {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+	})
+	return infos
+}
+
+// RedisIndex keeps the sessions of each user in a hash
+type RedisIndex struct {
+	conn   redis.Cmdable
+	prefix string
+}
+
+// NewRedisIndex prefix is the prefix of the hash keys, default session_index_
+func NewRedisIndex(conn redis.Cmdable, prefix string) *RedisIndex {
+	if prefix == "" {
+		prefix = "session_index_"
+	}
+	return &RedisIndex{conn: conn, prefix: prefix}
+}
+
+func (index *RedisIndex) Add(ctx context.Context, user string, info SessionInfo, ttl time.Duration) error {
+	data, err := jsoniter.Marshal(info)
+	if err != nil {
+		return err
+	}
+	var key = index.prefix + user
+	if err = index.conn.HSet(ctx, key, info.ID, data).Err(); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		return index.conn.Expire(ctx, key, ttl).Err()
+	}
+	return nil
+}
+
+func (index *RedisIndex) Get(ctx context.Context, user, id string) (SessionInfo, bool, error) {
+	var info SessionInfo
+	value, err := index.conn.HGet(ctx, index.prefix+user, id).Result()
+	if err == redis.Nil {
+		return info, false, nil
+	}
+	if err != nil {
+		return info, false, err
+	}
+	return info, jsoniter.UnmarshalFromString(value, &info) == nil, nil
+}
+
+func (index *RedisIndex) List(ctx context.Context, user string) ([]SessionInfo, error) {
+	values, err := index.conn.HGetAll(ctx, index.prefix+user).Result()
+	if err != nil {
+		return nil, err
+	}
+	var infos = make([]SessionInfo, 0, len(values))
+	for _, value := range values {
+		var info SessionInfo
+		if jsoniter.UnmarshalFromString(value, &info) == nil {
+			infos = append(infos, info)
+		}
+	}
+	return sortSessions(infos), nil
+}
+
+func (index *RedisIndex) Remove(ctx context.Context, user string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return index.conn.HDel(ctx, index.prefix+user, ids...).Err()
+}
+
+// MemoryIndex the index of a single process, used with the memory store
+type MemoryIndex struct {
+	mu    sync.Mutex
+	users map[string]map[string]SessionInfo
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{users: make(map[string]map[string]SessionInfo)}
+}
+
+func (index *MemoryIndex) Add(_ context.Context, user string, info SessionInfo, _ time.Duration) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if index.users[user] == nil {
+		index.users[user] = make(map[string]SessionInfo)
+	}
+	index.users[user][info.ID] = info
+	return nil
+}
+
+func (index *MemoryIndex) Get(_ context.Context, user, id string) (SessionInfo, bool, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	info, ok := index.users[user][id]
+	return info, ok, nil
+}
+
+func (index *MemoryIndex) List(_ context.Context, user string) ([]SessionInfo, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	var infos = make([]SessionInfo, 0, len(index.users[user]))
+	for _, info := range index.users[user] {
+		infos = append(infos, info)
+	}
+	return sortSessions(infos), nil
+}
+
+func (index *MemoryIndex) Remove(_ context.Context, user string, ids ...string) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, id := range ids {
+		delete(index.users[user], id)
+	}
+	if len(index.users[user]) == 0 {
+		delete(index.users, user)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
+	"github.com/kenretto/sessions"
+	"time"
+)
+
+// the keys of the values kept by the session management
+const (
+	userKey    = "_crane_user"
+	sidKey     = "_crane_sid"
+	createdKey = "_crane_created"
+	seenKey    = "_crane_seen"
+)
+
+func parseDuration(s string) time.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// SetIndex use the index instead of the one chosen by the driver, the redis and the database drivers keep the index with the sessions,
+//  the other drivers use a memory index, the cookie driver needs a shared index such as RedisIndex to revoke the sessions
+//  and limit MaxSessions across the instances and the restarts
+func (s *Sessions) SetIndex(index Index) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index, s.customIndex = index, true
+}
+
+// timeouts the absolute timeout, the idle timeout and how long the index is kept
+func (s *Sessions) timeouts() (absolute, idle, ttl time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	absolute, idle, ttl = parseDuration(s.AbsoluteTimeout), parseDuration(s.IdleTimeout), parseDuration(s.MaxAge)
+	if absolute > ttl {
+		ttl = absolute
+	}
+	return
+}
+
+func (s *Sessions) getIndex() Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+// isLocalIndex whether the index only knows the sessions bound in this process, see SetIndex
+func (s *Sessions) isLocalIndex() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.localIndex
+}
+
+// expired whether the session created and last seen at the times is expired
+func (s *Sessions) expired(created, seen time.Time, now time.Time) bool {
+	absolute, idle, _ := s.timeouts()
+	return absolute > 0 && now.Sub(created) > absolute || idle > 0 && now.Sub(seen) > idle
+}
+
+// touchInterval the last seen time is saved at most once per interval, so that not every request writes the store
+func touchInterval(idle time.Duration) time.Duration {
+	if idle > 0 && idle/2 < time.Minute {
+		return idle / 2
+	}
+	return time.Minute
+}
+
+func underlying(sess sessions.Session) *gsessions.Session {
+	if u, ok := sess.(interface{ Session() *gsessions.Session }); ok {
+		return u.Session()
+	}
+	return nil
+}
+
+// check clear the expired and the revoked sessions, and update the last seen time
+func (s *Sessions) check(c *gin.Context) {
+	var sess = sessions.Default(c)
+	var session = underlying(sess)
+	if session == nil || len(session.Values) == 0 {
+		return
+	}
+	var now = time.Now()
+	var created, _ = session.Values[createdKey].(int64)
+	var seen, _ = session.Values[seenKey].(int64)
+	var user, _ = session.Values[userKey].(string)
+	var sid, _ = session.Values[sidKey].(string)
+	if created == 0 {
+		created, seen = now.UnixMilli(), now.UnixMilli()
+		sess.Set(createdKey, created)
+		sess.Set(seenKey, seen)
+	}
+
+	var index = s.getIndex()
+	var expired = s.expired(time.UnixMilli(created), time.UnixMilli(seen), now)
+	var info SessionInfo
+	if user != "" && !expired {
+		var ok bool
+		var err error
+		if info, ok, err = index.Get(c.Request.Context(), user, sid); err != nil {
+			// keep the session when the index is unavailable
+			_ = c.Error(err)
+			return
+		}
+		if !ok && s.isLocalIndex() {
+			// bound in another process, it's added to the local index when it's touched
+			ok, info = true, SessionInfo{ID: sid, CreatedAt: time.UnixMilli(created)}
+		}
+		expired = !ok
+	}
+	if expired {
+		if user != "" {
+			_ = index.Remove(c.Request.Context(), user, sid)
+		}
+		sess.Clear()
+		return
+	}
+
+	_, idle, ttl := s.timeouts()
+	if now.Sub(time.UnixMilli(seen)) < touchInterval(idle) {
+		return
+	}
+	sess.Set(seenKey, now.UnixMilli())
+	if user != "" {
+		info.LastSeen, info.IP, info.UserAgent = now, c.ClientIP(), c.Request.UserAgent()
+		if err := index.Add(c.Request.Context(), user, info, ttl); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	var b = make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Bind bind the session to the user after login, the session id is regenerated,
+//  the oldest sessions of the user are revoked if the user has more than max_sessions sessions
+func (s *Sessions) Bind(c *gin.Context, user string) error {
+	var ctx = c.Request.Context()
+	var index = s.getIndex()
+	var sess = sessions.Default(c)
+	if previous, _ := sess.Get(userKey).(string); previous != "" {
+		sid, _ := sess.Get(sidKey).(string)
+		if err := index.Remove(ctx, previous, sid); err != nil {
+			return err
+		}
+	}
+	if err := Regenerate(c); err != nil {
+		return err
+	}
+
+	sid, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	var now = time.Now()
+	var info = SessionInfo{ID: sid, IP: c.ClientIP(), UserAgent: c.Request.UserAgent(), CreatedAt: now, LastSeen: now}
+	_, _, ttl := s.timeouts()
+	if err = index.Add(ctx, user, info, ttl); err != nil {
+		return err
+	}
+	sess.Set(userKey, user)
+	sess.Set(sidKey, sid)
+	sess.Set(createdKey, now.UnixMilli())
+	sess.Set(seenKey, now.UnixMilli())
+
+	s.mu.RLock()
+	var max = s.MaxSessions
+	s.mu.RUnlock()
+	if max > 0 {
+		infos, err := s.List(ctx, user)
+		if err != nil {
+			return err
+		}
+		var evict []string
+		for i := 0; i < len(infos)-max; i++ {
+			if infos[i].ID != sid {
+				evict = append(evict, infos[i].ID)
+			}
+		}
+		if err = index.Remove(ctx, user, evict...); err != nil {
+			return err
+		}
+	}
+	return changed(c, sess)
+}
+
+// Unbind remove the binding of the session after logout, the other values are kept
+func (s *Sessions) Unbind(c *gin.Context) error {
+	var sess = sessions.Default(c)
+	user, sid := Current(c)
+	if user != "" {
+		if err := s.getIndex().Remove(c.Request.Context(), user, sid); err != nil {
+			return err
+		}
+	}
+	sess.Delete(userKey)
+	sess.Delete(sidKey)
+	return changed(c, sess)
+}
+
+// Current the user and the id of the current session, empty if the session isn't bound
+func Current(c *gin.Context) (user, id string) {
+	var sess = sessions.Default(c)
+	user, _ = sess.Get(userKey).(string)
+	id, _ = sess.Get(sidKey).(string)
+	return
+}
+
+// List the active sessions of the user, ordered by the creation time, the expired ones are removed
+func (s *Sessions) List(ctx context.Context, user string) ([]SessionInfo, error) {
+	var index = s.getIndex()
+	infos, err := index.List(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	var now = time.Now()
+	var active = infos[:0]
+	var expired []string
+	for _, info := range infos {
+		if s.expired(info.CreatedAt, info.LastSeen, now) {
+			expired = append(expired, info.ID)
+			continue
+		}
+		active = append(active, info)
+	}
+	return active, index.Remove(ctx, user, expired...)
+}
+
+// Revoke revoke the sessions of the user, the values of the revoked sessions are cleared on their next request
+func (s *Sessions) Revoke(ctx context.Context, user string, ids ...string) error {
+	return s.getIndex().Remove(ctx, user, ids...)
+}
+
+// RevokeAll revoke all the sessions of the user except the given ones, such as the current session
+func (s *Sessions) RevokeAll(ctx context.Context, user string, except ...string) error {
+	var index = s.getIndex()
+	infos, err := index.List(ctx, user)
+	if err != nil {
+		return err
+	}
+	var ids = make([]string, 0, len(infos))
+	for _, info := range infos {
+		var keep bool
+		for _, id := range except {
+			keep = keep || id == info.ID
+		}
+		if !keep {
+			ids = append(ids, info.ID)
+		}
+	}
+	return index.Remove(ctx, user, ids...)
+}
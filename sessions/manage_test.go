@@ -0,0 +1,140 @@
+package sessions
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newManaged(settings map[string]interface{}) (*Sessions, *gin.Engine) {
+	return newManagedWith(settings, nil)
+}
+
+// newManagedWith setup is called before the config is loaded
+func newManagedWith(settings map[string]interface{}, setup func(s *Sessions)) (*Sessions, *gin.Engine) {
+	var s = new(Sessions)
+	if setup != nil {
+		setup(s)
+	}
+	var config = viper.New()
+	config.Set("key", "secret")
+	config.Set("name", "session")
+	config.Set("max_age", "1h")
+	for k, v := range settings {
+		config.Set(k, v)
+	}
+	s.OnChange(config)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	s.Inject(router)
+	router.GET("/login", func(c *gin.Context) {
+		_ = Set(c, "name", c.Query("user"))
+		if err := s.Bind(c, c.Query("user")); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/whoami", func(c *gin.Context) {
+		var user, _ = Current(c)
+		var name, _ = Get[string](c, "name")
+		c.String(http.StatusOK, user+":"+name)
+	})
+	router.GET("/logout", func(c *gin.Context) {
+		_ = s.Unbind(c)
+	})
+	return s, router
+}
+
+// client keeps the latest session cookie
+type client struct {
+	router *gin.Engine
+	cookie *http.Cookie
+}
+
+func (cli *client) get(path string) string {
+	var req = httptest.NewRequest(http.MethodGet, path, nil)
+	if cli.cookie != nil {
+		req.AddCookie(cli.cookie)
+	}
+	var recorder = httptest.NewRecorder()
+	cli.router.ServeHTTP(recorder, req)
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.MaxAge >= 0 {
+			cli.cookie = cookie
+		}
+	}
+	return recorder.Body.String()
+}
+
+func TestSessions_Bind(t *testing.T) {
+	var s, router = newManaged(map[string]interface{}{"max_sessions": 2})
+	var ctx = context.Background()
+	var clients = []*client{{router: router}, {router: router}, {router: router}}
+	for _, cli := range clients {
+		cli.get("/login?user=alice")
+		time.Sleep(time.Millisecond)
+	}
+
+	infos, err := s.List(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(infos))
+	}
+	// the oldest session is evicted
+	if who := clients[0].get("/whoami"); who != ":" {
+		t.Errorf("the oldest session should be cleared, got %q", who)
+	}
+	if who := clients[1].get("/whoami"); who != "alice:alice" {
+		t.Errorf("unexpected session %q", who)
+	}
+
+	if err = s.RevokeAll(ctx, "alice", infos[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if who := clients[1].get("/whoami"); who != ":" {
+		t.Errorf("the revoked session should be cleared, got %q", who)
+	}
+	if who := clients[2].get("/whoami"); who != "alice:alice" {
+		t.Errorf("the kept session should be active, got %q", who)
+	}
+
+	clients[2].get("/logout")
+	if who := clients[2].get("/whoami"); who != ":alice" {
+		t.Errorf("unexpected session after logout %q", who)
+	}
+	if infos, _ = s.List(ctx, "alice"); len(infos) != 0 {
+		t.Errorf("unexpected sessions %v", infos)
+	}
+}
+
+func TestSessions_Timeout(t *testing.T) {
+	_, router := newManaged(map[string]interface{}{"idle_timeout": "100ms", "absolute_timeout": "300ms"})
+
+	var idle = &client{router: router}
+	idle.get("/login?user=bob")
+	time.Sleep(150 * time.Millisecond)
+	if who := idle.get("/whoami"); who != ":" {
+		t.Errorf("the idle session should be cleared, got %q", who)
+	}
+
+	var active = &client{router: router}
+	active.get("/login?user=bob")
+	for i := 0; i < 4; i++ {
+		time.Sleep(60 * time.Millisecond)
+		if who := active.get("/whoami"); who != "bob:bob" {
+			t.Fatalf("the active session should be kept, got %q", who)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if who := active.get("/whoami"); who != ":" {
+		t.Errorf("the session should be cleared after the absolute timeout, got %q", who)
+	}
+}
@@ -9,8 +9,10 @@ import (
 )
 
 // RedisStoreConfig redis config
+//  connection: the name of a connection of the redis node shared by Sessions.UseRedis, the other fields are ignored if it's set
 type RedisStoreConfig struct {
-	RedisType string `mapstructure:"redis_type"`
+	Connection string `mapstructure:"connection"`
+	RedisType  string `mapstructure:"redis_type"`
 
 	Network  string `mapstructure:"network"`
 	Addr     string `mapstructure:"addr"`
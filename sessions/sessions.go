@@ -3,27 +3,63 @@ package sessions
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	gsessions "github.com/gorilla/sessions"
+	"github.com/kenretto/crane/codec"
+	credis "github.com/kenretto/crane/redis"
 	"github.com/kenretto/sessions"
+	"github.com/kenretto/sessions/cookie"
 	"github.com/kenretto/sessions/memstore"
 	store "github.com/kenretto/sessions/redis"
 	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
 
+// CookieKey a key pair of the cookies, hash_key signs the cookies, block_key encrypts them if it's set,
+//  the length of block_key must be 16, 24 or 32 to select AES-128, AES-192 or AES-256
+type CookieKey struct {
+	HashKey  string `mapstructure:"hash_key"`
+	BlockKey string `mapstructure:"block_key"`
+}
+
 // Sessions session related function processing
+//  driver: memory (default), redis, database (the database node, call UseDatabase first) or cookie (the values are kept in the encrypted cookie)
 type Sessions struct {
 	Driver           string           `mapstructure:"driver"`
 	RedisStoreConfig RedisStoreConfig `mapstructure:"redis"`
 	Key              string           `mapstructure:"key"`
-	Name             string           `mapstructure:"name"`
-	Domain           string           `mapstructure:"domain"`
-	MaxAge           string           `mapstructure:"max_age"`
-	HTTPOnly         bool             `mapstructure:"http_only"`
+	// Keys the key pairs of the cookies, the first one signs and all of them verify, so that the keys can be rotated by adding a new one in front,
+	//  key is used if it's empty
+	Keys     []CookieKey `mapstructure:"keys"`
+	Name     string      `mapstructure:"name"`
+	Domain   string      `mapstructure:"domain"`
+	Path     string      `mapstructure:"path"` // default /
+	MaxAge   string      `mapstructure:"max_age"`
+	HTTPOnly bool        `mapstructure:"http_only"`
+	Secure   bool        `mapstructure:"secure"`
+	SameSite string      `mapstructure:"same_site"` // lax, strict or none, default not set
+	Database string      `mapstructure:"database"`  // the connection name of the database node used by the database driver, default master
+	Codec    string      `mapstructure:"codec"`     // json (default) or gob, the codec of the values set by Set
+	// MaxSessions the maximum number of the sessions bound to a user, the oldest ones are revoked by Bind, 0 means unlimited
+	MaxSessions int `mapstructure:"max_sessions"`
+	// AbsoluteTimeout the sessions are cleared this long after they are created no matter whether they are active, such as 24h
+	AbsoluteTimeout string `mapstructure:"absolute_timeout"`
+	// IdleTimeout the sessions are cleared if there is no request for this long, such as 30m
+	IdleTimeout string `mapstructure:"idle_timeout"`
 
-	store sessions.Store
-	conn  redis.Cmdable `mapstructure:"redis"`
-	mu    sync.RWMutex
+	store       sessions.Store
+	conn        redis.Cmdable `mapstructure:"redis"`
+	ownConn     bool
+	shared      *credis.Redis
+	db          func(db ...string) *gorm.DB
+	index       Index
+	customIndex bool
+	localIndex  bool
+	mu          sync.RWMutex
 }
 
 func (s *Sessions) Node() string {
@@ -34,46 +70,232 @@ func (s *Sessions) Node() string {
 func (s *Sessions) OnChange(viper *viper.Viper) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.Keys = nil
 	_ = viper.Unmarshal(&s)
+	s.build()
+}
+
+// build create the store of the driver, the own redis client of the previous store is closed
+func (s *Sessions) build() {
+	var keyPairs = s.keyPairs()
+	var old, owned = s.conn, s.ownConn
+	s.conn, s.ownConn = nil, false
 	switch s.Driver {
 	case "redis":
-		s.conn = s.RedisStoreConfig.NewRedis()
-		s.store = store.NewStore(s.conn, []byte(s.Key))
+		if name := s.RedisStoreConfig.Connection; name != "" {
+			if s.shared == nil {
+				panic("sessions: call UseRedis before using the redis connection " + name)
+			}
+			if s.conn = s.shared.Connection(name); s.conn == nil {
+				panic("sessions: the redis connection " + name + " isn't configured")
+			}
+		} else {
+			s.conn, s.ownConn = s.RedisStoreConfig.NewRedis(), true
+		}
+		s.store = store.NewStore(s.conn, keyPairs...)
+		if !s.customIndex {
+			s.index = NewRedisIndex(s.conn, "")
+		}
+	case "database":
+		if s.db == nil {
+			panic("sessions: call UseDatabase before loading the database driver")
+		}
+		var db, connection = s.db, s.Database
+		var conn = func() *gorm.DB {
+			if connection == "" {
+				return db()
+			}
+			return db(connection)
+		}
+		s.store = NewDatabaseStore(conn, keyPairs...)
+		if !s.customIndex {
+			s.index = NewDatabaseIndex(conn)
+		}
+	case "cookie":
+		s.store = cookie.NewStore(keyPairs...)
 	default:
-		s.store = memstore.NewStore([]byte(s.Key))
+		s.store = memstore.NewStore(keyPairs...)
 	}
+	if s.Driver != "redis" && s.Driver != "database" {
+		if _, ok := s.index.(*MemoryIndex); !ok && !s.customIndex {
+			s.index = NewMemoryIndex()
+		}
+	}
+	// the cookie sessions outlive the process and are shared by the instances, a memory index can't tell whether they are revoked
+	s.localIndex = s.Driver == "cookie" && !s.customIndex
 	duration, err := time.ParseDuration(s.MaxAge)
 	if err != nil {
 		panic(err)
 	}
-	s.store.Options(sessions.Options{MaxAge: int(duration / time.Second), Path: "/", Domain: s.Domain, HttpOnly: s.HTTPOnly})
+	var path = s.Path
+	if path == "" {
+		path = "/"
+	}
+	s.store.Options(sessions.Options{
+		MaxAge:   int(duration / time.Second),
+		Path:     path,
+		Domain:   s.Domain,
+		HttpOnly: s.HTTPOnly,
+		Secure:   s.Secure,
+		SameSite: sameSite(s.SameSite),
+	})
+	if closer, ok := old.(io.Closer); ok && owned && old != s.conn {
+		_ = closer.Close()
+	}
+}
+
+// UseRedis share the connections of r, the redis driver uses the connection named by the connection field of the redis config instead of its own client,
+//  and the store is recreated when the connection is reloaded, call it before the sessions are added to the configurator
+//  sessions:
+//    driver: redis
+//    redis:
+//      connection: default
+func (s *Sessions) UseRedis(r *credis.Redis) {
+	s.mu.Lock()
+	s.shared = r
+	s.mu.Unlock()
+	r.OnReload(func(name string, client redis.Cmdable) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if client != nil && s.Driver == "redis" && s.RedisStoreConfig.Connection == name {
+			s.build()
+		}
+	})
+}
+
+func (s *Sessions) current() sessions.Store {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.store
+}
+
+// reloadable delegates to the current store, so that the reloaded store is used by the middleware
+type reloadable struct {
+	sessions *Sessions
+}
+
+func (r reloadable) Get(req *http.Request, name string) (*gsessions.Session, error) {
+	return r.sessions.current().Get(req, name)
+}
+
+func (r reloadable) New(req *http.Request, name string) (*gsessions.Session, error) {
+	return r.sessions.current().New(req, name)
+}
+
+func (r reloadable) Save(req *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	return r.sessions.current().Save(req, w, session)
+}
+
+func (r reloadable) Options(options sessions.Options) {
+	r.sessions.current().Options(options)
+}
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteDefaultMode
+}
+
+// keyPairs the key pairs of the keys config, or key if it's empty
+func (s *Sessions) keyPairs() [][]byte {
+	if len(s.Keys) == 0 {
+		return [][]byte{[]byte(s.Key)}
+	}
+	var pairs = make([][]byte, 0, len(s.Keys)*2)
+	for _, key := range s.Keys {
+		var block []byte
+		if key.BlockKey != "" {
+			block = []byte(key.BlockKey)
+		}
+		pairs = append(pairs, []byte(key.HashKey), block)
+	}
+	return pairs
+}
+
+// UseDatabase set the database of the database driver, db is usually crane.ORM, call it before the sessions are added to the configurator
+func (s *Sessions) UseDatabase(db func(db ...string) *gorm.DB) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db = db
+}
+
+// Close close the redis connection of the redis store, the shared connections are closed by their owner
+func (s *Sessions) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if closer, ok := s.conn.(io.Closer); ok && s.ownConn {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (s *Sessions) codec() codec.Codec {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Codec == "gob" {
+		return codec.GobCodec{}
+	}
+	return codec.JSONCodec{}
 }
 
 // Inject start the session service, call it in the custom routing code, and import it. *gin.Engine  object
+//  the changes of a request are saved once, before the response is written
 func (s *Sessions) Inject(engine *gin.Engine) gin.IRoutes {
-	return engine.Use(sessions.Sessions(s.Name, s.store))
+	return engine.Use(sessions.Sessions(s.Name, reloadable{sessions: s}), s.batch)
+}
+
+// batch save the changes before the headers are written, and after the handlers for the changes made after writing the response,
+//  the expired and the revoked sessions are cleared before the handlers
+func (s *Sessions) batch(c *gin.Context) {
+	c.Set(contextKey, s.codec())
+	var w = &writer{ResponseWriter: c.Writer, c: c, session: sessions.Default(c)}
+	c.Writer = w
+	s.check(c)
+	c.Next()
+	w.save()
+}
+
+// writer saves the session before the headers are written, so that the cookie can still be set
+type writer struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	session sessions.Session
 }
 
-// Get gets the specified session
-func Get(c *gin.Context, key string) string {
-	sess := sessions.Default(c)
-	val := sess.Get(key)
-	if val != nil {
-		return val.(string)
+// save the errors are added to gin.Context.Errors
+func (w *writer) save() {
+	if err := w.session.Save(); err != nil {
+		_ = w.c.Error(err)
 	}
-	return ""
 }
 
-// Set set session
-func Set(c *gin.Context, key, val string) {
-	sess := sessions.Default(c)
-	sess.Set(key, val)
-	_ = sess.Save()
+func (w *writer) WriteHeader(code int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writer) WriteHeaderNow() {
+	w.save()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *writer) Write(data []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *writer) WriteString(s string) (int, error) {
+	w.save()
+	return w.ResponseWriter.WriteString(s)
 }
 
-// Del delete the specified session
-func Del(c *gin.Context, key string) {
-	sess := sessions.Default(c)
-	sess.Delete(key)
-	_ = sess.Save()
+func (w *writer) Flush() {
+	w.save()
+	w.ResponseWriter.Flush()
 }
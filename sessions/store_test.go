@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSessions_CookieKeys(t *testing.T) {
+	var old = []map[string]interface{}{{"hash_key": "old-hash-key", "block_key": "0123456789abcdef"}}
+	var rotated = []map[string]interface{}{{"hash_key": "new-hash-key", "block_key": "abcdef0123456789"}, old[0]}
+
+	// the bindings are kept in the index shared by the instances
+	var index = NewMemoryIndex()
+	var load = func(keys []map[string]interface{}) *gin.Engine {
+		s, router := newManaged(map[string]interface{}{"driver": "cookie", "keys": keys})
+		s.SetIndex(index)
+		return router
+	}
+	var cli = &client{router: load(old)}
+	cli.get("/login?user=carol")
+	var signedByOld = cli.cookie
+
+	// the cookies signed by the old key are still valid, and they are signed by the new key when they are saved again
+	cli.router = load(rotated)
+	if who := cli.get("/whoami"); who != "carol:carol" {
+		t.Fatalf("the old cookie should be verified, got %q", who)
+	}
+	cli.get("/login?user=carol")
+	if cli.cookie.Value == signedByOld.Value {
+		t.Fatal("the cookie isn't saved again")
+	}
+
+	cli.router = load(rotated[:1])
+	if who := cli.get("/whoami"); who != "carol:carol" {
+		t.Errorf("the new cookie should be verified by the new key, got %q", who)
+	}
+	cli.cookie = signedByOld
+	if who := cli.get("/whoami"); who != ":" {
+		t.Errorf("the removed key shouldn't verify the cookies, got %q", who)
+	}
+}
+
+func TestSessions_CookieOptions(t *testing.T) {
+	_, router := newManaged(map[string]interface{}{"path": "/app", "secure": true, "same_site": "strict"})
+	var cli = &client{router: router}
+	cli.get("/login?user=dave")
+	if cli.cookie.Path != "/app" || !cli.cookie.Secure || cli.cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("unexpected cookie %v", cli.cookie)
+	}
+}
+
+func TestSessions_CookieLocalIndex(t *testing.T) {
+	// two instances without a shared index, the session bound by one is kept by the other
+	var _, first = newManaged(map[string]interface{}{"driver": "cookie"})
+	var _, second = newManaged(map[string]interface{}{"driver": "cookie"})
+	var cli = &client{router: first}
+	cli.get("/login?user=erin")
+	cli.router = second
+	if who := cli.get("/whoami"); who != "erin:erin" {
+		t.Errorf("the bound session should survive on another instance, got %q", who)
+	}
+}
+
+func TestSessions_DatabaseIndex(t *testing.T) {
+	var dsn = os.Getenv("CRANE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		dsn = "root:root@tcp(127.0.0.1:3306)/crane?charset=utf8mb4&parseTime=True&loc=Local"
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Skip("mysql isn't available:", err)
+	}
+	if err = Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	var conn = func(...string) *gorm.DB {
+		return db
+	}
+	var load = func() (*Sessions, *gin.Engine) {
+		return newManagedWith(map[string]interface{}{"driver": "database"}, func(s *Sessions) {
+			s.UseDatabase(conn)
+		})
+	}
+
+	// the instances share the database, the session bound by one is kept by the other and after a restart
+	var s, first = load()
+	var _, second = load()
+	var cli = &client{router: first}
+	cli.get("/login?user=frank")
+	cli.router = second
+	if who := cli.get("/whoami"); who != "frank:frank" {
+		t.Fatalf("the bound session should survive on another instance, got %q", who)
+	}
+
+	// a revoked session is cleared by every instance
+	infos, err := s.List(context.Background(), "frank")
+	if err != nil || len(infos) == 0 {
+		t.Fatalf("expected the bound session, got %v %v", infos, err)
+	}
+	if err = s.Revoke(context.Background(), "frank", infos[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if who := cli.get("/whoami"); who != ":" {
+		t.Errorf("the revoked session should be cleared, got %q", who)
+	}
+}
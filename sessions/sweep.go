@@ -0,0 +1,69 @@
+package sessions
+
+import (
+	"context"
+	"github.com/kenretto/crane/timer"
+	"sync"
+)
+
+// sweepJob deletes the expired sessions of the database driver
+type sweepJob struct {
+	sessions *Sessions
+	spec     string
+	status   timer.Status
+	mu       sync.Mutex
+}
+
+// SweepJob the timer job deleting the expired sessions of the database driver, spec is the cron spec such as @every 10m,
+//  it does nothing for the other drivers
+//  crane.Timer().AddJob(crane.Sessions().SweepJob("@every 10m"))
+func (s *Sessions) SweepJob(spec string) timer.JobInterface {
+	return &sweepJob{sessions: s, spec: spec}
+}
+
+func (job *sweepJob) Name() timer.JobName {
+	return "sessions_sweep"
+}
+
+func (job *sweepJob) Description() string {
+	return "delete the expired sessions of the database driver"
+}
+
+func (job *sweepJob) Spec() string {
+	return job.spec
+}
+
+func (job *sweepJob) Runnable() bool {
+	return true
+}
+
+func (job *sweepJob) Pause() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.status = timer.Pause
+}
+
+func (job *sweepJob) Start() {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.status = timer.Run
+}
+
+func (job *sweepJob) Status() timer.Status {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.status
+}
+
+// Run panics with the error, so that it's recorded by the timer
+func (job *sweepJob) Run() {
+	job.sessions.mu.RLock()
+	store, ok := job.sessions.store.(*DatabaseStore)
+	job.sessions.mu.RUnlock()
+	if !ok {
+		return
+	}
+	if _, err := store.Sweep(context.Background()); err != nil {
+		panic(err)
+	}
+}
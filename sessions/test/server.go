@@ -21,13 +21,14 @@ func main() {
 	s.Handler(func(router *gin.Engine) {
 		r := sess.Inject(router)
 		r.GET("/set", func(context *gin.Context) {
-			sessions.Set(context, "hello", "world")
+			_ = sessions.Set(context, "hello", "world")
 		})
 		r.GET("/get", func(context *gin.Context) {
-			context.String(http.StatusOK, sessions.Get(context, "hello"))
+			var hello, _ = sessions.Get[string](context, "hello")
+			context.String(http.StatusOK, hello)
 		})
 		r.GET("/del", func(context *gin.Context) {
-			sessions.Del(context, "hello")
+			_ = sessions.Del(context, "hello")
 		})
 	})
 
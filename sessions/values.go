@@ -0,0 +1,155 @@
+package sessions
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	gsessions "github.com/gorilla/sessions"
+	"github.com/kenretto/crane/codec"
+	"github.com/kenretto/sessions"
+)
+
+// contextKey the key of the codec in gin.Context, it's set by the middleware of Inject
+const contextKey = "github.com/kenretto/crane/sessions"
+
+// ErrUnsupported the session of the context doesn't support the operation
+var ErrUnsupported = errors.New("sessions: the session doesn't support regenerating")
+
+// contextCodec the codec of the request, and whether the changes are saved by the middleware at the end of the request,
+//  without the middleware (sessions.Sessions used directly) every change is saved immediately,
+//  the values are encoded into []byte, so the stores never need gob.Register for the application types
+func contextCodec(c *gin.Context) (codec.Codec, bool) {
+	if v, ok := c.Get(contextKey); ok {
+		return v.(codec.Codec), true
+	}
+	return codec.JSONCodec{}, false
+}
+
+func changed(c *gin.Context, sess sessions.Session) error {
+	if _, batched := contextCodec(c); batched {
+		return nil
+	}
+	return sess.Save()
+}
+
+func decode[T any](c *gin.Context, value interface{}) (T, bool) {
+	var result T
+	if value == nil {
+		return result, false
+	}
+	// the encoded values are checked first, []byte and interface{} are T as well
+	if data, ok := value.([]byte); ok {
+		cdc, _ := contextCodec(c)
+		if err := cdc.Unmarshal(data, &result); err == nil {
+			return result, true
+		}
+		// only the raw []byte values are returned as they are, never the encoded ones as interface{}
+		if _, raw := interface{}(result).([]byte); !raw {
+			return result, false
+		}
+	}
+	// the values stored before the codecs, such as the plain strings
+	if v, ok := value.(T); ok {
+		return v, true
+	}
+	return result, false
+}
+
+// Get get the value of the key, false if the key doesn't exist or the value can't be decoded as T
+func Get[T any](c *gin.Context, key string) (T, bool) {
+	return decode[T](c, sessions.Default(c).Get(key))
+}
+
+// Set set the value of the key, it's encoded by the codec of the sessions config
+func Set(c *gin.Context, key string, value interface{}) error {
+	cdc, _ := contextCodec(c)
+	data, err := cdc.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var sess = sessions.Default(c)
+	sess.Set(key, data)
+	return changed(c, sess)
+}
+
+// Del delete the keys
+func Del(c *gin.Context, keys ...string) error {
+	var sess = sessions.Default(c)
+	for _, key := range keys {
+		sess.Delete(key)
+	}
+	return changed(c, sess)
+}
+
+// Clear delete all the values
+func Clear(c *gin.Context) error {
+	var sess = sessions.Default(c)
+	sess.Clear()
+	return changed(c, sess)
+}
+
+// Save save the changes now instead of at the end of the request
+func Save(c *gin.Context) error {
+	return sessions.Default(c).Save()
+}
+
+// AddFlash add a flash message, it's removed once it's read by Flashes, kind is optional, default _flash
+func AddFlash(c *gin.Context, value interface{}, kind ...string) error {
+	cdc, _ := contextCodec(c)
+	data, err := cdc.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var sess = sessions.Default(c)
+	sess.AddFlash(data, kind...)
+	return changed(c, sess)
+}
+
+// Flashes read and remove the flash messages of the kind, the messages that can't be decoded as T are dropped
+func Flashes[T any](c *gin.Context, kind ...string) []T {
+	var sess = sessions.Default(c)
+	var flashes = sess.Flashes(kind...)
+	_ = changed(c, sess)
+	var result = make([]T, 0, len(flashes))
+	for _, flash := range flashes {
+		if v, ok := decode[T](c, flash); ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Regenerate move the values to a new session ID and delete the old session, call it after login to prevent session fixation
+func Regenerate(c *gin.Context) error {
+	var sess = sessions.Default(c)
+	underlying, ok := sess.(interface{ Session() *gsessions.Session })
+	if !ok {
+		return ErrUnsupported
+	}
+	var session = underlying.Session()
+	if session == nil {
+		return ErrUnsupported
+	}
+
+	var values = make(map[interface{}]interface{}, len(session.Values))
+	for k, v := range session.Values {
+		values[k] = v
+	}
+	if !session.IsNew && session.ID != "" {
+		// the options may be shared with the store, the expired copy deletes the old session
+		var options = session.Options
+		var expired = *options
+		expired.MaxAge = -1
+		session.Options = &expired
+		err := session.Store().Save(c.Request, c.Writer, session)
+		session.Options = options
+		if err != nil {
+			return err
+		}
+	}
+
+	session.ID, session.IsNew, session.Values = "", true, make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		sess.Set(k, v)
+	}
+	return changed(c, sess)
+}
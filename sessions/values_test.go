@@ -0,0 +1,141 @@
+package sessions
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/sessions"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type profile struct {
+	ID   int
+	Name string
+}
+
+func newRouter(codec string) *gin.Engine {
+	var s = new(Sessions)
+	var config = viper.New()
+	config.Set("driver", "memory")
+	config.Set("key", "secret")
+	config.Set("name", "session")
+	config.Set("max_age", "1h")
+	config.Set("codec", codec)
+	s.OnChange(config)
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	s.Inject(router)
+	router.GET("/set", func(c *gin.Context) {
+		_ = Set(c, "profile", profile{ID: 1, Name: "crane"})
+		_ = Set(c, "count", 3)
+		_ = AddFlash(c, "saved")
+		sessions.Default(c).Set("raw", "plain")
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/get", func(c *gin.Context) {
+		p, _ := Get[profile](c, "profile")
+		count, _ := Get[int](c, "count")
+		raw, _ := Get[string](c, "raw")
+		_, wrong := Get[int](c, "raw")
+		c.JSON(http.StatusOK, gin.H{"profile": p, "count": count, "raw": raw, "wrong": wrong, "flashes": Flashes[string](c)})
+	})
+	router.GET("/id", func(c *gin.Context) {
+		c.String(http.StatusOK, sessions.Default(c).ID())
+	})
+	router.GET("/login", func(c *gin.Context) {
+		if err := Regenerate(c); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		// the new id is generated by the store when the session is saved
+		_ = Save(c)
+		c.String(http.StatusOK, sessions.Default(c).ID())
+	})
+	return router
+}
+
+func serve(router *gin.Engine, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	var req = httptest.NewRequest(http.MethodGet, path, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	var recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestValues(t *testing.T) {
+	for _, codec := range []string{"json", "gob"} {
+		var router = newRouter(codec)
+		var recorder = serve(router, "/set", nil)
+		// the changes are saved once
+		if n := len(recorder.Header().Values("Set-Cookie")); n != 1 {
+			t.Fatalf("%s: %d cookies", codec, n)
+		}
+		var cookies = recorder.Result().Cookies()
+
+		recorder = serve(router, "/get", cookies)
+		var expected = `{"count":3,"flashes":["saved"],"profile":{"ID":1,"Name":"crane"},"raw":"plain","wrong":false}`
+		if recorder.Body.String() != expected {
+			t.Errorf("%s: unexpected values %s", codec, recorder.Body.String())
+		}
+		// the flashes are removed once they are read
+		recorder = serve(router, "/get", cookies)
+		if recorder.Body.String() != `{"count":3,"flashes":[],"profile":{"ID":1,"Name":"crane"},"raw":"plain","wrong":false}` {
+			t.Errorf("%s: unexpected values %s", codec, recorder.Body.String())
+		}
+	}
+}
+
+func TestValues_Bytes(t *testing.T) {
+	for _, codec := range []string{"json", "gob"} {
+		var router = newRouter(codec)
+		router.GET("/bytes", func(c *gin.Context) {
+			if _, ok := c.GetQuery("set"); ok {
+				_ = Set(c, "bytes", []byte("data"))
+				c.String(http.StatusOK, "ok")
+				return
+			}
+			data, _ := Get[[]byte](c, "bytes")
+			value, ok := Get[interface{}](c, "count")
+			c.JSON(http.StatusOK, gin.H{"bytes": string(data), "any": value, "ok": ok})
+		})
+		var cookies = serve(router, "/set", nil).Result().Cookies()
+		var recorder = serve(router, "/bytes?set", cookies)
+		cookies = recorder.Result().Cookies()
+
+		recorder = serve(router, "/bytes", cookies)
+		var expected = `{"any":3,"bytes":"data","ok":true}`
+		if codec == "gob" {
+			// gob decodes into interface{} only the interface values, the encoded value isn't returned
+			expected = `{"any":null,"bytes":"data","ok":false}`
+		}
+		if recorder.Body.String() != expected {
+			t.Errorf("%s: unexpected values %s", codec, recorder.Body.String())
+		}
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	var router = newRouter("json")
+	var cookies = serve(router, "/set", nil).Result().Cookies()
+	var id = serve(router, "/id", cookies).Body.String()
+
+	var recorder = serve(router, "/login", cookies)
+	var regenerated = recorder.Result().Cookies()
+	// the old session is expired first, then the new one is set
+	if len(regenerated) != 2 || regenerated[0].MaxAge >= 0 || recorder.Body.String() == id {
+		t.Fatalf("the session id isn't regenerated: %s %v", recorder.Body.String(), regenerated)
+	}
+	var current = regenerated[1]
+	if serve(router, "/id", []*http.Cookie{current}).Body.String() != recorder.Body.String() {
+		t.Fatal("the new session isn't saved")
+	}
+
+	recorder = serve(router, "/get", []*http.Cookie{current})
+	if recorder.Body.String() != `{"count":3,"flashes":["saved"],"profile":{"ID":1,"Name":"crane"},"raw":"plain","wrong":false}` {
+		t.Errorf("the values aren't kept: %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package timer
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/response"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// jobView 管理接口中任务的信息
+type jobView struct {
+	Name        JobName                `json:"name"`
+	Description string                 `json:"description"`
+	Spec        string                 `json:"spec"`
+	Status      Status                 `json:"status"`
+	Prev        time.Time              `json:"prev"`
+	Next        time.Time              `json:"next"`
+	LastError   map[string]interface{} `json:"last_error"`
+	History     []Record               `json:"history"`
+}
+
+// specRequest 修改执行时间表达式的请求
+type specRequest struct {
+	Spec string `json:"spec" binding:"required"`
+}
+
+// view 任务的信息
+func (timer *Timer) view(task *Task) jobView {
+	return jobView{
+		Name:        task.Name,
+		Description: task.Job.Description(),
+		Spec:        task.Spec(),
+		Status:      task.Status(),
+		Prev:        timer.Prev(task.Name),
+		Next:        timer.Next(task.Name),
+		LastError:   timer.LastError(task.Name),
+		History:     timer.History(task.Name),
+	}
+}
+
+// AdminRoutes 注册管理定时任务的路由, 需要通过 middlewares 限制只有有权限的用户可以访问
+//  GET  /timer/jobs               所有任务, 按名称排序
+//  GET  /timer/jobs/:name         任务的信息
+//  POST /timer/jobs/:name/pause   暂停任务, 暂停状态保存在 StoreDriver 中, 所有实例和重启后都处于暂停状态
+//  POST /timer/jobs/:name/resume  再次启动任务, 所有实例都恢复执行
+//  POST /timer/jobs/:name/trigger 在当前实例立即执行一次, 异步执行, 暂停的任务返回 409
+//  PUT  /timer/jobs/:name/spec    修改执行时间表达式, body: {"spec": "0 0 3 * * ?"}, 保存在 StoreDriver 中, 其它实例在下一次调度时应用
+func AdminRoutes(router gin.IRouter, t *Timer, middlewares ...gin.HandlerFunc) {
+	var group = router.Group("/timer/jobs", middlewares...)
+
+	// task 获取路径中的任务, 不存在时响应 404
+	var task = func(ctx *gin.Context) *Task {
+		var task = t.Tasks().GetJob(JobName(ctx.Param("name")))
+		if task == nil {
+			response.NewResponse(response.NotFound.Code, nil, ErrJobNotFound.Error()).End(ctx, http.StatusNotFound)
+		}
+		return task
+	}
+
+	group.GET("", func(ctx *gin.Context) {
+		var jobs = make([]jobView, 0, len(t.Tasks().All()))
+		for _, task := range t.Tasks().All() {
+			jobs = append(jobs, t.view(task))
+		}
+		sort.Slice(jobs, func(i, j int) bool {
+			return jobs[i].Name < jobs[j].Name
+		})
+		response.NewResponse(response.Success.Code, jobs).End(ctx)
+	})
+
+	group.GET("/:name", func(ctx *gin.Context) {
+		if task := task(ctx); task != nil {
+			response.NewResponse(response.Success.Code, t.view(task)).End(ctx)
+		}
+	})
+
+	group.POST("/:name/pause", func(ctx *gin.Context) {
+		if task := task(ctx); task != nil {
+			t.Tasks().Pause(task.Name)
+			response.NewResponse(response.Success.Code, t.view(task)).End(ctx)
+		}
+	})
+
+	group.POST("/:name/resume", func(ctx *gin.Context) {
+		if task := task(ctx); task != nil {
+			t.Tasks().Start(task.Name)
+			response.NewResponse(response.Success.Code, t.view(task)).End(ctx)
+		}
+	})
+
+	group.POST("/:name/trigger", func(ctx *gin.Context) {
+		var task = task(ctx)
+		if task == nil {
+			return
+		}
+		if task.Status() == Pause {
+			response.NewResponse(response.Failed.Code, nil, "job is paused").End(ctx, http.StatusConflict)
+			return
+		}
+		go t.Tasks().Exec(task.Name)
+		response.NewResponse(response.Success.Code, nil).End(ctx, http.StatusAccepted)
+	})
+
+	group.PUT("/:name/spec", func(ctx *gin.Context) {
+		var task = task(ctx)
+		if task == nil {
+			return
+		}
+		var req specRequest
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusBadRequest)
+			return
+		}
+		if err := t.Reschedule(task.Name, req.Spec); err != nil {
+			response.NewResponse(response.Failed.Code, nil, err.Error()).End(ctx, http.StatusBadRequest)
+			return
+		}
+		response.NewResponse(response.Success.Code, t.view(task)).End(ctx)
+	})
+}
@@ -0,0 +1,142 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+// HistoryLimit 每个任务保留的执行记录数量
+var HistoryLimit = 20
+
+const (
+	// ResultSuccess 执行成功
+	ResultSuccess = "success"
+	// ResultFailed ContextJobInterface 返回了错误
+	ResultFailed = "failed"
+	// ResultPanic 执行时发生了 panic
+	ResultPanic = "panic"
+)
+
+var (
+	runsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "crane",
+			Name:      "timer_runs_total",
+			Help:      "timer job runs by result",
+		},
+		[]string{"job", "result"},
+	)
+	runDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "crane",
+			Name:      "timer_run_duration_seconds",
+			Help:      "timer job run duration",
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(runsCounter)
+	prometheus.MustRegister(runDuration)
+}
+
+// Record 任务的一次执行记录
+type Record struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Result   string        `json:"result"`
+	Error    string        `json:"error,omitempty"`
+	Node     string        `json:"node"` // 执行任务的实例, 主机名和进程号
+}
+
+// HistoryDriver 保存执行记录的驱动, driver 实现它时使用它保存, 否则执行记录以 json 数组保存在 driver 的 history 状态中
+type HistoryDriver interface {
+	// AddRecord 添加一条执行记录, 只保留最近的 limit 条
+	AddRecord(name JobName, record string, limit int)
+	// Records 最近的执行记录, 最新的在前
+	Records(name JobName) []string
+}
+
+// ContextJobInterface 与 JobInterface 相同, 只是 Run 接收 ctx 并返回错误, 通过 Timer.AddContextJob 添加
+type ContextJobInterface interface {
+	// Name 定时任务名称, 保持唯一
+	Name() JobName
+	// Description 对定时任务的描述
+	Description() string
+	// Spec 执行触发时间表达式, 见 JobInterface
+	Spec() string
+	Runnable() bool
+	Pause()
+	// Start 再次启动
+	Start()
+	// Run 执行任务, ctx 在定时器停止且等待超时后取消, 返回的错误记录在执行记录和 LastError 中
+	Run(ctx context.Context) error
+	Status() Status
+}
+
+// contextJob 将 ContextJobInterface 适配为 JobInterface, Task 执行时调用 ContextJobInterface 的 Run
+type contextJob struct {
+	ContextJobInterface
+}
+
+// Run 实现 JobInterface
+func (job contextJob) Run() {
+	_ = job.ContextJobInterface.Run(context.Background())
+}
+
+// AddRecord 使用 list 保存执行记录
+func (r *RedisStore) AddRecord(name JobName, record string, limit int) {
+	var ctx = context.Background()
+	var key = fmt.Sprintf("cron_history_%s", name)
+	_, _ = r.redis().TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, record)
+		pipe.LTrim(ctx, key, 0, int64(limit-1))
+		return nil
+	})
+}
+
+// Records 最近的执行记录
+func (r *RedisStore) Records(name JobName) []string {
+	return r.redis().LRange(context.Background(), fmt.Sprintf("cron_history_%s", name), 0, -1).Val()
+}
+
+// addRecord 保存执行记录并更新指标
+func addRecord(name JobName, record Record) {
+	runsCounter.With(prometheus.Labels{"job": string(name), "result": record.Result}).Inc()
+	runDuration.With(prometheus.Labels{"job": string(name)}).Observe(record.Duration.Seconds())
+
+	if d, ok := driver.(HistoryDriver); ok {
+		var s, _ = jsoniter.MarshalToString(record)
+		d.AddRecord(name, s, HistoryLimit)
+		return
+	}
+	var records = history(name)
+	records = append([]Record{record}, records...)
+	if len(records) > HistoryLimit {
+		records = records[:HistoryLimit]
+	}
+	var s, _ = jsoniter.MarshalToString(records)
+	driver.Set(name, "history", s)
+}
+
+// history 任务最近的执行记录, 最新的在前
+func history(name JobName) []Record {
+	var records []Record
+	if d, ok := driver.(HistoryDriver); ok {
+		for _, s := range d.Records(name) {
+			var record Record
+			if jsoniter.UnmarshalFromString(s, &record) == nil {
+				records = append(records, record)
+			}
+		}
+		return records
+	}
+	_ = jsoniter.UnmarshalFromString(driver.Get(name, "history"), &records)
+	return records
+}
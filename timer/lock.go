@@ -0,0 +1,89 @@
+package timer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultLockTTL 每次执行的锁默认的过期时间
+var DefaultLockTTL = time.Minute
+
+// node 当前实例的标识, 作为锁的值, 便于排查是哪个实例执行的
+var node = func() string {
+	var host, _ = os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// locker 全局的执行锁, 为空时使用实现了 Locker 的 driver
+var locker Locker
+
+// Locker 集群中的执行锁, 多个实例运行同一个定时器时, 同一个任务的每次调度只有获得锁的实例执行
+//  锁按任务名称和调度时间区分, 使用 @every 的任务触发时间取决于启动时间, 调度时间为按间隔划分的时间段的开始时间,
+//  所以每个间隔内只有一个实例执行
+type Locker interface {
+	// Lock 获取任务在 scheduled 这次调度的锁, ttl 后自动释放, 获取成功返回 true
+	Lock(name JobName, scheduled time.Time, ttl time.Duration) bool
+}
+
+// ClusterJob 定时任务可以实现的接口, 用于控制在集群中的执行方式, 未实现时每次调度只在一个实例执行, 锁的过期时间为 DefaultLockTTL
+type ClusterJob interface {
+	// RunOnEveryNode 返回 true 时不加锁, 每个实例都执行
+	RunOnEveryNode() bool
+	// LockTTL 每次执行的锁的过期时间, 需要大于各实例间的时钟误差, 小于等于 0 时使用 DefaultLockTTL
+	LockTTL() time.Duration
+}
+
+// UseLocker 使用自定义的执行锁, 为空时使用实现了 Locker 的 driver, 如 RedisStore
+func UseLocker(l Locker) {
+	locker = l
+}
+
+// currentLocker 当前使用的执行锁, 都没有配置时返回 nil, 每个实例都执行
+func currentLocker() Locker {
+	if locker != nil {
+		return locker
+	}
+	if l, ok := driver.(Locker); ok {
+		return l
+	}
+	return nil
+}
+
+// Lock 使用 SET NX 获取任务某次调度的锁, redis 出错时视为未获取, 本次不执行
+func (r *RedisStore) Lock(name JobName, scheduled time.Time, ttl time.Duration) bool {
+	var key = fmt.Sprintf("cron_lock_%s_%d", name, scheduled.Unix())
+	ok, err := r.redis().SetNX(context.Background(), key, node, ttl).Result()
+	return err == nil && ok
+}
+
+// MemoryLocker 进程内的执行锁, 用于同一进程内的多个定时器和测试
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]time.Time
+}
+
+// NewMemoryLocker 进程内的执行锁
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]time.Time)}
+}
+
+// Lock 获取任务某次调度的锁, 同时清理过期的锁
+func (m *MemoryLocker) Lock(name JobName, scheduled time.Time, ttl time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var now = time.Now()
+	for key, expire := range m.locks {
+		if !expire.After(now) {
+			delete(m.locks, key)
+		}
+	}
+	var key = fmt.Sprintf("%s_%d", name, scheduled.Unix())
+	if _, ok := m.locks[key]; ok {
+		return false
+	}
+	m.locks[key] = now.Add(ttl)
+	return true
+}
@@ -1,30 +1,32 @@
 package timer
 
 import (
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 	"strings"
 )
 
-// ILogger 用于将 logrus 转为 log.Logger
-type ILogger struct {
-	*logrus.Entry
+// cronLogger 用于将 logging.Logger 转为 cron 的 logger
+type cronLogger struct {
+	logger logging.Logger
 }
 
 // Info 实现来自 cron 的logger接口
-func (logger *ILogger) Info(_ string, _ ...interface{}) {
-	//logger.Logger.WithField("kvs", keysAndValues).WithField("log_type", "cron").Info(msg)
+func (logger *cronLogger) Info(_ string, _ ...interface{}) {
+	//logger.logger.WithFields(logging.Fields{"kvs": keysAndValues, "log_type": "cron"}).Info(msg)
 }
 
 // Error 实现来自 cron 的logger接口
-func (logger *ILogger) Error(err error, msg string, keysAndValues ...interface{}) {
-	var l = logger.Logger.WithField("error", err).WithField("log_type", "cron")
-	for i := 0; i < len(keysAndValues); i += 2 {
-		if i%2 == 0 {
-			switch keysAndValues[i].(type) {
-			case string:
-				l = l.WithField(keysAndValues[i].(string), strings.Split(strings.ReplaceAll(keysAndValues[i+1].(string), "\t", ""), "\n"))
+func (logger *cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	var fields = logging.Fields{"error": err, "log_type": "cron"}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		switch key := keysAndValues[i].(type) {
+		case string:
+			if value, ok := keysAndValues[i+1].(string); ok {
+				fields[key] = strings.Split(strings.ReplaceAll(value, "\t", ""), "\n")
+			} else {
+				fields[key] = keysAndValues[i+1]
 			}
 		}
 	}
-	l.Error(msg)
+	logger.logger.WithFields(fields).Error(msg)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/go-redis/redis/v8"
+	credis "github.com/kenretto/crane/redis"
 )
 
 // driver 全局保存驱动
@@ -19,22 +20,28 @@ type StoreDriver interface {
 
 // RedisStore redis 存储
 type RedisStore struct {
-	redis redis.UniversalClient
+	redis func() redis.Cmdable
 }
 
 // Set 设置指定任务的某个状态对应的值
 func (r *RedisStore) Set(name JobName, key, val string) {
-	r.redis.Set(context.Background(), fmt.Sprintf("cron_status_%s_%s", name, key), val, 0)
+	r.redis().Set(context.Background(), fmt.Sprintf("cron_status_%s_%s", name, key), val, 0)
 }
 
 // Get 获取某个任务的某个状态
 func (r *RedisStore) Get(name JobName, key string) string {
-	return r.redis.Get(context.Background(), fmt.Sprintf("cron_status_%s_%s", name, key)).Val()
+	return r.redis().Get(context.Background(), fmt.Sprintf("cron_status_%s_%s", name, key)).Val()
 }
 
 // UseRedisStore 将默认全局变量 driver 注册为 RedisStore
 func UseRedisStore(r redis.UniversalClient) {
-	driver = &RedisStore{redis: r}
+	driver = &RedisStore{redis: func() redis.Cmdable { return r }}
+}
+
+// UseSharedRedis 将默认全局变量 driver 注册为使用 redis 节点中名为 connection 的连接的 RedisStore,
+//  每次读写时获取连接, 所以连接重新加载后使用新的连接
+func UseSharedRedis(r *credis.Redis, connection string) {
+	driver = &RedisStore{redis: func() redis.Cmdable { return r.Connection(connection) }}
 }
 
 // UseCustomStore 使用自定义的存储
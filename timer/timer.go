@@ -1,11 +1,13 @@
 package timer
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/kenretto/crane/logging"
 	"github.com/kenretto/crane/util/stack"
 	"github.com/robfig/cron/v3"
-	"github.com/sirupsen/logrus"
 	"sync"
 	"time"
 )
@@ -13,6 +15,8 @@ import (
 var (
 	// ErrJobExist 同名字的任务已经被添加过了
 	ErrJobExist = errors.New("job has been exist")
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("job not found")
 )
 
 // JobName 任务名称
@@ -78,44 +82,180 @@ func (status Status) MarshalJSON() (data []byte, err error) {
 func (status Status) String() string {
 	switch status {
 	case Run:
-		return "正常"
+		return "running"
 	case Pause:
-		return "暂停"
+		return "paused"
 	}
 
-	return "UnKnow"
+	return "unknown"
 }
 
 // Task 单个定时任务的一些信息
 type Task struct {
-	EntryID cron.EntryID // 定时任务标识
+	EntryID cron.EntryID // 定时任务标识, 添加到定时器后由 Tasks 的锁保护
 	Name    JobName
 	Job     JobInterface // 定时任务本身
+	timer   *Timer
+	spec    string // Timer.Reschedule 修改后的执行时间表达式, 由 Tasks 的锁保护
 }
 
-// Run 运行
+// Run 由 cron 调度执行, 配置了 Locker 时同一次调度在集群中只有一个实例执行, 见 ClusterJob
+//  其它实例通过 Timer.Reschedule 修改了执行时间表达式时, 应用新的表达式, 本次调度不执行
 func (info *Task) Run() {
+	if info.timer != nil && info.timer.reload(info) {
+		return
+	}
+	if !info.runnable() || !info.obtain(time.Now()) {
+		return
+	}
+	info.run()
+}
+
+// runnable 任务已添加到定时器, 且没有在 StoreDriver 和当前实例中暂停
+func (info *Task) runnable() bool {
+	return info.entryID() != 0 && info.Status() != Pause && info.Job.Status() != Pause && info.Job.Runnable()
+}
+
+// lock 添加到定时器后使用 Tasks 的锁保护 EntryID 和 spec
+func (info *Task) lock() func() {
+	if info.timer == nil {
+		return func() {}
+	}
+	info.timer.tasks.lock.Lock()
+	return info.timer.tasks.lock.Unlock
+}
+
+// entryID 当前的 cron 标识, Timer.Reschedule 会修改它
+func (info *Task) entryID() cron.EntryID {
+	defer info.lock()()
+	return info.EntryID
+}
+
+// specParser 与 cron.WithSeconds 相同的执行时间表达式解析
+var specParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// slot 本次调度的时间和锁的过期时间, cron 在整秒触发, 所以各实例截断到秒得到相同的调度时间,
+//  @every 的触发时间取决于各实例的启动时间, 使用 floor(now / 间隔) 所在时间段的开始时间, 锁的过期时间至少为一个间隔
+func (info *Task) slot(now time.Time, ttl time.Duration) (time.Time, time.Duration) {
+	if schedule, err := specParser.Parse(info.Spec()); err == nil {
+		if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+			var interval = int64(every.Delay / time.Second)
+			if every.Delay > ttl {
+				ttl = every.Delay
+			}
+			return time.Unix(now.Unix()/interval*interval, 0), ttl
+		}
+	}
+	return now.Truncate(time.Second), ttl
+}
+
+// obtain 获取本次调度的执行锁, 调度时间见 slot
+func (info *Task) obtain(now time.Time) bool {
+	var l = currentLocker()
+	if l == nil {
+		return true
+	}
+	var ttl = DefaultLockTTL
+	if job, ok := info.Job.(ClusterJob); ok {
+		if job.RunOnEveryNode() {
+			return true
+		}
+		if job.LockTTL() > 0 {
+			ttl = job.LockTTL()
+		}
+	}
+	scheduled, ttl := info.slot(now, ttl)
+	return l.Lock(info.Name, scheduled, ttl)
+}
+
+// run 执行任务, 记录执行时间, 执行记录和错误
+func (info *Task) run() {
+	var record = Record{Start: time.Now(), Result: ResultSuccess, Node: node}
 	defer func() {
 		if err := recover(); err != nil {
-			err := map[string]interface{}{
+			record.Result, record.Error = ResultPanic, fmt.Sprint(err)
+			info.setError(map[string]interface{}{
 				"msg":   err,
 				"stack": stack.Stack(3),
-			}
-			var s, _ = jsoniter.MarshalToString(err)
-			driver.Set(info.Name, "error", s)
+			})
 		}
+		record.End = time.Now()
+		record.Duration = record.End.Sub(record.Start)
+		addRecord(info.Name, record)
 	}()
-	var runnable = info.Job.Runnable()
-	if info.EntryID == 0 || info.Job.Status() == Pause || !runnable {
+	driver.Set(info.Name, "prev", record.Start.Format("2006-01-02 15:04:05.999999999 -0700 MST"))
+	if job, ok := info.Job.(contextJob); ok {
+		if err := job.ContextJobInterface.Run(info.context()); err != nil {
+			record.Result, record.Error = ResultFailed, err.Error()
+			info.setError(struct {
+				Msg string `json:"msg"`
+			}{err.Error()})
+		}
 		return
 	}
-	driver.Set(info.Name, "prev", time.Now().Format("2006-01-02 15:04:05.999999999 -0700 MST"))
 	info.Job.Run()
 }
 
-// Status 状态
+// setError 保存最后一次的错误
+func (info *Task) setError(err interface{}) {
+	var s, _ = jsoniter.MarshalToString(err)
+	driver.Set(info.Name, "error", s)
+}
+
+// context ContextJobInterface 执行时的 ctx, 定时器停止且等待超时后取消
+func (info *Task) context() context.Context {
+	if info.timer == nil {
+		return context.Background()
+	}
+	info.timer.mu.Lock()
+	defer info.timer.mu.Unlock()
+	return info.timer.ctx
+}
+
+// Status 状态, 使用 StoreDriver 时只读取其中保存的暂停状态, 所有实例和重启后都相同,
+//  Job.Status 是任务在当前实例中的状态, 两者之一暂停时任务不执行, 没有 StoreDriver 时返回 Job.Status
 func (info *Task) Status() Status {
-	return info.Job.Status()
+	if driver == nil {
+		return info.Job.Status()
+	}
+	if paused(info.Name) {
+		return Pause
+	}
+	return Run
+}
+
+// Spec 执行时间表达式, 通过 Timer.Reschedule 修改后返回修改后的表达式
+func (info *Task) Spec() string {
+	defer info.lock()()
+	if info.spec != "" {
+		return info.spec
+	}
+	return info.Job.Spec()
+}
+
+// paused 暂停状态是否保存在 StoreDriver 中
+func paused(name JobName) bool {
+	return driver != nil && driver.Get(name, "paused") == "1"
+}
+
+// storedSpec 保存在 StoreDriver 中的执行时间表达式, 没有修改过时为空
+func storedSpec(name JobName) string {
+	if driver == nil {
+		return ""
+	}
+	return driver.Get(name, "spec")
+}
+
+// setPaused 在 StoreDriver 中保存暂停状态
+func setPaused(name JobName, pause bool) {
+	if driver == nil {
+		return
+	}
+	var val = "0"
+	if pause {
+		val = "1"
+	}
+	driver.Set(name, "paused", val)
 }
 
 // Tasks 定时任务集合
@@ -124,19 +264,30 @@ type Tasks struct {
 	tasks map[JobName]*Task
 }
 
-// Pause 暂停某个任务
+// Pause 暂停某个任务, 暂停状态保存在 StoreDriver 中, 对所有实例生效, 没有 StoreDriver 时调用 Job.Pause
 func (t *Tasks) Pause(name JobName) {
-	t.tasks[name].Job.Pause()
+	if driver == nil {
+		t.tasks[name].Job.Pause()
+		return
+	}
+	setPaused(name, true)
 }
 
-// Start 再次启动
+// Start 再次启动, 与 Pause 相同, 没有 StoreDriver 时调用 Job.Start
 func (t *Tasks) Start(name JobName) {
-	t.tasks[name].Job.Start()
+	if driver == nil {
+		t.tasks[name].Job.Start()
+		return
+	}
+	setPaused(name, false)
 }
 
-// Exec 直接执行
+// Exec 直接在当前实例执行, 不获取执行锁, 暂停的任务不执行
 func (t *Tasks) Exec(name JobName) {
-	t.tasks[name].Run()
+	var task = t.tasks[name]
+	if task.runnable() {
+		task.run()
+	}
 }
 
 // All 所有任务列表
@@ -173,25 +324,59 @@ func (t *Tasks) AddJob(jobInterface JobInterface) error {
 type Timer struct {
 	Cron  *cron.Cron
 	tasks *Tasks
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewTimer 开启新的定时器
 // 使用 quartz 规则, 这是一种 Java 定时任务同用的规则, 他可以精确到秒
 //  see http://www.quartz-scheduler.org/documentation/quartz-2.3.0/tutorials/tutorial-lesson-06.html
-func NewTimer(log *logrus.Entry) *Timer {
+func NewTimer(log logging.Logger) *Timer {
+	var ctx, cancel = context.WithCancel(context.Background())
 	return &Timer{Cron: cron.New(
 		cron.WithSeconds(),
 		cron.WithLocation(time.Local),
-		cron.WithLogger(&ILogger{log}),
-		cron.WithChain(cron.Recover(&ILogger{log})),
-	), tasks: new(Tasks)}
+		cron.WithLogger(&cronLogger{log}),
+		cron.WithChain(cron.Recover(&cronLogger{log})),
+	), tasks: new(Tasks), ctx: ctx, cancel: cancel}
 }
 
 // Run 启动定时器
 func (timer *Timer) Run() {
+	timer.mu.Lock()
+	if timer.ctx.Err() != nil {
+		timer.ctx, timer.cancel = context.WithCancel(context.Background())
+	}
+	timer.mu.Unlock()
 	timer.Cron.Start()
 }
 
+// Init 实现 crane.Lifecycle, 无需初始化
+func (timer *Timer) Init(_ context.Context) error {
+	return nil
+}
+
+// Start 实现 crane.Lifecycle, 启动定时器, 可以通过 Crane.Manage 交给 Crane 管理启动和停止
+func (timer *Timer) Start(_ context.Context) error {
+	timer.Run()
+	return nil
+}
+
+// Stop 停止定时器, 并等待正在执行的任务结束, ctx 结束时不再等待, 并取消 ContextJobInterface 执行时的 ctx
+func (timer *Timer) Stop(ctx context.Context) error {
+	select {
+	case <-timer.Cron.Stop().Done():
+		return nil
+	case <-ctx.Done():
+		timer.mu.Lock()
+		timer.cancel()
+		timer.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
 // Tasks 任务列表
 func (timer *Timer) Tasks() *Tasks {
 	return timer.tasks
@@ -203,7 +388,12 @@ func (timer *Timer) Prev(name JobName) time.Time {
 	return t
 }
 
-// LastError 上次执行的时间
+// History 最近的执行记录, 最新的在前, 最多保留 HistoryLimit 条
+func (timer *Timer) History(name JobName) []Record {
+	return history(name)
+}
+
+// LastError 上次执行的错误, panic 时包含 msg 和 stack, ContextJobInterface 返回错误时包含 msg
 func (timer *Timer) LastError(name JobName) map[string]interface{} {
 	var err map[string]interface{}
 	_ = jsoniter.UnmarshalFromString(driver.Get(name, "error"), &err)
@@ -212,10 +402,10 @@ func (timer *Timer) LastError(name JobName) map[string]interface{} {
 
 // Next 下一次执行的时间
 func (timer *Timer) Next(name JobName) time.Time {
-	return timer.Cron.Entry(timer.Tasks().GetJob(name).EntryID).Next
+	return timer.Cron.Entry(timer.Tasks().GetJob(name).entryID()).Next
 }
 
-// AddJob 添加任务
+// AddJob 添加任务, StoreDriver 中保存了 Timer.Reschedule 修改后的执行时间表达式时使用它
 func (timer *Timer) AddJob(job JobInterface) error {
 	err := timer.tasks.AddJob(job)
 	if err != nil {
@@ -223,10 +413,60 @@ func (timer *Timer) AddJob(job JobInterface) error {
 	}
 
 	var jobInfo = timer.tasks.GetJob(job.Name())
-	entryID, err := timer.Cron.AddJob(jobInfo.Job.Spec(), jobInfo)
+	jobInfo.timer = timer
+	if spec := storedSpec(job.Name()); spec != "" {
+		// 保存的表达式无效时使用任务的 Spec
+		if timer.schedule(jobInfo, spec) == nil {
+			return nil
+		}
+	}
+	return timer.schedule(jobInfo, "")
+}
+
+// Reschedule 修改任务的执行时间表达式, 保存在 StoreDriver 中, 其它实例在下一次调度时应用, 重启后依然有效
+func (timer *Timer) Reschedule(name JobName, spec string) error {
+	var task = timer.tasks.GetJob(name)
+	if task == nil {
+		return ErrJobNotFound
+	}
+	if err := timer.schedule(task, spec); err != nil {
+		return err
+	}
+	if driver != nil {
+		driver.Set(name, "spec", spec)
+	}
+	return nil
+}
+
+// schedule 使用 spec 调度任务, 替换之前的调度, spec 为空时使用任务的 Spec
+func (timer *Timer) schedule(task *Task, spec string) error {
+	var expr = spec
+	if expr == "" {
+		expr = task.Job.Spec()
+	}
+	timer.tasks.lock.Lock()
+	defer timer.tasks.lock.Unlock()
+	entryID, err := timer.Cron.AddJob(expr, task)
 	if err != nil {
 		return err
 	}
-	jobInfo.EntryID = entryID
-	return err
+	if task.EntryID != 0 {
+		timer.Cron.Remove(task.EntryID)
+	}
+	task.EntryID, task.spec = entryID, spec
+	return nil
+}
+
+// reload 应用其它实例保存在 StoreDriver 中的执行时间表达式, 重新调度时返回 true
+func (timer *Timer) reload(task *Task) bool {
+	var spec = storedSpec(task.Name)
+	if spec == "" || spec == task.Spec() {
+		return false
+	}
+	return timer.schedule(task, spec) == nil
+}
+
+// AddContextJob 添加 Run 接收 ctx 并返回错误的任务
+func (timer *Timer) AddContextJob(job ContextJobInterface) error {
+	return timer.AddJob(contextJob{job})
 }
@@ -1,9 +1,18 @@
 package timer
 
 import (
-	"github.com/sirupsen/logrus"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kenretto/crane/logging"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type PrintHello struct {
@@ -43,7 +52,7 @@ func (p *PrintHello) Run() {
 }
 
 func TestNewTimer(t *testing.T) {
-	var timer = NewTimer(logrus.New().WithField("filter", "pkg.timer.test"))
+	var timer = NewTimer(logging.Default().WithFields(logging.Fields{"filter": "pkg.timer.test"}))
 	_ = timer.AddJob(&PrintHello{})
 	err := timer.AddJob(&PrintHello{})
 
@@ -60,3 +69,226 @@ func TestNewTimer(t *testing.T) {
 
 	timer.Tasks().Start("print_hello")
 }
+
+// memoryStore 测试用的状态存储
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (m *memoryStore) Set(name JobName, key, val string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[string(name)+"_"+key] = val
+}
+
+func (m *memoryStore) Get(name JobName, key string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.values[string(name)+"_"+key]
+}
+
+type countJob struct {
+	PrintHello
+	runs      *int32
+	everyNode bool
+}
+
+func (c *countJob) Name() JobName {
+	return "count"
+}
+
+func (c *countJob) Run() {
+	atomic.AddInt32(c.runs, 1)
+}
+
+func (c *countJob) RunOnEveryNode() bool {
+	return c.everyNode
+}
+
+func (c *countJob) LockTTL() time.Duration {
+	return time.Second
+}
+
+func TestTask_RunOnce(t *testing.T) {
+	UseCustomStore(&memoryStore{values: make(map[string]string)})
+	UseLocker(NewMemoryLocker())
+	defer UseLocker(nil)
+
+	for _, everyNode := range []bool{false, true} {
+		var runs int32
+		var now = time.Now()
+		// 模拟两个实例中的同一个任务
+		var nodes = []*Task{
+			{EntryID: 1, Name: "count", Job: &countJob{runs: &runs, everyNode: everyNode}},
+			{EntryID: 1, Name: "count", Job: &countJob{runs: &runs, everyNode: everyNode}},
+		}
+		for _, task := range nodes {
+			if task.obtain(now) {
+				task.run()
+			}
+		}
+		var expect int32 = 1
+		if everyNode {
+			expect = 2
+		}
+		if runs != expect {
+			t.Errorf("every node %v: expect %d runs, got %d", everyNode, expect, runs)
+		}
+	}
+}
+
+func TestTimer_Every(t *testing.T) {
+	UseCustomStore(&memoryStore{values: make(map[string]string)})
+	UseLocker(NewMemoryLocker())
+	defer UseLocker(nil)
+
+	// 模拟两个启动时间不同的实例, @every 5s 的任务在每 5 秒内只执行一次
+	var first, second = NewTimer(logging.Default()), NewTimer(logging.Default())
+	_ = first.AddJob(&PrintHello{})
+	_ = second.AddJob(&PrintHello{})
+	var tasks = []*Task{first.Tasks().GetJob("print_hello"), second.Tasks().GetJob("print_hello")}
+	var base = time.Unix(time.Now().Unix()/5*5, 0)
+	for _, c := range []struct {
+		task   int
+		offset time.Duration
+		expect bool
+	}{
+		{0, time.Second, true},
+		{1, 3 * time.Second, false},
+		{1, 6 * time.Second, true},
+		{0, 9 * time.Second, false},
+		{0, 11 * time.Second, true},
+	} {
+		if obtained := tasks[c.task].obtain(base.Add(c.offset)); obtained != c.expect {
+			t.Errorf("timer %d at +%s: expect %v, got %v", c.task, c.offset, c.expect, obtained)
+		}
+	}
+}
+
+type failJob struct {
+	PrintHello
+}
+
+func (f *failJob) Name() JobName {
+	return "fail"
+}
+
+func (f *failJob) Run(ctx context.Context) error {
+	return errors.New("failed")
+}
+
+func TestTimer_History(t *testing.T) {
+	UseCustomStore(&memoryStore{values: make(map[string]string)})
+	var timer = NewTimer(logging.Default())
+	if err := timer.AddContextJob(&failJob{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < HistoryLimit+1; i++ {
+		timer.Tasks().Exec("fail")
+	}
+
+	var records = timer.History("fail")
+	if len(records) != HistoryLimit {
+		t.Fatalf("expect %d records, got %d", HistoryLimit, len(records))
+	}
+	if records[0].Result != ResultFailed || records[0].Error != "failed" || records[0].Node != node || records[0].End.Before(records[0].Start) {
+		t.Errorf("unexpected record %+v", records[0])
+	}
+	if timer.LastError("fail")["msg"] != "failed" {
+		t.Errorf("unexpected last error %v", timer.LastError("fail"))
+	}
+	if timer.Prev("fail").IsZero() {
+		t.Error("prev should be recorded")
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	var store = &memoryStore{values: make(map[string]string)}
+	UseCustomStore(store)
+	var timer = NewTimer(logging.Default())
+	if err := timer.AddJob(&PrintHello{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	var router = gin.New()
+	AdminRoutes(router, timer)
+	var request = func(method, path, body string) *httptest.ResponseRecorder {
+		var recorder = httptest.NewRecorder()
+		var req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if recorder := request(http.MethodGet, "/timer/jobs", ""); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"running"`) {
+		t.Errorf("unexpected response %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := request(http.MethodGet, "/timer/jobs/undefined", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("expect 404, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodPost, "/timer/jobs/print_hello/pause", ""); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodPost, "/timer/jobs/print_hello/trigger", ""); recorder.Code != http.StatusConflict {
+		t.Errorf("the paused job should not be triggered, got %d", recorder.Code)
+	}
+
+	// 暂停状态在重启后恢复
+	var restarted = NewTimer(logging.Default())
+	var job = &PrintHello{}
+	_ = restarted.AddJob(job)
+	if restarted.Tasks().GetJob("print_hello").Status() != Pause || restarted.Tasks().GetJob("print_hello").runnable() {
+		t.Error("the pause state should be restored from the store")
+	}
+	if job.Status() != Run {
+		t.Error("the pause state should not be copied to the job")
+	}
+
+	if recorder := request(http.MethodPost, "/timer/jobs/print_hello/resume", ""); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodPut, "/timer/jobs/print_hello/spec", `{"spec": "invalid"}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("expect 400, got %d", recorder.Code)
+	}
+	if recorder := request(http.MethodPut, "/timer/jobs/print_hello/spec", `{"spec": "0 0 3 * * ?"}`); recorder.Code != http.StatusOK {
+		t.Errorf("expect 200, got %d", recorder.Code)
+	}
+	if spec := timer.Tasks().GetJob("print_hello").Spec(); spec != "0 0 3 * * ?" {
+		t.Errorf("expect the new spec, got %s", spec)
+	}
+}
+
+func TestTimer_Cluster(t *testing.T) {
+	UseCustomStore(&memoryStore{values: make(map[string]string)})
+	// 模拟两个实例
+	var first, second = NewTimer(logging.Default()), NewTimer(logging.Default())
+	_ = first.AddJob(&PrintHello{})
+	_ = second.AddJob(&PrintHello{})
+
+	first.Tasks().Pause("print_hello")
+	if second.Tasks().GetJob("print_hello").runnable() {
+		t.Error("the job should be paused on every instance")
+	}
+	second.Tasks().Start("print_hello")
+	if !first.Tasks().GetJob("print_hello").runnable() {
+		t.Error("the job should be resumed on every instance")
+	}
+
+	if err := first.Reschedule("print_hello", "0 0 3 * * ?"); err != nil {
+		t.Fatal(err)
+	}
+	// 其它实例在下一次调度时应用
+	var task = second.Tasks().GetJob("print_hello")
+	task.Run()
+	if spec := task.Spec(); spec != "0 0 3 * * ?" {
+		t.Errorf("the new spec should be applied on the other instance, got %s", spec)
+	}
+
+	var restarted = NewTimer(logging.Default())
+	_ = restarted.AddJob(&PrintHello{})
+	if spec := restarted.Tasks().GetJob("print_hello").Spec(); spec != "0 0 3 * * ?" {
+		t.Errorf("the new spec should be restored from the store, got %s", spec)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"bytes"
 	"errors"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/sirupsen/logrus"
+	"github.com/kenretto/crane/logging"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -15,7 +15,7 @@ import (
 
 var (
 	client = http.DefaultClient
-	logger *logrus.Entry
+	logger = logging.Default()
 )
 
 type (
@@ -36,7 +36,7 @@ type (
 )
 
 // SetLogger 设置全局 log 输出对象
-func SetLogger(l *logrus.Entry) {
+func SetLogger(l logging.Logger) {
 	logger = l
 }
 
@@ -127,7 +127,7 @@ func (req *Request) Do() (*Response, error) {
 // Do 发起请求
 func Do(req *Request) (*Response, error) {
 	var (
-		fields = logrus.Fields{
+		fields = logging.Fields{
 			"parameters":     req.parameters,
 			"request_method": req.method,
 			"request_url":    req.url,
@@ -154,7 +154,7 @@ func Do(req *Request) (*Response, error) {
 	}
 
 	fields["response_body"] = string(body)
-	logger.WithFields(fields).Info()
+	logger.WithContext(request.Context()).WithFields(fields).Info()
 
 	response.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 	return &Response{response}, err
@@ -1,6 +1,7 @@
 package request
 
 import (
+	"github.com/kenretto/crane/logging"
 	"github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/url"
@@ -10,7 +11,7 @@ import (
 func TestDo(t *testing.T) {
 	l := logrus.New()
 	logrus.SetReportCaller(true)
-	logger = l.WithField("filter", "pkg.util.request.test")
+	SetLogger(logging.NewLogrus(l.WithField("filter", "pkg.util.request.test")))
 	response, err := URL("http://www.baidu.com/").ContentType("application/json").Parameters(url.Values{"nickname": []string{"wang"}}).Body([]byte(`{"hello": "world"}`)).Do()
 	if err != nil {
 		t.Error(err)